@@ -32,17 +32,21 @@ import (
 	"github.com/giancosta86/caravel"
 
 	"github.com/giancosta86/moondeploy/v3"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
 	"github.com/giancosta86/moondeploy/v3/log"
 )
 
 const userSettingsFileName = ".moondeploy.json"
+const defaultCredentialsFileName = ".moondeploy.credentials.json"
 
 const defaultLocalDirName = "MoonDeploy"
 const galleryDirName = "apps"
 const logsDirName = "logs"
+const actualBaseURLCacheFileName = ".actualBaseURLs.json"
 
 const defaultBufferSize = 1024 * 1024
 const defaultSkipAppOutput = false
+const defaultLaunchMode = descriptors.AttachedLaunchMode
 
 const defaultLoggingLevel = logging.DEBUG
 
@@ -51,14 +55,53 @@ const defaultForegroundColor = 22
 
 const defaultLogMaxAgeInHours = 120
 
+const defaultAppLogMaxSizeInMB = 10
+const defaultAppLogRetainedFiles = 5
+
+const defaultActualBaseURLCacheTTLInMinutes = 60
+
+const defaultUpdateCheckIntervalInMinutes = 240
+
+const defaultLockTimeoutInSeconds = 60
+
+const defaultRetrievalAttempts = 4
+const defaultRetryDelayInMilliseconds = 1000
+
+const defaultMaxPackageEntries = 100000
+const defaultMaxPackageSizeInMB = 4096
+
 type rawMoonSettingsStruct struct {
 	LocalDirectory   string
 	BufferSize       int64
 	LoggingLevel     string
 	SkipAppOutput    bool
+	LaunchMode       string
 	BackgroundColor  int
 	ForegroundColor  int
 	LogMaxAgeInHours int
+	CredentialsFile  string
+
+	InsecureCredentialHosts []string
+
+	AppLogMaxSizeInMB   int64
+	AppLogRetainedFiles int
+
+	GitHubToken           string
+	GitHubEnterpriseHosts []string
+
+	GiteaHosts []string
+
+	ActualBaseURLCacheTTLInMinutes int
+
+	UpdateCheckIntervalInMinutes int
+
+	LockTimeoutInSeconds int
+
+	RetrievalAttempts        int
+	RetryDelayInMilliseconds int
+
+	MaxPackageEntries  int
+	MaxPackageSizeInMB int64
 }
 
 type MoonSettings struct {
@@ -68,9 +111,33 @@ type MoonSettings struct {
 	bufferSize       int64
 	loggingLevel     logging.Level
 	skipAppOutput    bool
+	launchMode       string
 	backgroundColor  int
 	foregroundColor  int
 	logMaxAgeInHours int
+	credentialsFile  string
+
+	insecureCredentialHosts []string
+
+	appLogMaxSizeInMB   int64
+	appLogRetainedFiles int
+
+	gitHubToken           string
+	gitHubEnterpriseHosts []string
+
+	giteaHosts []string
+
+	actualBaseURLCacheTTLInMinutes int
+
+	updateCheckIntervalInMinutes int
+
+	lockTimeoutInSeconds int
+
+	retrievalAttempts        int
+	retryDelayInMilliseconds int
+
+	maxPackageEntries  int
+	maxPackageSizeInMB int64
 }
 
 var moonSettings *MoonSettings
@@ -99,6 +166,10 @@ func (settings *MoonSettings) IsSkipAppOutput() bool {
 	return settings.skipAppOutput
 }
 
+func (settings *MoonSettings) GetLaunchMode() string {
+	return settings.launchMode
+}
+
 func (settings *MoonSettings) GetBackgroundColor() int {
 	return settings.backgroundColor
 }
@@ -111,11 +182,73 @@ func (settings *MoonSettings) GetLogMaxAgeInHours() int {
 	return settings.logMaxAgeInHours
 }
 
+func (settings *MoonSettings) GetCredentialsFile() string {
+	return settings.credentialsFile
+}
+
+func (settings *MoonSettings) GetInsecureCredentialHosts() []string {
+	return settings.insecureCredentialHosts
+}
+
+func (settings *MoonSettings) GetAppLogMaxSizeInMB() int64 {
+	return settings.appLogMaxSizeInMB
+}
+
+func (settings *MoonSettings) GetAppLogRetainedFiles() int {
+	return settings.appLogRetainedFiles
+}
+
+func (settings *MoonSettings) GetGitHubToken() string {
+	return settings.gitHubToken
+}
+
+func (settings *MoonSettings) GetGitHubEnterpriseHosts() []string {
+	return settings.gitHubEnterpriseHosts
+}
+
+func (settings *MoonSettings) GetGiteaHosts() []string {
+	return settings.giteaHosts
+}
+
+func (settings *MoonSettings) GetActualBaseURLCacheTTLInMinutes() int {
+	return settings.actualBaseURLCacheTTLInMinutes
+}
+
+func (settings *MoonSettings) GetUpdateCheckIntervalInMinutes() int {
+	return settings.updateCheckIntervalInMinutes
+}
+
+func (settings *MoonSettings) GetLockTimeoutInSeconds() int {
+	return settings.lockTimeoutInSeconds
+}
+
+func (settings *MoonSettings) GetRetrievalAttempts() int {
+	return settings.retrievalAttempts
+}
+
+func (settings *MoonSettings) GetRetryDelayInMilliseconds() int {
+	return settings.retryDelayInMilliseconds
+}
+
+func (settings *MoonSettings) GetMaxPackageEntries() int {
+	return settings.maxPackageEntries
+}
+
+func (settings *MoonSettings) GetMaxPackageSizeInMB() int64 {
+	return settings.maxPackageSizeInMB
+}
+
 func getRawMoonSettings() (rawMoonSettings *rawMoonSettingsStruct) {
 	rawMoonSettings = &rawMoonSettingsStruct{
-		BackgroundColor:  -1,
-		ForegroundColor:  -1,
-		LogMaxAgeInHours: defaultLogMaxAgeInHours,
+		BackgroundColor:          -1,
+		ForegroundColor:          -1,
+		LogMaxAgeInHours:         defaultLogMaxAgeInHours,
+		RetryDelayInMilliseconds: -1,
+		AppLogRetainedFiles:      -1,
+
+		ActualBaseURLCacheTTLInMinutes: -1,
+
+		LockTimeoutInSeconds: -1,
 	}
 
 	userDir, err := caravel.GetUserDirectory()
@@ -199,6 +332,12 @@ func getMoonSettings() *MoonSettings {
 
 	moonSettings.skipAppOutput = rawMoonSettings.SkipAppOutput
 
+	if descriptors.IsValidLaunchMode(rawMoonSettings.LaunchMode) {
+		moonSettings.launchMode = rawMoonSettings.LaunchMode
+	} else {
+		moonSettings.launchMode = defaultLaunchMode
+	}
+
 	if 0 <= rawMoonSettings.BackgroundColor && rawMoonSettings.BackgroundColor <= 255 {
 		moonSettings.backgroundColor = rawMoonSettings.BackgroundColor
 	} else {
@@ -211,5 +350,88 @@ func getMoonSettings() *MoonSettings {
 		moonSettings.foregroundColor = defaultForegroundColor
 	}
 
+	if rawMoonSettings.CredentialsFile != "" {
+		moonSettings.credentialsFile = rawMoonSettings.CredentialsFile
+	} else {
+		userDir, err := caravel.GetUserDirectory()
+		if err == nil {
+			moonSettings.credentialsFile = filepath.Join(userDir, defaultCredentialsFileName)
+		}
+	}
+
+	if rawMoonSettings.InsecureCredentialHosts != nil {
+		moonSettings.insecureCredentialHosts = rawMoonSettings.InsecureCredentialHosts
+	} else {
+		moonSettings.insecureCredentialHosts = []string{}
+	}
+
+	if rawMoonSettings.AppLogMaxSizeInMB > 0 {
+		moonSettings.appLogMaxSizeInMB = rawMoonSettings.AppLogMaxSizeInMB
+	} else {
+		moonSettings.appLogMaxSizeInMB = defaultAppLogMaxSizeInMB
+	}
+
+	if rawMoonSettings.AppLogRetainedFiles >= 0 {
+		moonSettings.appLogRetainedFiles = rawMoonSettings.AppLogRetainedFiles
+	} else {
+		moonSettings.appLogRetainedFiles = defaultAppLogRetainedFiles
+	}
+
+	moonSettings.gitHubToken = rawMoonSettings.GitHubToken
+
+	if rawMoonSettings.GitHubEnterpriseHosts != nil {
+		moonSettings.gitHubEnterpriseHosts = rawMoonSettings.GitHubEnterpriseHosts
+	} else {
+		moonSettings.gitHubEnterpriseHosts = []string{}
+	}
+
+	if rawMoonSettings.GiteaHosts != nil {
+		moonSettings.giteaHosts = rawMoonSettings.GiteaHosts
+	} else {
+		moonSettings.giteaHosts = []string{}
+	}
+
+	if rawMoonSettings.ActualBaseURLCacheTTLInMinutes >= 0 {
+		moonSettings.actualBaseURLCacheTTLInMinutes = rawMoonSettings.ActualBaseURLCacheTTLInMinutes
+	} else {
+		moonSettings.actualBaseURLCacheTTLInMinutes = defaultActualBaseURLCacheTTLInMinutes
+	}
+
+	if rawMoonSettings.UpdateCheckIntervalInMinutes > 0 {
+		moonSettings.updateCheckIntervalInMinutes = rawMoonSettings.UpdateCheckIntervalInMinutes
+	} else {
+		moonSettings.updateCheckIntervalInMinutes = defaultUpdateCheckIntervalInMinutes
+	}
+
+	if rawMoonSettings.LockTimeoutInSeconds >= 0 {
+		moonSettings.lockTimeoutInSeconds = rawMoonSettings.LockTimeoutInSeconds
+	} else {
+		moonSettings.lockTimeoutInSeconds = defaultLockTimeoutInSeconds
+	}
+
+	if rawMoonSettings.RetrievalAttempts > 0 {
+		moonSettings.retrievalAttempts = rawMoonSettings.RetrievalAttempts
+	} else {
+		moonSettings.retrievalAttempts = defaultRetrievalAttempts
+	}
+
+	if rawMoonSettings.RetryDelayInMilliseconds >= 0 {
+		moonSettings.retryDelayInMilliseconds = rawMoonSettings.RetryDelayInMilliseconds
+	} else {
+		moonSettings.retryDelayInMilliseconds = defaultRetryDelayInMilliseconds
+	}
+
+	if rawMoonSettings.MaxPackageEntries > 0 {
+		moonSettings.maxPackageEntries = rawMoonSettings.MaxPackageEntries
+	} else {
+		moonSettings.maxPackageEntries = defaultMaxPackageEntries
+	}
+
+	if rawMoonSettings.MaxPackageSizeInMB > 0 {
+		moonSettings.maxPackageSizeInMB = rawMoonSettings.MaxPackageSizeInMB
+	} else {
+		moonSettings.maxPackageSizeInMB = defaultMaxPackageSizeInMB
+	}
+
 	return moonSettings
 }
@@ -21,6 +21,8 @@
 package bash
 
 import (
+	"context"
+
 	"github.com/giancosta86/caravel/terminals"
 
 	"github.com/giancosta86/moondeploy/v3/descriptors"
@@ -30,8 +32,8 @@ import (
 	"github.com/giancosta86/moondeploy/v3/ui/termui"
 )
 
-func StartGUI(launcher launchers.Launcher, bootDescriptorPath string) (err error) {
-	bootDescriptor, err := descriptors.NewAppDescriptorFromPath(bootDescriptorPath)
+func StartGUI(ctx context.Context, launcher launchers.Launcher, bootDescriptorPath string) (err error) {
+	bootDescriptor, err := descriptors.NewAppDescriptorFromPath(ctx, bootDescriptorPath)
 	if err != nil {
 		return err
 	}
@@ -40,7 +42,7 @@ func StartGUI(launcher launchers.Launcher, bootDescriptorPath string) (err error
 
 	userInterface := termui.NewTerminalUserInterface(launcher, bashTerminal)
 
-	result := engine.Run(launcher, userInterface, bootDescriptor)
+	result := engine.Run(ctx, launcher, userInterface, bootDescriptor)
 
 	log.Notice("OK")
 
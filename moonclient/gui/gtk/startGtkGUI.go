@@ -21,9 +21,12 @@
 package gtk
 
 import (
+	"context"
+	"time"
+
 	"github.com/gotk3/gotk3/gtk"
-	"github.com/op/go-logging"
 
+	"github.com/giancosta86/moondeploy/v3/apps"
 	"github.com/giancosta86/moondeploy/v3/descriptors"
 	"github.com/giancosta86/moondeploy/v3/engine"
 	"github.com/giancosta86/moondeploy/v3/launchers"
@@ -31,27 +34,39 @@ import (
 	"github.com/giancosta86/moondeploy/v3/ui/gtkui"
 )
 
+/*
+outcomeTimeout is how long to wait for the background routine, once the
+main loop has terminated, before giving up on its outcome
+*/
+const outcomeTimeout = 10 * time.Second
+
 type guiOutcomeStruct struct {
 	userInterface *gtkui.GtkUserInterface
 	err           error
 }
 
-func StartGUI(launcher launchers.Launcher, bootDescriptorPath string) (err error) {
+func StartGUI(ctx context.Context, launcher launchers.Launcher, bootDescriptorPath string) (err error) {
 	log.Debug("Initializing GTK...")
 	gtkui.InitGTK()
 	log.Debug("GTK initialized")
 
-	guiOutcomeChannel := make(chan guiOutcomeStruct)
-	defer close(guiOutcomeChannel)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	go backgroundOrchestrator(launcher, bootDescriptorPath, guiOutcomeChannel)
+	guiOutcomeChannel := make(chan guiOutcomeStruct, 1)
+
+	go backgroundOrchestrator(ctx, launcher, bootDescriptorPath, guiOutcomeChannel)
 
 	log.Debug("Starting GTK main loop...")
 	gtk.Main()
 
-	log.SetCallback(func(level logging.Level, message string) {})
 	log.Debug("GTK main loop terminated")
 
+	//
+	//If the user has closed the window, the background routine must stop
+	//
+	cancel()
+
 	select {
 	case guiOutcome := <-guiOutcomeChannel:
 		log.Debug("Outcome retrieved from the GUI channel")
@@ -68,27 +83,26 @@ func StartGUI(launcher launchers.Launcher, bootDescriptorPath string) (err error
 
 		log.Notice("OK")
 		return nil
-	default:
-		log.Debug("The user has manually closed the program")
+	case <-time.After(outcomeTimeout):
+		log.Warning("The background routine did not stop in time")
 		return &engine.ExecutionCanceled{}
 	}
 }
 
-func backgroundOrchestrator(launcher launchers.Launcher, bootDescriptorPath string, guiOutcomeChannel chan guiOutcomeStruct) {
-	outcome := runEngineWithGtk(launcher, bootDescriptorPath)
+func backgroundOrchestrator(ctx context.Context, launcher launchers.Launcher, bootDescriptorPath string, guiOutcomeChannel chan guiOutcomeStruct) {
+	outcome := runEngineWithGtk(ctx, launcher, bootDescriptorPath)
 	userInterface := outcome.userInterface
 	err := outcome.err
 
-	log.SetCallback(func(level logging.Level, message string) {})
 	log.Debug("Result returned by the background routine. Is UI available? %v", userInterface != nil)
 
 	if err != nil {
 		log.Warning("Err is: %v", err)
 
-		if userInterface != nil {
+		if userInterface != nil && ctx.Err() == nil && !userInterface.IsClosedByUser() {
 			switch err.(type) {
 
-			case *engine.ExecutionCanceled:
+			case *engine.ExecutionCanceled, *apps.AppFailed:
 				break
 
 			default:
@@ -103,7 +117,7 @@ func backgroundOrchestrator(launcher launchers.Launcher, bootDescriptorPath stri
 	guiOutcomeChannel <- outcome
 }
 
-func runEngineWithGtk(launcher launchers.Launcher, bootDescriptorPath string) guiOutcomeStruct {
+func runEngineWithGtk(ctx context.Context, launcher launchers.Launcher, bootDescriptorPath string) guiOutcomeStruct {
 	log.Debug("Creating the GTK+ user interface...")
 
 	userInterface, err := gtkui.NewGtkUserInterface(launcher)
@@ -119,7 +133,7 @@ func runEngineWithGtk(launcher launchers.Launcher, bootDescriptorPath string) gu
 	//----------------------------------------------------------------------------
 	log.Info("Opening boot descriptor: '%v'...", bootDescriptorPath)
 
-	bootDescriptor, err := descriptors.NewAppDescriptorFromPath(bootDescriptorPath)
+	bootDescriptor, err := descriptors.NewAppDescriptorFromPath(ctx, bootDescriptorPath)
 	if err != nil {
 		return guiOutcomeStruct{
 			userInterface: userInterface,
@@ -132,7 +146,7 @@ func runEngineWithGtk(launcher launchers.Launcher, bootDescriptorPath string) gu
 
 	log.Debug("Starting the launch process...")
 
-	err = engine.Run(launcher, userInterface, bootDescriptor)
+	err = engine.Run(ctx, launcher, userInterface, bootDescriptor)
 	return guiOutcomeStruct{
 		userInterface: userInterface,
 		err:           err,
@@ -23,9 +23,17 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/giancosta86/moondeploy/v3"
+	"github.com/giancosta86/moondeploy/v3/apps"
+	"github.com/giancosta86/moondeploy/v3/config"
+	"github.com/giancosta86/moondeploy/v3/credentials"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
 	"github.com/giancosta86/moondeploy/v3/engine"
+	"github.com/giancosta86/moondeploy/v3/gitHubUtils"
+	"github.com/giancosta86/moondeploy/v3/giteaUtils"
 	"github.com/giancosta86/moondeploy/v3/launchers"
 	"github.com/giancosta86/moondeploy/v3/log"
 
@@ -46,8 +54,24 @@ func main() {
 
 	log.Debug("Launcher is: %#v", launcher)
 
+	err := credentials.Setup(settings.GetCredentialsFile(), settings.GetInsecureCredentialHosts())
+	if err != nil {
+		log.Warning("Cannot load the credentials: %v", err)
+	}
+
+	gitHubUtils.Setup(gitHubUtils.Settings{
+		Token:           settings.GetGitHubToken(),
+		EnterpriseHosts: settings.GetGitHubEnterpriseHosts(),
+	})
+
+	giteaUtils.Setup(giteaUtils.Settings{
+		Hosts: settings.GetGiteaHosts(),
+	})
+
+	setupActualBaseURLCache(settings)
+
 	command := os.Args[1]
-	err := executeCommand(launcher, command)
+	err = executeCommand(launcher, command)
 
 	switch err.(type) {
 	case nil:
@@ -59,6 +83,9 @@ func main() {
 	case *verbs.InvalidCommandLineArguments:
 		exitWithUsage()
 
+	case *apps.AppFailed:
+		exitWithAppFailure(err.(*apps.AppFailed))
+
 	default:
 		exitWithError(err)
 	}
@@ -71,11 +98,62 @@ func executeCommand(launcher launchers.Launcher, command string) (err error) {
 	case verbs.Serve:
 		return verbs.DoServe()
 
+	case verbs.Validate:
+		return verbs.DoValidate()
+
+	case verbs.Lint:
+		return verbs.DoLint()
+
+	case verbs.Generate:
+		return verbs.DoGenerate()
+
+	case verbs.Release:
+		return verbs.DoRelease()
+
+	case verbs.Migrate:
+		return verbs.DoMigrate()
+
+	case verbs.CheckUpdates:
+		return verbs.DoCheckUpdates(launcher, settings)
+
+	case verbs.Update:
+		return verbs.DoUpdate(launcher, settings)
+
+	case verbs.StageUpdates:
+		return verbs.DoStageUpdates(launcher, settings)
+
+	case verbs.UpdateDaemon:
+		return verbs.DoUpdateDaemon(launcher, settings)
+
+	case verbs.Locks:
+		return verbs.DoLocks(settings)
+
+	case verbs.AppLog:
+		return verbs.DoAppLog(settings)
+
+	case verbs.ClearURLCache:
+		return verbs.DoClearURLCache()
+
 	default:
 		return verbs.DoRun(launcher, settings)
 	}
 }
 
+/*
+A zero TTL disables the persistent cache - see descriptors.SetupActualBaseURLCache() -
+so that actual base URLs are resolved at every run
+*/
+func setupActualBaseURLCache(settings config.Settings) {
+	cacheTTLInMinutes := settings.GetActualBaseURLCacheTTLInMinutes()
+
+	err := descriptors.SetupActualBaseURLCache(
+		filepath.Join(settings.GetGalleryDirectory(), actualBaseURLCacheFileName),
+		time.Duration(cacheTTLInMinutes)*time.Minute)
+	if err != nil {
+		log.Warning("Cannot load the actual base URL cache: %v", err)
+	}
+}
+
 func exitWithCancel() {
 	log.Warning("*** EXECUTION CANCELED ***")
 	os.Exit(v3.ExitCodeCanceled)
@@ -86,6 +164,17 @@ func exitWithError(err error) {
 	os.Exit(v3.ExitCodeError)
 }
 
+/*
+exitWithAppFailure propagates the exit code of the app, mapped into the range
+reserved to app failures - see v3.GetAppFailureExitCode() - so that scripts
+can tell the failures of the app from the failures of MoonDeploy
+*/
+func exitWithAppFailure(err *apps.AppFailed) {
+	log.Warning(err.Error())
+
+	os.Exit(v3.GetAppFailureExitCode(err.ExitCode))
+}
+
 func exitWithUsage() {
 	fmt.Println()
 	fmt.Println()
@@ -96,6 +185,54 @@ func exitWithUsage() {
 	fmt.Printf("%v <port> <directory>\n", verbs.Serve)
 	fmt.Println("\tStarts an HTTP server on <port> serving files from <directory>")
 	fmt.Println()
+	fmt.Printf("%v <app descriptor file> [%v]\n", verbs.Validate, verbs.ResolveURLsOption)
+	fmt.Println("\tValidates the descriptor offline, reporting all of its problems - and resolves its actual base URL, if requested")
+	fmt.Println()
+	fmt.Printf("%v <app descriptor file> [<packages directory>] [%v]\n", verbs.Lint, verbs.ResolveURLsOption)
+	fmt.Println("\tLooks for common mistakes in the descriptor - and in the packages, if <packages directory> is passed; the actual base URL is resolved only if requested")
+	fmt.Println()
+	fmt.Printf("%v <packages directory> <base URL> <name> <version> <publisher> <command line>...\n", verbs.Generate)
+	fmt.Println("\tGenerates a V3 descriptor declaring the packages in <packages directory>")
+	fmt.Println()
+	fmt.Printf("%v <release spec> <build directory> <output directory> [<previous descriptor path or URL>]\n", verbs.Release)
+	fmt.Println("\tBuilds the packages and the descriptor of a release, bumping the versions of the changed packages only")
+	fmt.Println()
+	fmt.Printf("%v <V1/V2 descriptor file> <V3 descriptor file>\n", verbs.Migrate)
+	fmt.Println("\tConverts a V1/V2 descriptor into an equivalent V3 descriptor")
+	fmt.Println()
+	fmt.Printf("%v [<app descriptor file>]\n", verbs.CheckUpdates)
+	fmt.Println("\tReports whether an update is available - for the given app or for every app in the gallery - and which packages would change")
+	fmt.Println()
+	fmt.Printf("%v [<app descriptor file>]\n", verbs.Update)
+	fmt.Println("\tUpdates the given app - or every app in the gallery - without starting it")
+	fmt.Println()
+	fmt.Println(verbs.StageUpdates)
+	fmt.Println("\tDownloads the updates of every app in the gallery, applied at the next launch - for example, when run by a scheduler")
+	fmt.Println()
+	fmt.Println(verbs.UpdateDaemon)
+	fmt.Println("\tLike stage-updates, but running periodically - every UpdateCheckIntervalInMinutes - until interrupted")
+	fmt.Println()
+	fmt.Printf("%v [release <app directory>|release-stale]\n", verbs.Locks)
+	fmt.Println("\tLists the app locks in the gallery - with their owners - or force-releases the lock of an app, or every stale lock")
+	fmt.Println()
+	fmt.Printf("%v <app descriptor file> [<number of lines>]\n", verbs.AppLog)
+	fmt.Println("\tPrints the latest output log of the app - or just its last lines")
+	fmt.Println()
+	fmt.Println(verbs.ClearURLCache)
+	fmt.Println("\tClears the cache of the actual base URLs - such as the ones resolved from 'latest' releases")
+	fmt.Println()
+	fmt.Println()
+	fmt.Println("Exit codes")
+	fmt.Println()
+	fmt.Printf("%v: success - %v: error - %v: canceled - %v: the app was terminated by a signal\n",
+		v3.ExitCodeSuccess,
+		v3.ExitCodeError,
+		v3.ExitCodeCanceled,
+		v3.ExitCodeAppTerminated)
+	fmt.Printf("%v + N: the app failed with exit code N (%v if N is too high)\n",
+		v3.ExitCodeAppFailureBase,
+		v3.ExitCodeAppFailureOverflow)
+	fmt.Println()
 
 	os.Exit(v3.ExitCodeError)
 }
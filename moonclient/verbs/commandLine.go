@@ -20,8 +20,37 @@
 
 package verbs
 
+import (
+	"os"
+)
+
 type InvalidCommandLineArguments struct{}
 
 func (err *InvalidCommandLineArguments) Error() string {
 	return "Invalid command line arguments"
 }
+
+/*
+ResolveURLsOption makes the authoring verbs resolve the actual base URL
+of the descriptor - which usually requires network access
+*/
+const ResolveURLsOption = "--resolve-urls"
+
+/*
+extractOption returns the command line arguments following the verb,
+without the given option - telling whether it was found
+*/
+func extractOption(option string) (arguments []string, optionFound bool) {
+	arguments = []string{}
+
+	for _, argument := range os.Args[2:] {
+		if argument == option {
+			optionFound = true
+			continue
+		}
+
+		arguments = append(arguments, argument)
+	}
+
+	return arguments, optionFound
+}
@@ -0,0 +1,125 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package verbs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/giancosta86/caravel"
+
+	"github.com/giancosta86/moondeploy/v3/apps"
+	"github.com/giancosta86/moondeploy/v3/config"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+)
+
+const AppLog = "app-log"
+
+func DoAppLog(settings config.Settings) (err error) {
+	lineCount := 0
+
+	switch len(os.Args) {
+	case 3:
+		break
+
+	case 4:
+		lineCount, err = strconv.Atoi(os.Args[3])
+		if err != nil || lineCount <= 0 {
+			return &InvalidCommandLineArguments{}
+		}
+
+	default:
+		return &InvalidCommandLineArguments{}
+	}
+
+	descriptorPath := os.Args[2]
+
+	ctx, stopInterruptionHandling := newInterruptibleContext()
+	defer stopInterruptionHandling()
+
+	bootDescriptor, err := descriptors.NewAppDescriptorFromPath(ctx, descriptorPath)
+	if err != nil {
+		return err
+	}
+
+	appGallery := apps.NewAppGallery(settings.GetGalleryDirectory())
+
+	app, err := appGallery.GetApp(bootDescriptor)
+	if err != nil {
+		return err
+	}
+
+	outputLogPath := app.GetOutputLogPath()
+	if !caravel.FileExists(outputLogPath) {
+		return fmt.Errorf("No output log available for '%v'", bootDescriptor.GetName())
+	}
+
+	outputLog, err := os.Open(outputLogPath)
+	if err != nil {
+		return err
+	}
+	defer outputLog.Close()
+
+	fmt.Println()
+	fmt.Printf("===== %v =====\n", outputLogPath)
+
+	if lineCount == 0 {
+		_, err = io.Copy(os.Stdout, outputLog)
+		return err
+	}
+
+	return printLastLines(outputLog, lineCount)
+}
+
+func printLastLines(reader io.Reader, lineCount int) (err error) {
+	lastLines := make([]string, 0, lineCount)
+
+	bufferedReader := bufio.NewReader(reader)
+
+	for {
+		line, err := bufferedReader.ReadString('\n')
+
+		if line != "" {
+			if len(lastLines) == lineCount {
+				lastLines = lastLines[1:]
+			}
+			lastLines = append(lastLines, strings.TrimRight(line, "\r\n"))
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, line := range lastLines {
+		fmt.Println(line)
+	}
+
+	return nil
+}
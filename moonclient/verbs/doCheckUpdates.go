@@ -0,0 +1,124 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package verbs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giancosta86/moondeploy/v3/config"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/engine"
+	"github.com/giancosta86/moondeploy/v3/launchers"
+	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/ui/batchui"
+	"github.com/giancosta86/moondeploy/v3/versioning"
+)
+
+const CheckUpdates = "check-updates"
+
+func DoCheckUpdates(launcher launchers.Launcher, settings config.Settings) (err error) {
+	descriptorPaths, err := getTargetDescriptorPaths(settings)
+	if err != nil {
+		return err
+	}
+
+	ctx, stopInterruptionHandling := newInterruptibleContext()
+	defer stopInterruptionHandling()
+
+	failedChecks := 0
+
+	for _, descriptorPath := range descriptorPaths {
+		log.Info("Checking for updates: '%v'...", descriptorPath)
+		updateCheck, err := checkForUpdates(ctx, launcher, descriptorPath)
+		if ctx.Err() != nil {
+			return &engine.ExecutionCanceled{}
+		}
+
+		fmt.Println()
+
+		if err != nil {
+			fmt.Printf("%v\n\tERROR: %v\n", descriptorPath, err)
+			failedChecks++
+			continue
+		}
+
+		printUpdateCheck(updateCheck)
+	}
+
+	if len(descriptorPaths) == 0 {
+		fmt.Println()
+		fmt.Println("No apps installed")
+	}
+
+	if failedChecks > 0 {
+		return fmt.Errorf("%v app(s) could not be checked", failedChecks)
+	}
+
+	return nil
+}
+
+func checkForUpdates(ctx context.Context, launcher launchers.Launcher, descriptorPath string) (updateCheck *engine.UpdateCheck, err error) {
+	bootDescriptor, err := descriptors.NewAppDescriptorFromPath(ctx, descriptorPath)
+	if err != nil {
+		return nil, err
+	}
+
+	appEngine := engine.NewEngine(launcher, batchui.NewBatchUserInterface(), bootDescriptor)
+	defer appEngine.Close()
+
+	return appEngine.CheckForUpdates(ctx)
+}
+
+func printUpdateCheck(updateCheck *engine.UpdateCheck) {
+	switch {
+	case updateCheck.AvailableVersion == nil:
+		fmt.Printf("%v: update check skipped, as requested by the local descriptor\n", updateCheck.App)
+
+	case updateCheck.InstalledVersion == nil:
+		fmt.Printf("%v: not installed - available version: %v\n", updateCheck.App, updateCheck.AvailableVersion)
+
+	case updateCheck.IsUpdateAvailable():
+		fmt.Printf("%v: update available - %v -> %v\n",
+			updateCheck.App,
+			updateCheck.InstalledVersion,
+			updateCheck.AvailableVersion)
+
+	default:
+		fmt.Printf("%v: up-to-date (%v)\n", updateCheck.App, updateCheck.InstalledVersion)
+		return
+	}
+
+	for _, packageChange := range updateCheck.PackageChanges {
+		fmt.Printf("\t* %v: %v -> %v\n",
+			packageChange.PackageName,
+			formatPackageVersion(packageChange.InstalledVersion),
+			formatPackageVersion(packageChange.AvailableVersion))
+	}
+}
+
+func formatPackageVersion(packageVersion *versioning.Version) string {
+	if packageVersion == nil {
+		return "-"
+	}
+
+	return packageVersion.String()
+}
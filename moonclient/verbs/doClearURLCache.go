@@ -0,0 +1,50 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package verbs
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+)
+
+const ClearURLCache = "clear-url-cache"
+
+/*
+DoClearURLCache empties the cache of the actual base URLs, so that - for example -
+a 'latest' release URL is resolved again even before its cache entry expires
+*/
+func DoClearURLCache() (err error) {
+	if len(os.Args) != 2 {
+		return &InvalidCommandLineArguments{}
+	}
+
+	err = descriptors.ClearActualBaseURLCache()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Actual base URL cache cleared")
+
+	return nil
+}
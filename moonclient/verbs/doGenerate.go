@@ -0,0 +1,80 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package verbs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/giancosta86/caravel"
+
+	"github.com/giancosta86/moondeploy/v3/authoring"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+const Generate = "generate"
+
+func DoGenerate() (err error) {
+	if len(os.Args) < 8 {
+		return &InvalidCommandLineArguments{}
+	}
+
+	packagesDirectory := os.Args[2]
+
+	settings := authoring.GenerationSettings{
+		BaseURL:     os.Args[3],
+		Name:        os.Args[4],
+		Version:     os.Args[5],
+		Publisher:   os.Args[6],
+		Description: os.Args[4],
+		CommandLine: os.Args[7:],
+	}
+
+	descriptorPath := filepath.Join(packagesDirectory, descriptors.DefaultDescriptorFileName)
+	if caravel.FileExists(descriptorPath) {
+		err = fmt.Errorf("The descriptor already exists: '%v'", descriptorPath)
+		fmt.Println()
+		fmt.Println(err)
+		return err
+	}
+
+	descriptorBytes, err := authoring.GenerateDescriptor(packagesDirectory, settings)
+	if err != nil {
+		fmt.Println()
+		fmt.Println(err)
+		return err
+	}
+
+	log.Info("Saving the generated descriptor to '%v'...", descriptorPath)
+	err = ioutil.WriteFile(descriptorPath, descriptorBytes, 0644)
+	if err != nil {
+		return err
+	}
+	log.Notice("Descriptor saved")
+
+	fmt.Println()
+	fmt.Printf("Descriptor generated: '%v'\n", descriptorPath)
+
+	return nil
+}
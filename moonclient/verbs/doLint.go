@@ -0,0 +1,69 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package verbs
+
+import (
+	"fmt"
+
+	"github.com/giancosta86/moondeploy/v3/authoring"
+)
+
+const Lint = "lint"
+
+func DoLint() (err error) {
+	arguments, resolvingURLs := extractOption(ResolveURLsOption)
+	if len(arguments) < 1 || len(arguments) > 2 {
+		return &InvalidCommandLineArguments{}
+	}
+
+	descriptorPath := arguments[0]
+
+	packagesDirectory := ""
+	if len(arguments) > 1 {
+		packagesDirectory = arguments[1]
+	}
+
+	ctx, stopInterruptionHandling := newInterruptibleContext()
+	defer stopInterruptionHandling()
+
+	warnings, err := authoring.Lint(ctx, descriptorPath, packagesDirectory, resolvingURLs)
+	if err != nil {
+		fmt.Println()
+		fmt.Println(err)
+		return err
+	}
+
+	fmt.Println()
+
+	if len(warnings) == 0 {
+		fmt.Println("No problems found")
+		return nil
+	}
+
+	for _, warning := range warnings {
+		fmt.Printf("* %v\n", warning)
+	}
+
+	fmt.Println()
+	fmt.Printf("%v warning(s) found\n", len(warnings))
+
+	return nil
+}
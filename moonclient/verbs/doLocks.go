@@ -0,0 +1,185 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package verbs
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/giancosta86/caravel"
+
+	"github.com/giancosta86/moondeploy/v3/apps"
+	"github.com/giancosta86/moondeploy/v3/config"
+)
+
+const Locks = "locks"
+
+const releaseLockCommand = "release"
+const releaseStaleLocksCommand = "release-stale"
+
+func DoLocks(settings config.Settings) (err error) {
+	switch {
+	case len(os.Args) == 2:
+		return listLocks(settings)
+
+	case len(os.Args) == 3 && os.Args[2] == releaseStaleLocksCommand:
+		return releaseStaleLocks(settings)
+
+	case len(os.Args) == 4 && os.Args[2] == releaseLockCommand:
+		return releaseLock(os.Args[3])
+
+	default:
+		return &InvalidCommandLineArguments{}
+	}
+}
+
+func getLockStatuses(settings config.Settings) (lockStatuses []*apps.LockStatus, err error) {
+	appGallery := apps.NewAppGallery(settings.GetGalleryDirectory())
+
+	lockFilePaths, err := appGallery.GetLockFilePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	lockStatuses = []*apps.LockStatus{}
+
+	for _, lockFilePath := range lockFilePaths {
+		lockStatus, err := apps.InspectLock(lockFilePath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		lockStatuses = append(lockStatuses, lockStatus)
+	}
+
+	return lockStatuses, nil
+}
+
+func listLocks(settings config.Settings) (err error) {
+	lockStatuses, err := getLockStatuses(settings)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+
+	if len(lockStatuses) == 0 {
+		fmt.Println("No locks found")
+		return nil
+	}
+
+	for _, lockStatus := range lockStatuses {
+		printLockStatus(lockStatus)
+	}
+
+	return nil
+}
+
+func printLockStatus(lockStatus *apps.LockStatus) {
+	fmt.Println(lockStatus.LockFilePath)
+
+	if lockStatus.Owner != nil {
+		fmt.Printf("\tOwner: %v\n", lockStatus.Owner)
+		fmt.Printf("\tLast refreshed: %v\n", lockStatus.Owner.RefreshedAt.Local().Format(time.RFC3339))
+	} else {
+		fmt.Println("\tOwner: unknown")
+	}
+
+	switch {
+	case !lockStatus.Held:
+		fmt.Println("\tState: left over - not held by any process")
+
+	case lockStatus.IsStale():
+		fmt.Println("\tState: stale")
+
+	default:
+		fmt.Println("\tState: held")
+	}
+
+	fmt.Println()
+}
+
+func releaseStaleLocks(settings config.Settings) (err error) {
+	lockStatuses, err := getLockStatuses(settings)
+	if err != nil {
+		return err
+	}
+
+	releasedLocks := 0
+
+	for _, lockStatus := range lockStatuses {
+		if !lockStatus.IsStale() {
+			continue
+		}
+
+		released, err := apps.ReleaseStaleLock(lockStatus)
+		if err != nil {
+			return err
+		}
+
+		if !released {
+			fmt.Printf("Skipped, as its owner has changed: %v\n", lockStatus.LockFilePath)
+			continue
+		}
+
+		fmt.Printf("Released: %v\n", lockStatus.LockFilePath)
+		releasedLocks++
+	}
+
+	fmt.Printf("%v stale lock(s) released\n", releasedLocks)
+
+	return nil
+}
+
+/*
+releaseLock accepts either an app directory or the path of its lock file
+*/
+func releaseLock(target string) (err error) {
+	lockFilePath := target
+	if caravel.DirectoryExists(target) {
+		lockFilePath = apps.GetLockFilePath(target)
+	}
+
+	lockStatus, err := apps.InspectLock(lockFilePath)
+	if err != nil {
+		return err
+	}
+
+	printLockStatus(lockStatus)
+
+	released, err := apps.ForceReleaseLock(lockStatus)
+	if err != nil {
+		return err
+	}
+
+	if !released {
+		return fmt.Errorf("The lock has changed owner in the meantime: please, check it again")
+	}
+
+	fmt.Printf("Released: %v\n", lockFilePath)
+
+	return nil
+}
@@ -0,0 +1,84 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package verbs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/giancosta86/caravel"
+
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+const Migrate = "migrate"
+
+func DoMigrate() (err error) {
+	if len(os.Args) < 4 {
+		return &InvalidCommandLineArguments{}
+	}
+
+	sourceDescriptorPath := os.Args[2]
+	targetDescriptorPath := os.Args[3]
+
+	if caravel.FileExists(targetDescriptorPath) {
+		err = fmt.Errorf("The target descriptor already exists: '%v'", targetDescriptorPath)
+		fmt.Println()
+		fmt.Println(err)
+		return err
+	}
+
+	sourceBytes, err := ioutil.ReadFile(sourceDescriptorPath)
+	if err != nil {
+		return err
+	}
+
+	sourceBytes, err = descriptors.ConvertToJSON(sourceBytes, descriptors.GetEncodingFromFileName(sourceDescriptorPath))
+	if err != nil {
+		fmt.Println()
+		fmt.Println(err)
+		return err
+	}
+
+	ctx, stopInterruptionHandling := newInterruptibleContext()
+	defer stopInterruptionHandling()
+
+	migratedBytes, err := descriptors.MigrateToV3(ctx, sourceBytes)
+	if err != nil {
+		fmt.Println()
+		fmt.Println(err)
+		return err
+	}
+
+	log.Info("Saving the migrated descriptor to '%v'...", targetDescriptorPath)
+	err = ioutil.WriteFile(targetDescriptorPath, migratedBytes, 0644)
+	if err != nil {
+		return err
+	}
+	log.Notice("Migrated descriptor saved")
+
+	fmt.Println()
+	fmt.Printf("Descriptor migrated to V3: '%v'\n", targetDescriptorPath)
+
+	return nil
+}
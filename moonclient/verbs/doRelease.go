@@ -0,0 +1,75 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package verbs
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/giancosta86/moondeploy/v3/authoring"
+)
+
+const Release = "release"
+
+func DoRelease() (err error) {
+	if len(os.Args) < 5 {
+		return &InvalidCommandLineArguments{}
+	}
+
+	settings := authoring.ReleaseSettings{
+		SpecPath:        os.Args[2],
+		BuildDirectory:  os.Args[3],
+		OutputDirectory: os.Args[4],
+	}
+
+	if len(os.Args) > 5 {
+		settings.PreviousDescriptor = os.Args[5]
+	}
+
+	ctx, stopInterruptionHandling := newInterruptibleContext()
+	defer stopInterruptionHandling()
+
+	result, err := authoring.Release(ctx, settings)
+	if err != nil {
+		fmt.Println()
+		fmt.Println(err)
+		return err
+	}
+
+	fmt.Println()
+	fmt.Printf("Released %v: '%v'\n", result.Title, result.DescriptorPath)
+
+	fmt.Println()
+	fmt.Println("Changed packages:")
+	for _, packageName := range result.ChangedPackages {
+		fmt.Printf("* %v\n", packageName)
+	}
+
+	if len(result.UnchangedPackages) > 0 {
+		fmt.Println()
+		fmt.Println("Unchanged packages:")
+		for _, packageName := range result.UnchangedPackages {
+			fmt.Printf("* %v\n", packageName)
+		}
+	}
+
+	return nil
+}
@@ -23,6 +23,8 @@ package verbs
 import (
 	"os"
 
+	"github.com/giancosta86/moondeploy/v3/engine"
+
 	"github.com/giancosta86/moondeploy/v3/config"
 	"github.com/giancosta86/moondeploy/v3/launchers"
 )
@@ -30,5 +32,13 @@ import (
 func DoRun(launcher launchers.Launcher, settings config.Settings) (err error) {
 	bootDescriptorPath := os.Args[1]
 
-	return StartGUI(launcher, bootDescriptorPath)
+	ctx, stopInterruptionHandling := newInterruptibleContext()
+	defer stopInterruptionHandling()
+
+	err = StartGUI(ctx, launcher, bootDescriptorPath)
+	if err != nil && ctx.Err() != nil {
+		return &engine.ExecutionCanceled{}
+	}
+
+	return err
 }
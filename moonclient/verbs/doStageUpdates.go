@@ -0,0 +1,141 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package verbs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/giancosta86/moondeploy/v3/apps"
+	"github.com/giancosta86/moondeploy/v3/config"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/engine"
+	"github.com/giancosta86/moondeploy/v3/launchers"
+	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/networking"
+	"github.com/giancosta86/moondeploy/v3/ui/batchui"
+)
+
+const StageUpdates = "stage-updates"
+const UpdateDaemon = "update-daemon"
+
+/*
+DoStageUpdates stages the updates of all the apps in the gallery just once -
+for example, when run by a scheduler
+*/
+func DoStageUpdates(launcher launchers.Launcher, settings config.Settings) (err error) {
+	if len(os.Args) != 2 {
+		return &InvalidCommandLineArguments{}
+	}
+
+	ctx, stopInterruptionHandling := newInterruptibleContext()
+	defer stopInterruptionHandling()
+
+	err = stageGalleryUpdates(ctx, launcher, settings)
+	if ctx.Err() != nil {
+		return &engine.ExecutionCanceled{}
+	}
+
+	return err
+}
+
+/*
+DoUpdateDaemon stages the updates of all the apps in the gallery
+periodically, until the process is interrupted
+*/
+func DoUpdateDaemon(launcher launchers.Launcher, settings config.Settings) (err error) {
+	if len(os.Args) != 2 {
+		return &InvalidCommandLineArguments{}
+	}
+
+	ctx, stopInterruptionHandling := newInterruptibleContext()
+	defer stopInterruptionHandling()
+
+	checkInterval := time.Duration(settings.GetUpdateCheckIntervalInMinutes()) * time.Minute
+
+	for {
+		descriptors.ResetActualBaseURLCache()
+
+		err = stageGalleryUpdates(ctx, launcher, settings)
+		if err != nil && ctx.Err() == nil {
+			log.Warning(err.Error())
+		}
+
+		log.Notice("Next update check in %v", checkInterval)
+
+		err = networking.SleepWithContext(ctx, checkInterval)
+		if err != nil {
+			log.Notice("Update daemon stopped")
+			return nil
+		}
+	}
+}
+
+func stageGalleryUpdates(ctx context.Context, launcher launchers.Launcher, settings config.Settings) (err error) {
+	appGallery := apps.NewAppGallery(settings.GetGalleryDirectory())
+
+	descriptorPaths, err := appGallery.GetLocalDescriptorPaths()
+	if err != nil {
+		return err
+	}
+
+	failedStagings := 0
+
+	for _, descriptorPath := range descriptorPaths {
+		log.Info("Staging the update of: '%v'...", descriptorPath)
+		staged, err := stageUpdate(ctx, launcher, descriptorPath)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			log.Warning("Cannot stage the update of '%v': %v", descriptorPath, err)
+			failedStagings++
+			continue
+		}
+
+		if staged {
+			log.Notice("Update staged for '%v'", descriptorPath)
+		} else {
+			log.Notice("Nothing to stage for '%v'", descriptorPath)
+		}
+	}
+
+	if failedStagings > 0 {
+		return fmt.Errorf("%v app(s) could not be staged", failedStagings)
+	}
+
+	return nil
+}
+
+func stageUpdate(ctx context.Context, launcher launchers.Launcher, descriptorPath string) (staged bool, err error) {
+	bootDescriptor, err := descriptors.NewAppDescriptorFromPath(ctx, descriptorPath)
+	if err != nil {
+		return false, err
+	}
+
+	appEngine := engine.NewEngine(launcher, batchui.NewBatchUserInterface(), bootDescriptor)
+	defer appEngine.Close()
+
+	return appEngine.StageUpdate(ctx)
+}
@@ -0,0 +1,138 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package verbs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giancosta86/moondeploy/v3/config"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/engine"
+	"github.com/giancosta86/moondeploy/v3/launchers"
+	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/ui/batchui"
+)
+
+const Update = "update"
+
+func DoUpdate(launcher launchers.Launcher, settings config.Settings) (err error) {
+	descriptorPaths, err := getTargetDescriptorPaths(settings)
+	if err != nil {
+		return err
+	}
+
+	ctx, stopInterruptionHandling := newInterruptibleContext()
+	defer stopInterruptionHandling()
+
+	failedUpdates := 0
+
+	for _, descriptorPath := range descriptorPaths {
+		log.Info("Updating: '%v'...", descriptorPath)
+		updateOutcome, err := updateApp(ctx, launcher, descriptorPath)
+		if ctx.Err() != nil {
+			return &engine.ExecutionCanceled{}
+		}
+
+		fmt.Println()
+
+		if err != nil {
+			fmt.Printf("%v\n\tERROR: %v\n", descriptorPath, err)
+			failedUpdates++
+			continue
+		}
+
+		if !printUpdateOutcome(updateOutcome) {
+			failedUpdates++
+		}
+	}
+
+	if len(descriptorPaths) == 0 {
+		fmt.Println()
+		fmt.Println("No apps installed")
+	}
+
+	if failedUpdates > 0 {
+		return fmt.Errorf("%v app(s) could not be updated", failedUpdates)
+	}
+
+	return nil
+}
+
+/*
+updateApp performs the same steps as a launch - locking included -
+except starting the app
+*/
+func updateApp(ctx context.Context, launcher launchers.Launcher, descriptorPath string) (updateOutcome *engine.UpdateOutcome, err error) {
+	bootDescriptor, err := descriptors.NewAppDescriptorFromPath(ctx, descriptorPath)
+	if err != nil {
+		return nil, err
+	}
+
+	appEngine := engine.NewEngine(launcher, batchui.NewBatchUserInterface(), bootDescriptor)
+	defer appEngine.Close()
+
+	err = appEngine.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = appEngine.Update(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return appEngine.GetUpdateOutcome(), nil
+}
+
+/*
+printUpdateOutcome returns false if the update could not be performed,
+because the remote descriptor was unavailable
+*/
+func printUpdateOutcome(updateOutcome *engine.UpdateOutcome) (succeeded bool) {
+	switch {
+	case updateOutcome.RemoteDescriptorErr != nil:
+		fmt.Printf("%v: remote descriptor unavailable - keeping version %v\n\tERROR: %v\n",
+			updateOutcome.App,
+			updateOutcome.CurrentVersion,
+			updateOutcome.RemoteDescriptorErr)
+		return false
+
+	case updateOutcome.StagedVersion != nil:
+		fmt.Printf("%v: the app is running - update to version %v staged, to be applied at the next launch\n",
+			updateOutcome.App,
+			updateOutcome.StagedVersion)
+
+	case updateOutcome.PreviousVersion == nil:
+		fmt.Printf("%v: installed version %v\n", updateOutcome.App, updateOutcome.CurrentVersion)
+
+	case updateOutcome.IsUpdated():
+		fmt.Printf("%v: updated - %v -> %v\n",
+			updateOutcome.App,
+			updateOutcome.PreviousVersion,
+			updateOutcome.CurrentVersion)
+
+	default:
+		fmt.Printf("%v: up-to-date (%v)\n", updateOutcome.App, updateOutcome.CurrentVersion)
+	}
+
+	return true
+}
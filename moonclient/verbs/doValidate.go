@@ -0,0 +1,103 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package verbs
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+const Validate = "validate"
+
+func DoValidate() (err error) {
+	arguments, resolvingURLs := extractOption(ResolveURLsOption)
+	if len(arguments) != 1 {
+		return &InvalidCommandLineArguments{}
+	}
+
+	descriptorPath := arguments[0]
+
+	log.Info("Validating descriptor: '%v'...", descriptorPath)
+
+	descriptorBytes, err := ioutil.ReadFile(descriptorPath)
+	if err != nil {
+		return err
+	}
+
+	descriptorBytes, err = descriptors.ConvertToJSON(descriptorBytes, descriptors.GetEncodingFromFileName(descriptorPath))
+	if err != nil {
+		fmt.Println()
+		fmt.Println(err)
+		return err
+	}
+
+	schemaWarnings, err := descriptors.CheckSchema(descriptorBytes)
+	if len(schemaWarnings) > 0 {
+		fmt.Println()
+		fmt.Println("Warnings:")
+		for _, schemaWarning := range schemaWarnings {
+			fmt.Printf("* %v\n", schemaWarning)
+		}
+	}
+
+	if err == nil {
+		var descriptor descriptors.AppDescriptor
+
+		if resolvingURLs {
+			ctx, stopInterruptionHandling := newInterruptibleContext()
+			defer stopInterruptionHandling()
+
+			descriptor, err = descriptors.NewAppDescriptorFromBytes(ctx, descriptorBytes)
+		} else {
+			descriptor, err = descriptors.ParseAppDescriptorForPlatform(descriptorBytes, descriptors.GetCurrentPlatform())
+		}
+
+		if err == nil {
+			log.Notice("Descriptor validated")
+
+			fmt.Println()
+			fmt.Printf("The descriptor is valid: %v\n", descriptor.GetTitle())
+
+			if resolvingURLs {
+				printActualBaseURL(descriptor)
+			}
+
+			return nil
+		}
+	}
+
+	fmt.Println()
+	fmt.Println(err)
+	return err
+}
+
+func printActualBaseURL(descriptor descriptors.AppDescriptor) {
+	actualBaseURLErr := descriptor.GetActualBaseURLError()
+	if actualBaseURLErr != nil {
+		fmt.Printf("Cannot resolve the actual base URL: %v\n", actualBaseURLErr)
+		return
+	}
+
+	fmt.Printf("Actual base URL: %v\n", descriptor.GetActualBaseURL().Redacted())
+}
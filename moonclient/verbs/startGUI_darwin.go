@@ -21,11 +21,13 @@
 package verbs
 
 import (
+	"context"
+
 	"github.com/giancosta86/moondeploy/v3/launchers"
 
 	"github.com/giancosta86/moondeploy/moonclient/gui/bash"
 )
 
-func StartGUI(launcher launchers.Launcher, bootDescriptorPath string) (err error) {
-	return bash.StartGUI(launcher, bootDescriptorPath)
+func StartGUI(ctx context.Context, launcher launchers.Launcher, bootDescriptorPath string) (err error) {
+	return bash.StartGUI(ctx, launcher, bootDescriptorPath)
 }
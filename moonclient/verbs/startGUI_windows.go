@@ -21,11 +21,13 @@
 package verbs
 
 import (
+	"context"
+
 	"github.com/giancosta86/moondeploy/v3/launchers"
 
 	"github.com/giancosta86/moondeploy/moonclient/gui/gtk"
 )
 
-func StartGUI(launcher launchers.Launcher, bootDescriptorPath string) (err error) {
-	return gtk.StartGUI(launcher, bootDescriptorPath)
+func StartGUI(ctx context.Context, launcher launchers.Launcher, bootDescriptorPath string) (err error) {
+	return gtk.StartGUI(ctx, launcher, bootDescriptorPath)
 }
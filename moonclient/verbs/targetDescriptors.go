@@ -0,0 +1,46 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package verbs
+
+import (
+	"os"
+
+	"github.com/giancosta86/moondeploy/v3/apps"
+	"github.com/giancosta86/moondeploy/v3/config"
+)
+
+/*
+getTargetDescriptorPaths returns the descriptor passed on the command line,
+if any - otherwise, the local descriptors of all the apps in the gallery
+*/
+func getTargetDescriptorPaths(settings config.Settings) (descriptorPaths []string, err error) {
+	switch len(os.Args) {
+	case 2:
+		appGallery := apps.NewAppGallery(settings.GetGalleryDirectory())
+		return appGallery.GetLocalDescriptorPaths()
+
+	case 3:
+		return []string{os.Args[2]}, nil
+
+	default:
+		return nil, &InvalidCommandLineArguments{}
+	}
+}
@@ -21,25 +21,26 @@
 package apps
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 
 	"github.com/giancosta86/caravel"
 
-	"github.com/giancosta86/moondeploy/v3/config"
 	"github.com/giancosta86/moondeploy/v3/descriptors"
 	"github.com/giancosta86/moondeploy/v3/launchers"
 	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/networking"
 	"github.com/giancosta86/moondeploy/v3/ui"
 )
 
 const filesDirName = "files"
 const lockFileName = "App.lock"
+const localDescriptorExtension = ".moondeploy"
 
 type App struct {
 	Directory string
@@ -48,7 +49,8 @@ type App struct {
 
 	filesDirectory string
 
-	lockFile *os.File
+	lock         *directoryLock
+	instanceFile *os.File
 
 	localDescriptor       descriptors.AppDescriptor
 	localDescriptorCached bool
@@ -56,6 +58,7 @@ type App struct {
 
 	remoteDescriptor       descriptors.AppDescriptor
 	remoteDescriptorCached bool
+	remoteDescriptorErr    error
 
 	referenceDescriptor       descriptors.AppDescriptor
 	referenceDescriptorCached bool
@@ -74,7 +77,7 @@ func (app *App) CheckForConflictingLocalDescriptors() (err error) {
 	}
 
 	for _, appRootFile := range appRootFiles {
-		if !appRootFile.IsDir() && strings.HasSuffix(strings.ToLower(appRootFile.Name()), ".moondeploy") && appRootFile.Name() != bootDescriptor.GetDescriptorFileName() {
+		if isLocalDescriptorFile(appRootFile) && appRootFile.Name() != bootDescriptor.GetDescriptorFileName() {
 			return fmt.Errorf("Conflicting app descriptors: only one app descriptor can be deployed to a given path.\n\nPlease, contact the publisher of '%v'.", bootDescriptor.GetName())
 		}
 	}
@@ -99,7 +102,7 @@ func (app *App) EnsureDirectory() (err error) {
 	return nil
 }
 
-func (app *App) GetLocalDescriptor() (localDescriptor descriptors.AppDescriptor) {
+func (app *App) GetLocalDescriptor(ctx context.Context) (localDescriptor descriptors.AppDescriptor) {
 	if app.localDescriptorCached {
 		return app.localDescriptor
 	}
@@ -112,7 +115,7 @@ func (app *App) GetLocalDescriptor() (localDescriptor descriptors.AppDescriptor)
 	}
 
 	log.Notice("The local descriptor has been found! Opening it...")
-	localDescriptor, err := descriptors.NewAppDescriptorFromPath(app.localDescriptorPath)
+	localDescriptor, err := descriptors.NewAppDescriptorFromPath(ctx, app.localDescriptorPath)
 	if err != nil {
 		log.Warning(err.Error())
 		return nil
@@ -126,11 +129,24 @@ func (app *App) GetLocalDescriptor() (localDescriptor descriptors.AppDescriptor)
 	return localDescriptor
 }
 
+func (app *App) GetFilesDirectory() string {
+	return app.filesDirectory
+}
+
 func (app *App) GetLocalDescriptorPath() string {
 	return app.localDescriptorPath
 }
 
-func (app *App) GetRemoteDescriptor() (remoteDescriptor descriptors.AppDescriptor) {
+/*
+GetRemoteDescriptorError returns the reason why GetRemoteDescriptor()
+returned nil - or nil if it succeeded, or if the update check was skipped
+as requested by the local descriptor
+*/
+func (app *App) GetRemoteDescriptorError() error {
+	return app.remoteDescriptorErr
+}
+
+func (app *App) GetRemoteDescriptor(ctx context.Context) (remoteDescriptor descriptors.AppDescriptor) {
 	if app.remoteDescriptorCached {
 		return app.remoteDescriptor
 	}
@@ -138,10 +154,9 @@ func (app *App) GetRemoteDescriptor() (remoteDescriptor descriptors.AppDescripto
 	app.remoteDescriptorCached = true
 
 	bootDescriptor := app.bootDescriptor
-	localDescriptor := app.GetLocalDescriptor()
+	localDescriptor := app.GetLocalDescriptor(ctx)
 
-	var remoteDescriptorURL *url.URL
-	var err error
+	sourceDescriptor := bootDescriptor
 
 	if localDescriptor != nil {
 		if localDescriptor.IsSkipUpdateCheck() {
@@ -149,30 +164,49 @@ func (app *App) GetRemoteDescriptor() (remoteDescriptor descriptors.AppDescripto
 			return nil
 		}
 
-		remoteDescriptorURL, err = localDescriptor.GetRemoteFileURL(localDescriptor.GetDescriptorFileName())
-	} else {
-		remoteDescriptorURL, err = bootDescriptor.GetRemoteFileURL(bootDescriptor.GetDescriptorFileName())
+		sourceDescriptor = localDescriptor
 	}
 
+	err := sourceDescriptor.GetActualBaseURLError()
+	if err != nil {
+		log.Warning("Cannot look for the remote descriptor: %v", err)
+		app.remoteDescriptorErr = err
+		return nil
+	}
+
+	remoteDescriptorURLs, err := sourceDescriptor.GetRemoteFileURLs(sourceDescriptor.GetDescriptorFileName())
 	if err != nil {
 		log.Warning(err.Error())
+		app.remoteDescriptorErr = err
 		return nil
 	}
 
-	log.Notice("The remote descriptor's URL is: %v", remoteDescriptorURL)
+	log.Notice("The remote descriptor's URL is: %v", remoteDescriptorURLs[0].Redacted())
 
 	log.Info("Retrieving the remote descriptor...")
-	remoteDescriptorBytes, err := caravel.RetrieveFromURL(remoteDescriptorURL)
+	var remoteDescriptorBytes []byte
+	err = networking.RetrieveFromMirrors(ctx, remoteDescriptorURLs, func(remoteDescriptorURL *url.URL, attempt networking.RetrievalAttempt) (err error) {
+		remoteDescriptorBytes, err = networking.RetrieveFromURL(ctx, remoteDescriptorURL)
+		return err
+	})
 	if err != nil {
 		log.Warning(err.Error())
+		app.remoteDescriptorErr = err
+
+		if ctx.Err() == nil &&
+			sourceDescriptor.GetActualBaseURL().String() != sourceDescriptor.GetDeclaredBaseURL().String() {
+			descriptors.InvalidateActualBaseURL(sourceDescriptor.GetDeclaredBaseURL())
+		}
+
 		return nil
 	}
 	log.Notice("Remote descriptor retrieved")
 
 	log.Info("Opening the remote descriptor...")
-	remoteDescriptor, err = descriptors.NewAppDescriptorFromBytes(remoteDescriptorBytes)
+	remoteDescriptor, err = descriptors.NewAppDescriptorFromBytes(ctx, remoteDescriptorBytes)
 	if err != nil {
 		log.Warning(err.Error())
+		app.remoteDescriptorErr = err
 		return nil
 	}
 	log.Notice("Remote descriptor ready")
@@ -184,17 +218,26 @@ func (app *App) GetRemoteDescriptor() (remoteDescriptor descriptors.AppDescripto
 	return remoteDescriptor
 }
 
-func (app *App) GetReferenceDescriptor() (referenceDescriptor descriptors.AppDescriptor, err error) {
+func (app *App) GetReferenceDescriptor(ctx context.Context) (referenceDescriptor descriptors.AppDescriptor, err error) {
 	if app.referenceDescriptorCached {
 		return app.referenceDescriptor, nil
 	}
 
 	app.referenceDescriptorCached = true
 
-	localDescriptor := app.GetLocalDescriptor()
-	remoteDescriptor := app.GetRemoteDescriptor()
+	localDescriptor := app.GetLocalDescriptor(ctx)
+	remoteDescriptor := app.GetRemoteDescriptor(ctx)
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 
 	if remoteDescriptor == nil && localDescriptor == nil {
+		actualBaseURLErr := app.bootDescriptor.GetActualBaseURLError()
+		if actualBaseURLErr != nil {
+			return nil, actualBaseURLErr
+		}
+
 		return nil, fmt.Errorf("Cannot run the application: it is not installed and cannot be downloaded")
 	}
 
@@ -235,8 +278,8 @@ func (app *App) PrepareCommand(commandLine []string) (command *exec.Cmd) {
 	return exec.Command(commandLine[0], commandLine[1:]...)
 }
 
-func (app *App) SaveReferenceDescriptor() (referenceDescriptorSaved bool) {
-	referenceDescriptor, err := app.GetReferenceDescriptor()
+func (app *App) SaveReferenceDescriptor(ctx context.Context) (referenceDescriptorSaved bool) {
+	referenceDescriptor, err := app.GetReferenceDescriptor(ctx)
 	if err != nil {
 		log.Warning("Cannot save the reference descriptor: %v", err)
 		return false
@@ -259,35 +302,7 @@ func (app *App) SaveReferenceDescriptor() (referenceDescriptorSaved bool) {
 	return true
 }
 
-func (app *App) Launch(command *exec.Cmd, settings config.Settings, userInterface ui.UserInterface) (err error) {
-	log.Info("Starting the app...")
-
-	log.Debug("Hiding the user interface...")
-	userInterface.Hide()
-	log.Notice("User interface hidden")
-
-	if settings.IsSkipAppOutput() {
-		return command.Run()
-	}
-	var outputBytes []byte
-	outputBytes, err = command.CombinedOutput()
-
-	if outputBytes != nil && len(outputBytes) > 0 {
-		log.Info("------------------------------")
-		log.Info(string(outputBytes))
-		log.Info("------------------------------")
-	}
-
-	return err
-}
-
-func (app *App) GetActualIconPath(launcher launchers.Launcher) string {
-	referenceDescriptor, err := app.GetReferenceDescriptor()
-	if err != nil {
-		log.Warning("Error while retrieving the reference descriptor: %v", err)
-		return launcher.GetIconPath()
-	}
-
+func (app *App) GetActualIconPath(launcher launchers.Launcher, referenceDescriptor descriptors.AppDescriptor) string {
 	referenceIconPath := referenceDescriptor.GetIconPath()
 
 	if referenceIconPath != "" {
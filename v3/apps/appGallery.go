@@ -21,6 +21,8 @@
 package apps
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -75,3 +77,122 @@ func (appGallery *AppGallery) resolveAppDir(bootDescriptor descriptors.AppDescri
 
 	return appDir
 }
+
+/*
+GetLocalDescriptorPaths returns the paths of the local descriptors of all
+the apps installed in the gallery, sorted by path
+*/
+func (appGallery *AppGallery) GetLocalDescriptorPaths() (descriptorPaths []string, err error) {
+	descriptorPaths = []string{}
+
+	err = appGallery.walkDirectories(func(directory string, directoryFiles []os.FileInfo) {
+		for _, directoryFile := range directoryFiles {
+			if isLocalDescriptorFile(directoryFile) {
+				descriptorPaths = append(descriptorPaths, filepath.Join(directory, directoryFile.Name()))
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return descriptorPaths, nil
+}
+
+/*
+GetLockFilePaths returns the paths of the lock files in the gallery,
+sorted by path
+*/
+func (appGallery *AppGallery) GetLockFilePaths() (lockFilePaths []string, err error) {
+	lockFilePaths = []string{}
+
+	err = appGallery.walkDirectories(func(directory string, directoryFiles []os.FileInfo) {
+		for _, directoryFile := range directoryFiles {
+			if isLockFile(directoryFile) {
+				lockFilePaths = append(lockFilePaths, filepath.Join(directory, directoryFile.Name()))
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return lockFilePaths, nil
+}
+
+/*
+walkDirectories visits, in lexical order, the directories of the gallery,
+skipping the directories managed by the apps - such as "files"
+*/
+func (appGallery *AppGallery) walkDirectories(visitor func(directory string, directoryFiles []os.FileInfo)) (err error) {
+	if _, err := os.Stat(appGallery.Directory); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(appGallery.Directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		if isAppSubdirectoryName(info.Name()) && path != appGallery.Directory {
+			isAppSubdirectory, err := isAppDirectory(filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+
+			if isAppSubdirectory {
+				return filepath.SkipDir
+			}
+		}
+
+		directoryFiles, err := ioutil.ReadDir(path)
+		if err != nil {
+			return err
+		}
+
+		visitor(path, directoryFiles)
+
+		return nil
+	})
+}
+
+/*
+isAppDirectory returns true if the directory contains a local descriptor or
+a lock file - as it happens during the first installation
+*/
+func isAppDirectory(directory string) (bool, error) {
+	directoryFiles, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return false, err
+	}
+
+	for _, directoryFile := range directoryFiles {
+		if isLocalDescriptorFile(directoryFile) || isLockFile(directoryFile) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func isLocalDescriptorFile(fileInfo os.FileInfo) bool {
+	return !fileInfo.IsDir() && strings.HasSuffix(strings.ToLower(fileInfo.Name()), localDescriptorExtension)
+}
+
+func isLockFile(fileInfo os.FileInfo) bool {
+	return !fileInfo.IsDir() && fileInfo.Name() == lockFileName
+}
+
+func isAppSubdirectoryName(directoryName string) bool {
+	switch directoryName {
+	case filesDirName, stagingDirName, replacedFilesDirName, instancesDirName, outputLogsDirName:
+		return true
+
+	default:
+		return isPreparationDirectoryName(directoryName)
+	}
+}
@@ -0,0 +1,315 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package apps
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+type archiveFormat int
+
+const (
+	unknownArchive archiveFormat = iota
+	zipArchive
+	tarArchive
+	tarGzArchive
+	tarXzArchive
+	tarZstArchive
+)
+
+func (format archiveFormat) String() string {
+	switch format {
+	case zipArchive:
+		return "zip"
+	case tarArchive:
+		return "tar"
+	case tarGzArchive:
+		return "tar.gz"
+	case tarXzArchive:
+		return "tar.xz"
+	case tarZstArchive:
+		return "tar.zst"
+	default:
+		return "unknown"
+	}
+}
+
+var archiveExtensions = []struct {
+	extension string
+	format    archiveFormat
+}{
+	{".zip", zipArchive},
+	{".tar.gz", tarGzArchive},
+	{".tgz", tarGzArchive},
+	{".tar.xz", tarXzArchive},
+	{".txz", tarXzArchive},
+	{".tar.zst", tarZstArchive},
+	{".tzst", tarZstArchive},
+	{".tar", tarArchive},
+}
+
+var zipMagic = []byte("PK\x03\x04")
+var gzipMagic = []byte{0x1f, 0x8b}
+var xzMagic = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+var tarMagic = []byte("ustar")
+
+const tarMagicOffset = 257
+
+const maxSymlinkTargetLength = 4096
+
+/*
+detectArchiveFormat infers the format from the extension of the package name,
+falling back to the magic bytes of the package content
+*/
+func detectArchiveFormat(packageName string, packagePath string) (format archiveFormat, err error) {
+	lowercasePackageName := strings.ToLower(packageName)
+
+	for _, archiveExtension := range archiveExtensions {
+		if strings.HasSuffix(lowercasePackageName, archiveExtension.extension) {
+			return archiveExtension.format, nil
+		}
+	}
+
+	log.Debug("Cannot infer the archive format from the package name - now reading its header...")
+
+	packageFile, err := os.Open(packagePath)
+	if err != nil {
+		return unknownArchive, err
+	}
+	defer packageFile.Close()
+
+	header := make([]byte, tarMagicOffset+len(tarMagic))
+	headerSize, err := io.ReadFull(packageFile, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return unknownArchive, err
+	}
+	header = header[:headerSize]
+
+	switch {
+	case bytes.HasPrefix(header, zipMagic):
+		return zipArchive, nil
+	case bytes.HasPrefix(header, gzipMagic):
+		return tarGzArchive, nil
+	case bytes.HasPrefix(header, xzMagic):
+		return tarXzArchive, nil
+	case bytes.HasPrefix(header, zstdMagic):
+		return tarZstArchive, nil
+	case len(header) >= tarMagicOffset+len(tarMagic) && bytes.Equal(header[tarMagicOffset:], tarMagic):
+		return tarArchive, nil
+	}
+
+	return unknownArchive, fmt.Errorf("Unsupported archive format for package '%v'", packageName)
+}
+
+/*
+extractPackage extracts the given package archive into the target directory,
+via the hardened extraction layer; it stops as soon as the context is done
+*/
+func extractPackage(ctx context.Context, packageName string, packagePath string, targetDirectory string, skipLevels int, limits ExtractionLimits) (err error) {
+	format, err := detectArchiveFormat(packageName, packagePath)
+	if err != nil {
+		return &ExtractionError{
+			PackageName: packageName,
+			Reason:      err.Error(),
+		}
+	}
+	log.Notice("Package format: %v", format)
+
+	extractor, err := newPackageExtractor(ctx, packageName, targetDirectory, skipLevels, limits)
+	if err != nil {
+		return err
+	}
+
+	if format == zipArchive {
+		return extractZip(packagePath, extractor)
+	}
+
+	packageFile, err := os.Open(packagePath)
+	if err != nil {
+		return err
+	}
+	defer packageFile.Close()
+
+	tarStream, closeTarStream, err := openTarStream(format, packageFile)
+	if err != nil {
+		return err
+	}
+	defer closeTarStream()
+
+	return extractTar(tarStream, extractor)
+}
+
+/*
+openTarStream returns a reader decompressing the given tar-based package,
+as well as a function releasing the decompressor
+*/
+func openTarStream(format archiveFormat, packageFile io.Reader) (tarStream io.Reader, closeTarStream func(), err error) {
+	switch format {
+	case tarArchive:
+		return packageFile, func() {}, nil
+
+	case tarGzArchive:
+		gzipReader, err := gzip.NewReader(packageFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return gzipReader, func() { gzipReader.Close() }, nil
+
+	case tarXzArchive:
+		xzReader, err := xz.NewReader(packageFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return xzReader, func() {}, nil
+
+	case tarZstArchive:
+		zstdDecoder, err := zstd.NewReader(packageFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return zstdDecoder, zstdDecoder.Close, nil
+
+	default:
+		return nil, nil, fmt.Errorf("Not a tar-based archive format: %v", format)
+	}
+}
+
+func extractTar(tarStream io.Reader, extractor *packageExtractor) (err error) {
+	tarReader := tar.NewReader(tarStream)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		entry := &archiveEntry{
+			name:       header.Name,
+			mode:       os.FileMode(header.Mode).Perm(),
+			linkTarget: header.Linkname,
+			content:    tarReader,
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			entry.entryType = directoryEntry
+		case tar.TypeReg:
+			entry.entryType = regularFileEntry
+		case tar.TypeSymlink:
+			entry.entryType = symlinkEntry
+		case tar.TypeLink:
+			entry.entryType = hardLinkEntry
+		default:
+			entry.entryType = unsupportedEntry
+		}
+
+		err = extractor.extract(entry)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(packagePath string, extractor *packageExtractor) (err error) {
+	zipReader, err := zip.OpenReader(packagePath)
+	if err != nil {
+		return err
+	}
+	defer zipReader.Close()
+
+	if extractor.limits.MaxEntries > 0 && len(zipReader.File) > extractor.limits.MaxEntries {
+		return extractor.newError("", "the package has more than %v entries", extractor.limits.MaxEntries)
+	}
+
+	for _, zipEntry := range zipReader.File {
+		err = extractZipEntry(zipEntry, extractor)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractZipEntry(zipEntry *zip.File, extractor *packageExtractor) (err error) {
+	entryMode := zipEntry.Mode()
+
+	entry := &archiveEntry{
+		name: zipEntry.Name,
+		mode: entryMode.Perm(),
+	}
+
+	switch {
+	case entryMode.IsDir():
+		entry.entryType = directoryEntry
+		return extractor.extract(entry)
+
+	case entryMode&os.ModeSymlink != 0:
+		entry.entryType = symlinkEntry
+
+	case entryMode.IsRegular():
+		entry.entryType = regularFileEntry
+
+	default:
+		entry.entryType = unsupportedEntry
+		return extractor.extract(entry)
+	}
+
+	entryReader, err := zipEntry.Open()
+	if err != nil {
+		return err
+	}
+	defer entryReader.Close()
+
+	if entry.entryType == symlinkEntry {
+		linkTargetBytes, err := ioutil.ReadAll(io.LimitReader(entryReader, maxSymlinkTargetLength))
+		if err != nil {
+			return err
+		}
+
+		entry.linkTarget = string(linkTargetBytes)
+	} else {
+		entry.content = entryReader
+	}
+
+	return extractor.extract(entry)
+}
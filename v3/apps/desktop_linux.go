@@ -72,7 +72,7 @@ func (app *App) CreateDesktopShortcut(launcher launchers.Launcher, referenceDesc
 		}
 	}()
 
-	actualIconPath := app.GetActualIconPath(launcher)
+	actualIconPath := app.GetActualIconPath(launcher, referenceDescriptor)
 
 	shortcutContent := fmt.Sprintf(linuxShortcutContent,
 		referenceDescriptor.GetName(),
@@ -84,7 +84,7 @@ func (app *App) CreateDesktopShortcut(launcher launchers.Launcher, referenceDesc
 	}()
 
 	log.Debug("Temp script file created: %v", tempFilePath)
-	actualIconPath := app.GetActualIconPath(launcher)
+	actualIconPath := app.GetActualIconPath(launcher, referenceDescriptor)
 	log.Debug("Actual icon path: '%v'", actualIconPath)
 
 	workingDirectory := filepath.Dir(app.GetLocalDescriptorPath())
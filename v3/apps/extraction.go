@@ -0,0 +1,427 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package apps
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+/*
+ExtractionError is returned when a package contains an entry that cannot be
+safely extracted - for example because of path traversal or absolute paths,
+symbolic links pointing outside the app or archives exceeding the limits
+*/
+type ExtractionError struct {
+	PackageName string
+	EntryName   string
+	Reason      string
+}
+
+func (err *ExtractionError) Error() string {
+	if err.EntryName == "" {
+		return fmt.Sprintf("Cannot extract package '%v': %v", err.PackageName, err.Reason)
+	}
+
+	return fmt.Sprintf("Cannot extract entry '%v' of package '%v': %v",
+		err.EntryName,
+		err.PackageName,
+		err.Reason)
+}
+
+/*
+ExtractionLimits constrain the content of a package; a zero value means "no limit"
+*/
+type ExtractionLimits struct {
+	MaxEntries      int
+	MaxExpandedSize int64
+}
+
+/*
+contextReader stops reading - so that large entries do not delay
+the cancellation - as soon as its context is done
+*/
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (reader *contextReader) Read(buffer []byte) (readBytes int, err error) {
+	if reader.ctx.Err() != nil {
+		return 0, reader.ctx.Err()
+	}
+
+	return reader.reader.Read(buffer)
+}
+
+type archiveEntryType int
+
+const (
+	regularFileEntry archiveEntryType = iota
+	directoryEntry
+	symlinkEntry
+	hardLinkEntry
+	unsupportedEntry
+)
+
+type archiveEntry struct {
+	name       string
+	entryType  archiveEntryType
+	mode       os.FileMode
+	linkTarget string
+	content    io.Reader
+}
+
+/*
+packageExtractor is the hardened layer every package archive goes through:
+entries are validated, their paths are resolved within the target directory
+and the limits are enforced on the bytes actually written
+*/
+type packageExtractor struct {
+	ctx context.Context
+
+	packageName     string
+	targetDirectory string
+	realTarget      string
+	skipLevels      int
+	limits          ExtractionLimits
+
+	entryCount   int
+	expandedSize int64
+}
+
+func newPackageExtractor(ctx context.Context, packageName string, targetDirectory string, skipLevels int, limits ExtractionLimits) (extractor *packageExtractor, err error) {
+	realTarget, err := filepath.EvalSymlinks(targetDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	return &packageExtractor{
+		ctx:             ctx,
+		packageName:     packageName,
+		targetDirectory: filepath.Clean(targetDirectory),
+		realTarget:      realTarget,
+		skipLevels:      skipLevels,
+		limits:          limits,
+	}, nil
+}
+
+func (extractor *packageExtractor) newError(entryName string, reasonFormat string, args ...interface{}) *ExtractionError {
+	return &ExtractionError{
+		PackageName: extractor.packageName,
+		EntryName:   entryName,
+		Reason:      fmt.Sprintf(reasonFormat, args...),
+	}
+}
+
+func (extractor *packageExtractor) extract(entry *archiveEntry) (err error) {
+	if extractor.ctx.Err() != nil {
+		return extractor.ctx.Err()
+	}
+
+	extractor.entryCount++
+	if extractor.limits.MaxEntries > 0 && extractor.entryCount > extractor.limits.MaxEntries {
+		return extractor.newError("", "the package has more than %v entries", extractor.limits.MaxEntries)
+	}
+
+	err = extractor.validateEntryName(entry.name)
+	if err != nil {
+		return err
+	}
+
+	relativePath, skipped := skipPathLevels(entry.name, extractor.skipLevels)
+	if skipped {
+		return nil
+	}
+
+	entryPath, err := extractor.resolveEntryPath(entry.name, relativePath)
+	if err != nil {
+		return err
+	}
+
+	switch entry.entryType {
+	case directoryEntry:
+		return os.MkdirAll(entryPath, entry.mode.Perm()|0700)
+
+	case regularFileEntry:
+		return extractor.extractFile(entry, entryPath)
+
+	case symlinkEntry:
+		return extractor.extractSymlink(entry, entryPath)
+
+	case hardLinkEntry:
+		return extractor.extractHardLink(entry, entryPath)
+
+	default:
+		log.Debug("Skipping unsupported archive entry: '%v'", entry.name)
+		return nil
+	}
+}
+
+func (extractor *packageExtractor) extractFile(entry *archiveEntry, entryPath string) (err error) {
+	err = os.MkdirAll(filepath.Dir(entryPath), 0700)
+	if err != nil {
+		return err
+	}
+
+	os.Remove(entryPath)
+
+	entryMode := entry.mode.Perm()
+	if entryMode == 0 {
+		entryMode = 0600
+	}
+
+	entryFile, err := os.OpenFile(entryPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, entryMode)
+	if err != nil {
+		return err
+	}
+	defer entryFile.Close()
+
+	var content io.Reader = &contextReader{
+		ctx:    extractor.ctx,
+		reader: entry.content,
+	}
+	if extractor.limits.MaxExpandedSize > 0 {
+		remainingSize := extractor.limits.MaxExpandedSize - extractor.expandedSize
+		content = io.LimitReader(content, remainingSize+1)
+	}
+
+	writtenSize, err := io.Copy(entryFile, content)
+	extractor.expandedSize += writtenSize
+
+	if extractor.limits.MaxExpandedSize > 0 && extractor.expandedSize > extractor.limits.MaxExpandedSize {
+		return extractor.newError(entry.name, "the expanded package exceeds %v bytes", extractor.limits.MaxExpandedSize)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return entryFile.Chmod(entryMode)
+}
+
+func (extractor *packageExtractor) extractSymlink(entry *archiveEntry, entryPath string) (err error) {
+	linkTarget := entry.linkTarget
+
+	if isAbsoluteEntryName(linkTarget) {
+		return extractor.newError(entry.name, "symbolic links cannot have absolute targets: '%v'", linkTarget)
+	}
+
+	err = os.MkdirAll(filepath.Dir(entryPath), 0700)
+	if err != nil {
+		return err
+	}
+
+	os.Remove(entryPath)
+
+	realParent, err := filepath.EvalSymlinks(filepath.Dir(entryPath))
+	if err != nil {
+		return err
+	}
+
+	err = extractor.checkLinkTarget(entry.name, realParent, linkTarget)
+	if err != nil {
+		return err
+	}
+
+	return os.Symlink(linkTarget, entryPath)
+}
+
+/*
+checkLinkTarget ensures that a symbolic link points within the app, however the OS resolves it.
+
+".." is only accepted at the beginning of the target, where it climbs the real directories
+containing the link: after a named component - which might be a symbolic link, even one
+extracted later - it could lead anywhere, so it is refused
+*/
+func (extractor *packageExtractor) checkLinkTarget(entryName string, realParent string, linkTarget string) (err error) {
+	currentPath := realParent
+	namedComponentFound := false
+
+	for _, component := range strings.FieldsFunc(linkTarget, isPathSeparator) {
+		switch component {
+		case ".":
+			continue
+
+		case "..":
+			if namedComponentFound {
+				return extractor.newError(entryName, "the symbolic link can only contain '..' at the beginning: '%v'", linkTarget)
+			}
+
+			currentPath = filepath.Dir(currentPath)
+
+		default:
+			namedComponentFound = true
+			currentPath = filepath.Join(currentPath, component)
+		}
+
+		if !isWithinDirectory(extractor.realTarget, currentPath) {
+			return extractor.newError(entryName, "the symbolic link points outside the app: '%v'", linkTarget)
+		}
+	}
+
+	return nil
+}
+
+func (extractor *packageExtractor) extractHardLink(entry *archiveEntry, entryPath string) (err error) {
+	err = extractor.validateEntryName(entry.linkTarget)
+	if err != nil {
+		return err
+	}
+
+	relativeLinkTarget, skipped := skipPathLevels(entry.linkTarget, extractor.skipLevels)
+	if skipped {
+		return extractor.newError(entry.name, "the hard link points to a skipped entry: '%v'", entry.linkTarget)
+	}
+
+	linkTargetPath, err := extractor.resolveEntryPath(entry.name, relativeLinkTarget)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(entryPath), 0700)
+	if err != nil {
+		return err
+	}
+
+	os.Remove(entryPath)
+
+	return os.Link(linkTargetPath, entryPath)
+}
+
+func (extractor *packageExtractor) validateEntryName(entryName string) error {
+	if isAbsoluteEntryName(entryName) {
+		return extractor.newError(entryName, "absolute paths are not allowed")
+	}
+
+	for _, component := range strings.FieldsFunc(entryName, isPathSeparator) {
+		if component == ".." {
+			return extractor.newError(entryName, "path traversal is not allowed")
+		}
+	}
+
+	return nil
+}
+
+/*
+resolveEntryPath maps the relative path of an entry to its path within the
+target directory, also checking that no symbolic link already extracted
+redirects it outside of the app
+*/
+func (extractor *packageExtractor) resolveEntryPath(entryName string, relativePath string) (entryPath string, err error) {
+	entryPath = filepath.Join(extractor.targetDirectory, filepath.FromSlash(relativePath))
+
+	if !isWithinDirectory(extractor.targetDirectory, entryPath) {
+		return "", extractor.newError(entryName, "the entry escapes the files directory")
+	}
+
+	realParent, err := extractor.getRealParent(entryPath)
+	if err != nil {
+		return "", err
+	}
+
+	if !isWithinDirectory(extractor.realTarget, realParent) {
+		return "", extractor.newError(entryName, "the entry is redirected outside the app by a symbolic link")
+	}
+
+	return entryPath, nil
+}
+
+/*
+getRealParent resolves the symbolic links in the closest existing ancestor
+of the given path, appending the components not created yet
+*/
+func (extractor *packageExtractor) getRealParent(entryPath string) (realParent string, err error) {
+	existingAncestor := filepath.Dir(entryPath)
+	missingComponents := []string{}
+
+	for {
+		realAncestor, err := filepath.EvalSymlinks(existingAncestor)
+		if err == nil {
+			return filepath.Join(append([]string{realAncestor}, missingComponents...)...), nil
+		}
+
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		if !isWithinDirectory(extractor.targetDirectory, existingAncestor) || existingAncestor == extractor.targetDirectory {
+			return "", err
+		}
+
+		missingComponents = append([]string{filepath.Base(existingAncestor)}, missingComponents...)
+		existingAncestor = filepath.Dir(existingAncestor)
+	}
+}
+
+func isAbsoluteEntryName(entryName string) bool {
+	return strings.HasPrefix(entryName, "/") ||
+		strings.HasPrefix(entryName, "\\") ||
+		filepath.IsAbs(entryName) ||
+		filepath.VolumeName(entryName) != ""
+}
+
+func isPathSeparator(character rune) bool {
+	return character == '/' || character == '\\'
+}
+
+/*
+skipPathLevels removes the given number of leading components from the
+slash-separated entry name; skipped is true if nothing is left
+*/
+func skipPathLevels(entryName string, skipLevels int) (relativePath string, skipped bool) {
+	cleanComponents := getCleanPathComponents(entryName)
+
+	if len(cleanComponents) <= skipLevels {
+		return "", true
+	}
+
+	return strings.Join(cleanComponents[skipLevels:], "/"), false
+}
+
+func getCleanPathComponents(entryName string) []string {
+	cleanComponents := []string{}
+
+	for _, component := range strings.FieldsFunc(entryName, isPathSeparator) {
+		if component != "." {
+			cleanComponents = append(cleanComponents, component)
+		}
+	}
+
+	return cleanComponents
+}
+
+func isWithinDirectory(directory string, candidatePath string) bool {
+	relativePath, err := filepath.Rel(filepath.Clean(directory), filepath.Clean(candidatePath))
+	if err != nil {
+		return false
+	}
+
+	return relativePath != ".." &&
+		!strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) &&
+		!filepath.IsAbs(relativePath)
+}
@@ -0,0 +1,472 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package apps
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type testArchiveEntry struct {
+	name       string
+	entryType  archiveEntryType
+	content    string
+	linkTarget string
+}
+
+type testArchiveFormat struct {
+	extension string
+	write     func(t *testing.T, packagePath string, entries []testArchiveEntry)
+}
+
+var testArchiveFormats = []testArchiveFormat{
+	{".zip", writeTestZip},
+	{".tar", writeTestTar},
+}
+
+const outsideFileName = "outside.txt"
+
+/*
+extractionCase describes a package that must either be extracted or be rejected
+with an ExtractionError; in both cases, nothing must be written outside the app.
+
+existingSymlinks are created in the target directory - pointing to the parent
+of the target directory - before extracting the package
+*/
+type extractionCase struct {
+	name             string
+	entries          []testArchiveEntry
+	limits           ExtractionLimits
+	existingSymlinks []string
+
+	expectingError bool
+	creatingLinks  bool
+}
+
+var extractionCases = []extractionCase{
+	{
+		name: "Valid package",
+		entries: []testArchiveEntry{
+			{name: "dir/", entryType: directoryEntry},
+			{name: "dir/file.txt", entryType: regularFileEntry, content: "Hello"},
+			{name: "dir/link", entryType: symlinkEntry, linkTarget: "file.txt"},
+			{name: "topLink", entryType: symlinkEntry, linkTarget: "dir/file.txt"},
+			{name: "dir/upLink", entryType: symlinkEntry, linkTarget: "../topLink"},
+		},
+		creatingLinks: true,
+	},
+	{
+		name: "Path traversal",
+		entries: []testArchiveEntry{
+			{name: "../escaped.txt", entryType: regularFileEntry, content: "Escaped"},
+		},
+		expectingError: true,
+	},
+	{
+		name: "Nested path traversal",
+		entries: []testArchiveEntry{
+			{name: "dir/../../escaped.txt", entryType: regularFileEntry, content: "Escaped"},
+		},
+		expectingError: true,
+	},
+	{
+		name: "Backslash path traversal",
+		entries: []testArchiveEntry{
+			{name: "dir\\..\\..\\escaped.txt", entryType: regularFileEntry, content: "Escaped"},
+		},
+		expectingError: true,
+	},
+	{
+		name: "Absolute entry",
+		entries: []testArchiveEntry{
+			{name: "/escaped.txt", entryType: regularFileEntry, content: "Escaped"},
+		},
+		expectingError: true,
+	},
+	{
+		name: "Symlink outside the app",
+		entries: []testArchiveEntry{
+			{name: "link", entryType: symlinkEntry, linkTarget: "../" + outsideFileName},
+		},
+		expectingError: true,
+	},
+	{
+		name: "Symlink with absolute target",
+		entries: []testArchiveEntry{
+			{name: "link", entryType: symlinkEntry, linkTarget: "/etc/passwd"},
+		},
+		expectingError: true,
+	},
+	{
+		name: "Symlink chain escaping the app",
+		entries: []testArchiveEntry{
+			{name: "inner/", entryType: directoryEntry},
+			{name: "inner/up", entryType: symlinkEntry, linkTarget: ".."},
+			{name: "inner/up/escape", entryType: symlinkEntry, linkTarget: ".."},
+		},
+		expectingError: true,
+		creatingLinks:  true,
+	},
+	{
+		name: "Symlink going up through another symlink",
+		entries: []testArchiveEntry{
+			{name: "x", entryType: symlinkEntry, linkTarget: "."},
+			{name: "y", entryType: symlinkEntry, linkTarget: "x/.."},
+		},
+		expectingError: true,
+		creatingLinks:  true,
+	},
+	{
+		name: "Symlink going up through a longer symlink chain",
+		entries: []testArchiveEntry{
+			{name: "x", entryType: symlinkEntry, linkTarget: "."},
+			{name: "y", entryType: symlinkEntry, linkTarget: "x/x/x/../.."},
+		},
+		expectingError: true,
+		creatingLinks:  true,
+	},
+	{
+		name: "Symlink going up through a symlink extracted later",
+		entries: []testArchiveEntry{
+			{name: "y", entryType: symlinkEntry, linkTarget: "x/.."},
+			{name: "x", entryType: symlinkEntry, linkTarget: "."},
+		},
+		expectingError: true,
+		creatingLinks:  true,
+	},
+	{
+		name: "File written through an existing symlink",
+		entries: []testArchiveEntry{
+			{name: "existingLink/escaped.txt", entryType: regularFileEntry, content: "Escaped"},
+		},
+		existingSymlinks: []string{"existingLink"},
+		expectingError:   true,
+		creatingLinks:    true,
+	},
+	{
+		name: "Too many entries",
+		entries: []testArchiveEntry{
+			{name: "first.txt", entryType: regularFileEntry, content: "First"},
+			{name: "second.txt", entryType: regularFileEntry, content: "Second"},
+			{name: "third.txt", entryType: regularFileEntry, content: "Third"},
+		},
+		limits:         ExtractionLimits{MaxEntries: 2},
+		expectingError: true,
+	},
+	{
+		name: "Entries within the limit",
+		entries: []testArchiveEntry{
+			{name: "first.txt", entryType: regularFileEntry, content: "First"},
+			{name: "second.txt", entryType: regularFileEntry, content: "Second"},
+		},
+		limits: ExtractionLimits{MaxEntries: 2},
+	},
+	{
+		name: "Expanded size exceeding the limit",
+		entries: []testArchiveEntry{
+			{name: "first.txt", entryType: regularFileEntry, content: strings.Repeat("A", 10)},
+			{name: "second.txt", entryType: regularFileEntry, content: strings.Repeat("B", 10)},
+		},
+		limits:         ExtractionLimits{MaxExpandedSize: 15},
+		expectingError: true,
+	},
+	{
+		name: "Expanded size within the limit",
+		entries: []testArchiveEntry{
+			{name: "first.txt", entryType: regularFileEntry, content: strings.Repeat("A", 10)},
+			{name: "second.txt", entryType: regularFileEntry, content: strings.Repeat("B", 10)},
+		},
+		limits: ExtractionLimits{MaxExpandedSize: 20},
+	},
+}
+
+/*
+hardLinkCases only apply to tar archives, as zip archives cannot declare hard links
+*/
+var hardLinkCases = []extractionCase{
+	{
+		name: "Hard link within the app",
+		entries: []testArchiveEntry{
+			{name: "file.txt", entryType: regularFileEntry, content: "Hello"},
+			{name: "link.txt", entryType: hardLinkEntry, linkTarget: "file.txt"},
+		},
+		creatingLinks: true,
+	},
+	{
+		name: "Hard link outside the app",
+		entries: []testArchiveEntry{
+			{name: "link.txt", entryType: hardLinkEntry, linkTarget: "../" + outsideFileName},
+		},
+		expectingError: true,
+	},
+	{
+		name: "Hard link with absolute target",
+		entries: []testArchiveEntry{
+			{name: "link.txt", entryType: hardLinkEntry, linkTarget: "/etc/passwd"},
+		},
+		expectingError: true,
+	},
+}
+
+/*
+TestPackageExtraction extracts each case with every archive format,
+checking that unsafe packages are rejected with an ExtractionError
+*/
+func TestPackageExtraction(t *testing.T) {
+	for _, archiveFormat := range testArchiveFormats {
+		for _, testCase := range extractionCases {
+			archiveFormat := archiveFormat
+			testCase := testCase
+
+			t.Run(archiveFormat.extension+"/"+testCase.name, func(t *testing.T) {
+				checkExtractionCase(t, archiveFormat, testCase)
+			})
+		}
+	}
+}
+
+func TestHardLinkExtraction(t *testing.T) {
+	tarFormat := testArchiveFormat{".tar", writeTestTar}
+
+	for _, testCase := range hardLinkCases {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			checkExtractionCase(t, tarFormat, testCase)
+		})
+	}
+}
+
+func checkExtractionCase(t *testing.T, archiveFormat testArchiveFormat, testCase extractionCase) {
+	if testCase.creatingLinks && runtime.GOOS == "windows" {
+		t.Skip("Creating links might require additional privileges on Windows")
+	}
+
+	rootDirectory := t.TempDir()
+
+	outsideFilePath := filepath.Join(rootDirectory, outsideFileName)
+	err := ioutil.WriteFile(outsideFilePath, []byte("Outside"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targetDirectory := filepath.Join(rootDirectory, "files")
+	err = os.Mkdir(targetDirectory, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, existingSymlink := range testCase.existingSymlinks {
+		err = os.Symlink(rootDirectory, filepath.Join(targetDirectory, existingSymlink))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	packageName := "package" + archiveFormat.extension
+	packagePath := filepath.Join(rootDirectory, packageName)
+	archiveFormat.write(t, packagePath, testCase.entries)
+
+	err = extractPackage(context.Background(), packageName, packagePath, targetDirectory, 0, testCase.limits)
+
+	if testCase.expectingError {
+		var extractionErr *ExtractionError
+		if !errors.As(err, &extractionErr) {
+			t.Fatalf("An ExtractionError was expected, but got: %v", err)
+		}
+	} else if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	checkRootDirectory(t, rootDirectory)
+
+	if !testCase.expectingError {
+		checkExtractedEntries(t, targetDirectory, testCase.entries)
+	}
+}
+
+/*
+checkRootDirectory ensures that nothing has been created or modified
+outside the target directory
+*/
+func checkRootDirectory(t *testing.T, rootDirectory string) {
+	fileInfos, err := ioutil.ReadDir(rootDirectory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fileInfo := range fileInfos {
+		switch fileInfo.Name() {
+		case "files", outsideFileName:
+		default:
+			if !strings.HasPrefix(fileInfo.Name(), "package.") {
+				t.Errorf("Unexpected file outside the app: '%v'", fileInfo.Name())
+			}
+		}
+	}
+
+	outsideBytes, err := ioutil.ReadFile(filepath.Join(rootDirectory, outsideFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(outsideBytes) != "Outside" {
+		t.Errorf("The file outside the app has been modified: '%s'", outsideBytes)
+	}
+}
+
+func checkExtractedEntries(t *testing.T, targetDirectory string, entries []testArchiveEntry) {
+	contents := make(map[string]string)
+
+	for _, entry := range entries {
+		entryPath := filepath.Join(targetDirectory, filepath.FromSlash(entry.name))
+
+		switch entry.entryType {
+		case regularFileEntry:
+			contents[entry.name] = entry.content
+			checkFileContent(t, entryPath, entry.content)
+
+		case symlinkEntry:
+			linkTarget, err := os.Readlink(entryPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if linkTarget != entry.linkTarget {
+				t.Errorf("Symlink '%v' points to '%v' instead of '%v'", entry.name, linkTarget, entry.linkTarget)
+			}
+
+		case hardLinkEntry:
+			checkFileContent(t, entryPath, contents[entry.linkTarget])
+		}
+	}
+}
+
+func checkFileContent(t *testing.T, filePath string, expectedContent string) {
+	contentBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(contentBytes) != expectedContent {
+		t.Errorf("File '%v' contains '%s' instead of '%v'", filePath, contentBytes, expectedContent)
+	}
+}
+
+func writeTestZip(t *testing.T, packagePath string, entries []testArchiveEntry) {
+	packageFile, err := os.Create(packagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer packageFile.Close()
+
+	zipWriter := zip.NewWriter(packageFile)
+
+	for _, entry := range entries {
+		header := &zip.FileHeader{
+			Name:   entry.name,
+			Method: zip.Deflate,
+		}
+
+		content := entry.content
+
+		switch entry.entryType {
+		case directoryEntry:
+			header.SetMode(os.ModeDir | 0755)
+		case symlinkEntry:
+			header.SetMode(os.ModeSymlink | 0777)
+			content = entry.linkTarget
+		case regularFileEntry:
+			header.SetMode(0644)
+		default:
+			t.Fatalf("Unsupported zip entry: '%v'", entry.name)
+		}
+
+		entryWriter, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = entryWriter.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = zipWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestTar(t *testing.T, packagePath string, entries []testArchiveEntry) {
+	packageFile, err := os.Create(packagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer packageFile.Close()
+
+	tarWriter := tar.NewWriter(packageFile)
+
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.name,
+			Mode:     0644,
+			Linkname: entry.linkTarget,
+		}
+
+		switch entry.entryType {
+		case directoryEntry:
+			header.Typeflag = tar.TypeDir
+			header.Mode = 0755
+		case symlinkEntry:
+			header.Typeflag = tar.TypeSymlink
+		case hardLinkEntry:
+			header.Typeflag = tar.TypeLink
+		case regularFileEntry:
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(entry.content))
+		}
+
+		err = tarWriter.WriteHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = tarWriter.Write([]byte(entry.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
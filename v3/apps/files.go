@@ -21,33 +21,59 @@
 package apps
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
 
 	"github.com/giancosta86/caravel"
 
 	"github.com/giancosta86/moondeploy/v3/config"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
 	"github.com/giancosta86/moondeploy/v3/log"
-	"github.com/giancosta86/moondeploy/v3/ui"
+	"github.com/giancosta86/moondeploy/v3/networking"
+	"github.com/giancosta86/moondeploy/v3/versioning"
 )
 
+/*
+PackageProgressCallback is called while retrieving each package to update;
+packageIndex starts from 1
+*/
+type PackageProgressCallback func(packageName string, packageIndex int, packageCount int, retrievedSize int64, totalSize int64)
+
+/*
+CheckFiles downloads and installs the packages whose version changed in the
+remote descriptor - after applying the update staged by StageUpdate(), if any;
+should the context be canceled in the middle of an update,
+the partially updated files directory is removed, so that the next run
+can start from scratch
+*/
 func (app *App) CheckFiles(
+	ctx context.Context,
 	settings config.Settings,
-	userInterface ui.UserInterface) (err error) {
+	progressCallback PackageProgressCallback) (err error) {
+
+	err = app.applyStagedUpdate(ctx)
+	if err != nil {
+		log.Warning("Cannot apply the staged update: %v", err)
+	}
 
-	localDescriptor := app.GetLocalDescriptor()
-	remoteDescriptor := app.GetRemoteDescriptor()
+	localDescriptor := app.GetLocalDescriptor(ctx)
+	remoteDescriptor := app.GetRemoteDescriptor(ctx)
 
 	if remoteDescriptor == nil {
 		log.Notice("Skipping file check, as the remote descriptor is missing")
 		return nil
 	}
 
-	userInterface.SetHeader("Checking the app files")
 	log.Notice("Computing differences...")
 
-	packagesToUpdate := app.getPackagesToUpdate()
+	packagesToUpdate := app.getPackagesToUpdate(ctx)
 
 	if len(packagesToUpdate) == 0 {
 		log.Notice("All the packages are up-to-date")
@@ -63,6 +89,18 @@ func (app *App) CheckFiles(
 		log.Notice("Local descriptor deleted")
 	}
 
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			log.Info("Removing the partially updated app files dir...")
+			removalErr := os.RemoveAll(app.filesDirectory)
+			if removalErr != nil {
+				log.Warning("Could not remove the app files dir: %v", removalErr)
+			} else {
+				log.Notice("App files dir removed")
+			}
+		}
+	}()
+
 	retrieveAllPackages := (len(packagesToUpdate) == len(remoteDescriptor.GetPackageVersions()))
 	log.Notice("Must retrieve all the remote packages? %v", retrieveAllPackages)
 
@@ -76,20 +114,17 @@ func (app *App) CheckFiles(
 	}
 
 	for packageIndex, packageName := range packagesToUpdate {
-		userInterface.SetHeader(
-			fmt.Sprintf("Updating package %v of %v: %v",
-				packageIndex+1,
-				len(packagesToUpdate),
-				packageName))
-
 		log.Notice("Downloading %v...", packageName)
 
+		packageOrdinal := packageIndex + 1
+
 		err = app.installPackage(
+			ctx,
 			packageName,
+			app.filesDirectory,
 			settings,
 			func(retrievedSize int64, totalSize int64) {
-				log.Notice("Retrieved: %v / %v bytes", retrievedSize, totalSize)
-				userInterface.SetProgress(float64(retrievedSize) / float64(totalSize))
+				progressCallback(packageName, packageOrdinal, len(packagesToUpdate), retrievedSize, totalSize)
 			})
 		if err != nil {
 			return err
@@ -100,9 +135,52 @@ func (app *App) CheckFiles(
 	return nil
 }
 
-func (app *App) getPackagesToUpdate() []string {
-	localDescriptor := app.GetLocalDescriptor()
-	remoteDescriptor := app.GetRemoteDescriptor()
+/*
+PackageChange describes a package that an update would retrieve;
+InstalledVersion is nil if the package is not installed, while both versions
+are nil for packages having no version
+*/
+type PackageChange struct {
+	PackageName      string
+	InstalledVersion *versioning.Version
+	AvailableVersion *versioning.Version
+}
+
+/*
+GetPackageChanges returns the packages that CheckFiles would retrieve,
+without downloading anything
+*/
+func (app *App) GetPackageChanges(ctx context.Context) []PackageChange {
+	packageChanges := []PackageChange{}
+
+	remoteDescriptor := app.GetRemoteDescriptor(ctx)
+	if remoteDescriptor == nil {
+		return packageChanges
+	}
+
+	localPackageVersions := map[string]*versioning.Version{}
+	localDescriptor := app.GetLocalDescriptor(ctx)
+	if localDescriptor != nil {
+		localPackageVersions = localDescriptor.GetPackageVersions()
+	}
+
+	packagesToUpdate := app.getPackagesToUpdate(ctx)
+	sort.Strings(packagesToUpdate)
+
+	for _, packageName := range packagesToUpdate {
+		packageChanges = append(packageChanges, PackageChange{
+			PackageName:      packageName,
+			InstalledVersion: localPackageVersions[packageName],
+			AvailableVersion: remoteDescriptor.GetPackageVersions()[packageName],
+		})
+	}
+
+	return packageChanges
+}
+
+func (app *App) getPackagesToUpdate(ctx context.Context) []string {
+	localDescriptor := app.GetLocalDescriptor(ctx)
+	remoteDescriptor := app.GetRemoteDescriptor(ctx)
 
 	if localDescriptor == nil {
 		packagesToUpdate := []string{}
@@ -134,19 +212,21 @@ func (app *App) getPackagesToUpdate() []string {
 }
 
 func (app *App) installPackage(
+	ctx context.Context,
 	packageName string,
+	targetDirectory string,
 	settings config.Settings,
-	progressCallback caravel.RetrievalProgressCallback) (err error) {
+	progressCallback networking.RetrievalProgressCallback) (err error) {
 
-	remoteDescriptor := app.GetRemoteDescriptor()
+	remoteDescriptor := app.GetRemoteDescriptor(ctx)
 
-	packageURL, err := remoteDescriptor.GetRemoteFileURL(packageName)
+	packageURLs, err := remoteDescriptor.GetRemoteFileURLs(packageName)
 	if err != nil {
 		return err
 	}
 
 	log.Debug("Creating package temp file...")
-	packageTempFile, err := ioutil.TempFile(os.TempDir(), packageName)
+	packageTempFile, err := ioutil.TempFile(os.TempDir(), path.Base(packageName))
 	if err != nil {
 		return err
 	}
@@ -165,8 +245,24 @@ func (app *App) installPackage(
 		}
 	}()
 
-	log.Info("Retrieving package: %v", packageURL)
-	err = caravel.RetrieveChunksFromURL(packageURL, packageTempFile, settings.GetBufferSize(), progressCallback)
+	err = networking.RetrieveFromMirrors(ctx, packageURLs, func(packageURL *url.URL, attempt networking.RetrievalAttempt) (err error) {
+		err = resetFile(packageTempFile)
+		if err != nil {
+			return err
+		}
+
+		log.Notice("Retrieving package: %v", packageURL.Redacted())
+
+		return networking.RetrieveChunksFromURL(
+			ctx,
+			packageURL,
+			packageTempFile,
+			settings.GetBufferSize(),
+			func(retrievedSize int64, totalSize int64) {
+				log.Notice("Retrieved: %v / %v bytes (%v)", retrievedSize, totalSize, attempt)
+				progressCallback(retrievedSize, totalSize)
+			})
+	})
 	if err != nil {
 		return err
 	}
@@ -179,13 +275,27 @@ func (app *App) installPackage(
 	}
 	log.Notice("Package temp file closed")
 
-	err = os.MkdirAll(app.filesDirectory, 0700)
+	err = os.MkdirAll(targetDirectory, 0700)
 	if err != nil {
 		return err
 	}
 
+	rawPackage := remoteDescriptor.GetRawPackage(packageName)
+	if rawPackage != nil {
+		return installRawPackage(packageName, packageTempFilePath, targetDirectory, rawPackage)
+	}
+
+	extractionLimits := getExtractionLimits(remoteDescriptor, settings)
+	log.Debug("Extraction limits: %#v", extractionLimits)
+
 	log.Info("Extracting the package. Skipping levels: %v...", remoteDescriptor.GetSkipPackageLevels())
-	err = caravel.ExtractZipSkipLevels(packageTempFilePath, app.filesDirectory, remoteDescriptor.GetSkipPackageLevels())
+	err = extractPackage(
+		ctx,
+		packageName,
+		packageTempFilePath,
+		targetDirectory,
+		remoteDescriptor.GetSkipPackageLevels(),
+		extractionLimits)
 	if err != nil {
 		return err
 	}
@@ -193,3 +303,103 @@ func (app *App) installPackage(
 
 	return nil
 }
+
+func installRawPackage(packageName string, packageTempFilePath string, targetDirectory string, rawPackage *descriptors.RawPackage) (err error) {
+	targetPath := filepath.Join(targetDirectory, filepath.FromSlash(rawPackage.TargetPath))
+
+	if !isWithinDirectory(targetDirectory, targetPath) || targetPath == filepath.Clean(targetDirectory) {
+		return &ExtractionError{
+			PackageName: packageName,
+			Reason:      fmt.Sprintf("the target path is not within the files directory: '%v'", rawPackage.TargetPath),
+		}
+	}
+
+	log.Info("Copying the raw package to '%v'...", rawPackage.TargetPath)
+
+	err = os.MkdirAll(filepath.Dir(targetPath), 0700)
+	if err != nil {
+		return err
+	}
+
+	var targetMode os.FileMode = 0600
+	if rawPackage.Executable {
+		targetMode = 0700
+	}
+
+	os.Remove(targetPath)
+
+	err = copyFile(packageTempFilePath, targetPath, targetMode)
+	if err != nil {
+		return err
+	}
+
+	log.Notice("Raw package copied")
+
+	return nil
+}
+
+func copyFile(sourcePath string, targetPath string, targetMode os.FileMode) (err error) {
+	sourceFile, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	targetFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, targetMode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := targetFile.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	_, err = io.Copy(targetFile, sourceFile)
+	if err != nil {
+		return err
+	}
+
+	return targetFile.Chmod(targetMode)
+}
+
+func resetFile(file *os.File) (err error) {
+	err = file.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Seek(0, 0)
+	return err
+}
+
+/*
+getExtractionLimits returns the strictest limits declared by the descriptor
+and the settings: a descriptor can tighten the limits, but not relax them
+*/
+func getExtractionLimits(descriptor descriptors.AppDescriptor, settings config.Settings) ExtractionLimits {
+	const bytesInMB = 1024 * 1024
+
+	return ExtractionLimits{
+		MaxEntries: int(getStrictestLimit(
+			int64(descriptor.GetMaxPackageEntries()),
+			int64(settings.GetMaxPackageEntries()))),
+
+		MaxExpandedSize: getStrictestLimit(
+			descriptor.GetMaxPackageSizeInMB(),
+			settings.GetMaxPackageSizeInMB()) * bytesInMB,
+	}
+}
+
+func getStrictestLimit(limit int64, otherLimit int64) int64 {
+	if limit <= 0 {
+		return otherLimit
+	}
+
+	if otherLimit <= 0 || limit < otherLimit {
+		return limit
+	}
+
+	return otherLimit
+}
@@ -0,0 +1,130 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package apps
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+PackageEntry describes an entry of a package archive, as inspected
+without extracting it
+*/
+type PackageEntry struct {
+	Name        string
+	IsDirectory bool
+}
+
+/*
+GetPathComponents returns the components of the entry name,
+ignoring empty and "." components
+*/
+func (entry PackageEntry) GetPathComponents() []string {
+	return getCleanPathComponents(entry.Name)
+}
+
+/*
+IsArchivePackageName returns true if the extension of the given package name
+denotes a supported archive format
+*/
+func IsArchivePackageName(packageName string) bool {
+	lowercasePackageName := strings.ToLower(packageName)
+
+	for _, archiveExtension := range archiveExtensions {
+		if strings.HasSuffix(lowercasePackageName, archiveExtension.extension) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+ListPackageEntries returns the entries of a package archive, without extracting it
+*/
+func ListPackageEntries(packageName string, packagePath string) (entries []PackageEntry, err error) {
+	format, err := detectArchiveFormat(packageName, packagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == zipArchive {
+		return listZipEntries(packagePath)
+	}
+
+	packageFile, err := os.Open(packagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer packageFile.Close()
+
+	tarStream, closeTarStream, err := openTarStream(format, packageFile)
+	if err != nil {
+		return nil, err
+	}
+	defer closeTarStream()
+
+	return listTarEntries(tarStream)
+}
+
+func listZipEntries(packagePath string) (entries []PackageEntry, err error) {
+	zipReader, err := zip.OpenReader(packagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer zipReader.Close()
+
+	entries = []PackageEntry{}
+
+	for _, zipEntry := range zipReader.File {
+		entries = append(entries, PackageEntry{
+			Name:        zipEntry.Name,
+			IsDirectory: zipEntry.Mode().IsDir(),
+		})
+	}
+
+	return entries, nil
+}
+
+func listTarEntries(tarStream io.Reader) (entries []PackageEntry, err error) {
+	tarReader := tar.NewReader(tarStream)
+
+	entries = []PackageEntry{}
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, PackageEntry{
+			Name:        header.Name,
+			IsDirectory: header.Typeflag == tar.TypeDir,
+		})
+	}
+}
@@ -0,0 +1,283 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package apps
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/giancosta86/LockAPI/lockapi"
+
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+const instancesDirName = "instances"
+const instanceFileExtension = ".instance"
+
+/*
+AppAlreadyRunning is returned when starting a single-instance app
+that is already running
+*/
+type AppAlreadyRunning struct {
+	AppName string
+}
+
+func (err *AppAlreadyRunning) Error() string {
+	return fmt.Sprintf("'%v' is already running", err.AppName)
+}
+
+/*
+instanceRecord is written to each instance file; the PID is only checked
+for detached instances - which hold no lock, as MoonDeploy exits right after
+starting them
+*/
+type instanceRecord struct {
+	PID      int
+	Hostname string
+	Detached bool
+}
+
+func (record *instanceRecord) isDetachedProcessAlive() bool {
+	if !record.Detached {
+		return false
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname != record.Hostname {
+		return false
+	}
+
+	return isProcessAlive(record.PID)
+}
+
+func newInstanceRecord(pid int, detached bool) *instanceRecord {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Warning("Cannot retrieve the hostname: %v", err)
+	}
+
+	return &instanceRecord{
+		PID:      pid,
+		Hostname: hostname,
+		Detached: detached,
+	}
+}
+
+func readInstanceRecord(instanceFilePath string) (record *instanceRecord, err error) {
+	recordBytes, err := ioutil.ReadFile(instanceFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	record = &instanceRecord{}
+	err = json.Unmarshal(recordBytes, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func writeInstanceRecord(instanceFile *os.File, record *instanceRecord) (err error) {
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = instanceFile.Write(recordBytes)
+	if err != nil {
+		return err
+	}
+
+	return instanceFile.Sync()
+}
+
+func (app *App) getInstancesDirectory() string {
+	return filepath.Join(app.Directory, instancesDirName)
+}
+
+/*
+IsRunning returns true if at least one instance of the app, started by any
+MoonDeploy process, is still running. Each instance holds a lock on its own
+file in the instances directory, so the files left by crashed processes
+can be locked - and are therefore deleted, unless they describe a detached
+process still running on this host
+*/
+func (app *App) IsRunning() bool {
+	instanceFileInfos, err := ioutil.ReadDir(app.getInstancesDirectory())
+	if err != nil {
+		return false
+	}
+
+	running := false
+
+	for _, instanceFileInfo := range instanceFileInfos {
+		if filepath.Ext(instanceFileInfo.Name()) != instanceFileExtension {
+			continue
+		}
+
+		if app.instanceFile != nil && instanceFileInfo.Name() == filepath.Base(app.instanceFile.Name()) {
+			running = true
+			continue
+		}
+
+		instanceFilePath := filepath.Join(app.getInstancesDirectory(), instanceFileInfo.Name())
+
+		instanceFile, err := os.OpenFile(instanceFilePath, os.O_RDWR, 0600)
+		if err != nil {
+			log.Debug("Cannot open instance file '%v': %v", instanceFilePath, err)
+			running = true
+			continue
+		}
+
+		err = lockapi.TryLockFile(instanceFile)
+		if err != nil {
+			instanceFile.Close()
+			running = true
+			continue
+		}
+
+		lockapi.UnlockFile(instanceFile)
+		instanceFile.Close()
+
+		record, err := readInstanceRecord(instanceFilePath)
+		if err == nil && record.isDetachedProcessAlive() {
+			running = true
+			continue
+		}
+
+		log.Info("Deleting stale instance file '%v'...", instanceFilePath)
+		err = os.Remove(instanceFilePath)
+		if err != nil {
+			log.Warning("Cannot delete stale instance file: %v", err)
+		} else {
+			log.Notice("Stale instance file deleted")
+		}
+	}
+
+	log.Debug("Is the app running? %v", running)
+
+	return running
+}
+
+/*
+RegisterInstance marks the app as running until UnregisterInstance() is
+called or the current process exits; it should be called while holding
+the lock on the app directory, to prevent races with other processes
+*/
+func (app *App) RegisterInstance() (err error) {
+	if app.instanceFile != nil {
+		return nil
+	}
+
+	instancesDirectory := app.getInstancesDirectory()
+
+	err = os.MkdirAll(instancesDirectory, 0700)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Creating the instance file...")
+	instanceFile, err := ioutil.TempFile(instancesDirectory, fmt.Sprintf("%v-*%v", os.Getpid(), instanceFileExtension))
+	if err != nil {
+		return err
+	}
+
+	err = lockapi.TryLockFile(instanceFile)
+	if err != nil {
+		instanceFile.Close()
+		os.Remove(instanceFile.Name())
+		return err
+	}
+
+	err = writeInstanceRecord(instanceFile, newInstanceRecord(os.Getpid(), false))
+	if err != nil {
+		lockapi.UnlockFile(instanceFile)
+		instanceFile.Close()
+		os.Remove(instanceFile.Name())
+		return err
+	}
+	log.Notice("Instance file created: %v", instanceFile.Name())
+
+	app.instanceFile = instanceFile
+
+	return nil
+}
+
+/*
+registerDetachedInstance marks the app as running as long as the given
+detached process is alive
+*/
+func (app *App) registerDetachedInstance(pid int) (err error) {
+	instancesDirectory := app.getInstancesDirectory()
+
+	err = os.MkdirAll(instancesDirectory, 0700)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Creating the instance file of the detached process...")
+	instanceFile, err := ioutil.TempFile(instancesDirectory, fmt.Sprintf("%v-*%v", pid, instanceFileExtension))
+	if err != nil {
+		return err
+	}
+	defer instanceFile.Close()
+
+	err = writeInstanceRecord(instanceFile, newInstanceRecord(pid, true))
+	if err != nil {
+		os.Remove(instanceFile.Name())
+		return err
+	}
+	log.Notice("Instance file created: %v", instanceFile.Name())
+
+	return nil
+}
+
+/*
+UnregisterInstance deletes the instance file created by RegisterInstance()
+*/
+func (app *App) UnregisterInstance() (err error) {
+	if app.instanceFile == nil {
+		return nil
+	}
+
+	instanceFile := app.instanceFile
+	app.instanceFile = nil
+
+	log.Info("Deleting the instance file...")
+	lockapi.UnlockFile(instanceFile)
+
+	err = instanceFile.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(instanceFile.Name())
+	if err != nil {
+		return err
+	}
+	log.Notice("Instance file deleted")
+
+	return nil
+}
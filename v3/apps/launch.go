@@ -0,0 +1,208 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package apps
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/giancosta86/moondeploy/v3/config"
+	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/ui"
+)
+
+/*
+AppFailed is returned when the app exits with a non-zero exit code;
+ExitCode is -1 if the app has been terminated by a signal
+*/
+type AppFailed struct {
+	AppName  string
+	ExitCode int
+}
+
+func (err *AppFailed) Error() string {
+	if err.ExitCode < 0 {
+		return fmt.Sprintf("'%v' has been terminated abnormally", err.AppName)
+	}
+
+	return fmt.Sprintf("'%v' has exited with code %v", err.AppName, err.ExitCode)
+}
+
+/*
+Launch starts the app and waits for it to exit; unless the settings require
+to skip it, the app output is written line by line - as soon as it is produced -
+to the output log of the app. A non-zero exit code is returned as AppFailed
+*/
+func (app *App) Launch(command *exec.Cmd, settings config.Settings, userInterface ui.UserInterface) (err error) {
+	log.Info("Starting the app...")
+
+	log.Debug("Hiding the user interface...")
+	userInterface.Hide()
+	log.Notice("User interface hidden")
+
+	if settings.IsSkipAppOutput() {
+		err = command.Run()
+	} else {
+		err = app.runWithLoggedOutput(command, settings)
+	}
+
+	exitErr, isExitErr := err.(*exec.ExitError)
+	if isExitErr {
+		return &AppFailed{
+			AppName:  app.bootDescriptor.GetName(),
+			ExitCode: exitErr.ExitCode(),
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log.Notice("The app has exited successfully")
+	return nil
+}
+
+/*
+LaunchDetached starts the app in a session - or process group - of its own,
+writing its output to the output log of the app - or discarding it, should
+the output log be unavailable - and returns as soon as the app has started;
+the app is registered as a running instance
+*/
+func (app *App) LaunchDetached(command *exec.Cmd, settings config.Settings, userInterface ui.UserInterface) (err error) {
+	log.Info("Starting the app in detached mode...")
+
+	log.Debug("Hiding the user interface...")
+	userInterface.Hide()
+	log.Notice("User interface hidden")
+
+	detachCommand(command)
+
+	if !settings.IsSkipAppOutput() {
+		outputLog, err := app.openOutputLogForRun(settings)
+		if err != nil {
+			log.Warning("Cannot open the output log, so the app output will be discarded: %v", err)
+		} else {
+			defer outputLog.Close()
+
+			log.Notice("The app output will be written to: '%v'", outputLog.Name())
+
+			command.Stdout = outputLog
+			command.Stderr = outputLog
+		}
+	}
+
+	err = command.Start()
+	if err != nil {
+		return err
+	}
+
+	pid := command.Process.Pid
+	log.Notice("App started, with PID %v", pid)
+
+	err = app.registerDetachedInstance(pid)
+	if err != nil {
+		log.Warning("Cannot register the detached instance: %v", err)
+	}
+
+	return command.Process.Release()
+}
+
+/*
+outputLineSink receives each line written by the app
+*/
+type outputLineSink func(streamName string, line string)
+
+/*
+runWithLoggedOutput runs the command, writing each line of its standard output
+and standard error to the output log of the app - so that every line gets its
+own timestamp; should the output log be unavailable, the MoonDeploy log is used
+*/
+func (app *App) runWithLoggedOutput(command *exec.Cmd, settings config.Settings) (err error) {
+	var lineSink outputLineSink = logOutputLine
+
+	outputLogWriter, err := newOutputLogWriter(app, settings)
+	if err != nil {
+		log.Warning("Cannot open the output log, so the app output will be written to the MoonDeploy log: %v", err)
+	} else {
+		defer outputLogWriter.close()
+
+		log.Notice("The app output will be written to: '%v'", app.GetOutputLogPath())
+		lineSink = outputLogWriter.writeLine
+	}
+
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	stderr, err := command.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	err = command.Start()
+	if err != nil {
+		return err
+	}
+
+	var outputReading sync.WaitGroup
+	outputReading.Add(2)
+
+	go readOutputLines("stdout", stdout, lineSink, &outputReading)
+	go readOutputLines("stderr", stderr, lineSink, &outputReading)
+
+	//
+	//The pipes must be fully read before waiting for the command
+	//
+	outputReading.Wait()
+
+	return command.Wait()
+}
+
+func readOutputLines(streamName string, stream io.Reader, lineSink outputLineSink, outputReading *sync.WaitGroup) {
+	defer outputReading.Done()
+
+	reader := bufio.NewReader(stream)
+
+	for {
+		line, err := reader.ReadString('\n')
+
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" {
+			lineSink(streamName, line)
+		}
+
+		if err != nil {
+			if err != io.EOF {
+				log.Warning("Cannot read the app's %v: %v", streamName, err)
+			}
+			return
+		}
+	}
+}
+
+func logOutputLine(streamName string, line string) {
+	log.Info("[%v] %v", streamName, line)
+}
@@ -0,0 +1,136 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package apps
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/giancosta86/LockAPI/lockapi"
+
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+/*
+LockStatus describes a lock file found in the gallery
+*/
+type LockStatus struct {
+	LockFilePath string
+
+	//Owner is nil if the lock file does not describe its owner
+	Owner *LockOwner
+
+	//Held is false for lock files left in the gallery without being locked
+	Held bool
+}
+
+/*
+IsStale returns true if the lock file can be safely deleted - because it is
+not locked or because its owner is stale
+*/
+func (status *LockStatus) IsStale() bool {
+	if !status.Held {
+		return true
+	}
+
+	return status.Owner != nil && status.Owner.IsStale()
+}
+
+/*
+InspectLock reads the owner of the given lock file and checks whether
+the lock is currently held by some process
+*/
+func InspectLock(lockFilePath string) (status *LockStatus, err error) {
+	owner, err := ReadLockOwner(lockFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+
+		log.Debug("Cannot read the owner of lock file '%v': %v", lockFilePath, err)
+		owner = nil
+	}
+
+	return &LockStatus{
+		LockFilePath: lockFilePath,
+		Owner:        owner,
+		Held:         isLockHeld(lockFilePath),
+	}, nil
+}
+
+func isLockHeld(lockFilePath string) bool {
+	lockFile, err := os.OpenFile(lockFilePath, os.O_RDWR, 0600)
+	if err != nil {
+		return !os.IsNotExist(err)
+	}
+	defer lockFile.Close()
+
+	err = lockapi.TryLockFile(lockFile)
+	if err != nil {
+		return true
+	}
+
+	lockapi.UnlockFile(lockFile)
+
+	return false
+}
+
+/*
+GetLockFilePath returns the path of the lock file within the given app directory
+*/
+func GetLockFilePath(appDirectory string) string {
+	return filepath.Join(appDirectory, lockFileName)
+}
+
+/*
+ForceReleaseLock deletes the given lock file even if it is still held:
+the owner, if still running, will go on, but other processes will be able
+to lock the app directory - so it should only be used when the owner
+is known to be stuck or dead.
+
+The lock file is only deleted if its owner is still the one described
+by the given status - otherwise, released is false
+*/
+func ForceReleaseLock(status *LockStatus) (released bool, err error) {
+	log.Info("Force-releasing lock file '%v'...", status.LockFilePath)
+	released, err = removeLockFile(status.LockFilePath, status.Owner)
+	if err != nil {
+		return false, err
+	}
+
+	if released {
+		log.Notice("Lock file deleted")
+	}
+
+	return released, nil
+}
+
+/*
+ReleaseStaleLock deletes the given lock file if it is stale, provided that
+its owner is still the one described by the given status
+*/
+func ReleaseStaleLock(status *LockStatus) (released bool, err error) {
+	if !status.IsStale() {
+		return false, nil
+	}
+
+	return ForceReleaseLock(status)
+}
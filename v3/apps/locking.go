@@ -21,75 +21,428 @@
 package apps
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"os"
-	"path/filepath"
+	"time"
 
 	"github.com/giancosta86/LockAPI/lockapi"
 
-	"github.com/giancosta86/caravel"
-
 	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/networking"
 )
 
-func (app *App) LockDirectory() (err error) {
-	if app.lockFile != nil {
+const lockPollingInterval = 500 * time.Millisecond
+const lockRefreshInterval = 1 * time.Minute
+const staleLockAge = 10 * time.Minute
+
+/*
+AppLocked is returned when the app directory is still locked by another
+process - for example, another MoonDeploy instance updating the app -
+once the lock timeout has expired
+*/
+type AppLocked struct {
+	AppName string
+	Owner   *LockOwner
+}
+
+func (err *AppLocked) Error() string {
+	if err.Owner == nil {
+		return fmt.Sprintf("Another update of '%v' is in progress - please, try again later", err.AppName)
+	}
+
+	return fmt.Sprintf("Another update of '%v' is in progress (%v) - please, try again later", err.AppName, err.Owner)
+}
+
+/*
+LockOwner describes the process holding the lock on an app directory;
+it is written to the lock file and refreshed periodically while the lock is held
+*/
+type LockOwner struct {
+	PID         int
+	Hostname    string
+	AcquiredAt  time.Time
+	RefreshedAt time.Time
+}
+
+func (owner *LockOwner) String() string {
+	return fmt.Sprintf("PID %v on host '%v', since %v",
+		owner.PID,
+		owner.Hostname,
+		owner.AcquiredAt.Local().Format(time.RFC3339))
+}
+
+/*
+IsStale returns true if the owner of a lock that is still held runs on another host -
+for example, when the gallery resides on a network share - and has not refreshed
+the lock for a long time.
+
+An owner on this host is never stale: the lock of a process is released
+by the OS as soon as the process ends, so a held lock means a running owner -
+even if it has not refreshed the lock, or the file still describes the previous owner
+*/
+func (owner *LockOwner) IsStale() bool {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == owner.Hostname {
+		return false
+	}
+
+	return time.Since(owner.RefreshedAt) > staleLockAge
+}
+
+/*
+ReadLockOwner returns the owner written to the given lock file,
+or nil if the file contains no owner
+*/
+func ReadLockOwner(lockFilePath string) (owner *LockOwner, err error) {
+	ownerBytes, err := ioutil.ReadFile(lockFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ownerBytes) == 0 {
+		return nil, nil
+	}
+
+	owner = &LockOwner{}
+	err = json.Unmarshal(ownerBytes, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return owner, nil
+}
+
+func writeLockOwner(lockFile *os.File, owner *LockOwner) (err error) {
+	ownerBytes, err := json.Marshal(owner)
+	if err != nil {
+		return err
+	}
+
+	err = lockFile.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = lockFile.WriteAt(ownerBytes, 0)
+	if err != nil {
+		return err
+	}
+
+	return lockFile.Sync()
+}
+
+/*
+directoryLock is the lock held on an app directory, whose owner is refreshed
+in the background until the lock is released
+*/
+type directoryLock struct {
+	file  *os.File
+	owner *LockOwner
+
+	stopRefresh chan struct{}
+	refreshDone chan struct{}
+}
+
+func newDirectoryLock(lockFile *os.File) (lock *directoryLock, err error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Warning("Cannot retrieve the hostname: %v", err)
+	}
+
+	now := time.Now()
+
+	lock = &directoryLock{
+		file: lockFile,
+		owner: &LockOwner{
+			PID:         os.Getpid(),
+			Hostname:    hostname,
+			AcquiredAt:  now,
+			RefreshedAt: now,
+		},
+
+		stopRefresh: make(chan struct{}),
+		refreshDone: make(chan struct{}),
+	}
+
+	err = writeLockOwner(lockFile, lock.owner)
+	if err != nil {
+		return nil, err
+	}
+
+	go lock.refresh()
+
+	return lock, nil
+}
+
+func (lock *directoryLock) refresh() {
+	defer close(lock.refreshDone)
+
+	ticker := time.NewTicker(lockRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			lock.owner.RefreshedAt = time.Now()
+
+			err := writeLockOwner(lock.file, lock.owner)
+			if err != nil {
+				log.Warning("Cannot refresh the lock file: %v", err)
+			}
+
+		case <-lock.stopRefresh:
+			return
+		}
+	}
+}
+
+func (lock *directoryLock) stopRefreshing() {
+	close(lock.stopRefresh)
+	<-lock.refreshDone
+}
+
+/*
+LockWaitCallback is called - just once - when the app directory is locked
+by another process and LockDirectory() starts waiting for it
+*/
+type LockWaitCallback func()
+
+/*
+LockDirectory locks the app directory, waiting for at most the given timeout
+if another process is holding the lock; a zero timeout means "fail at once".
+
+A stale lock - left by a crashed process - is reclaimed without waiting.
+*/
+func (app *App) LockDirectory(ctx context.Context, timeout time.Duration, waitCallback LockWaitCallback) (err error) {
+	if app.lock != nil {
 		return nil
 	}
 
-	lockFilePath := filepath.Join(app.Directory, lockFileName)
+	lockFilePath := GetLockFilePath(app.Directory)
 
 	log.Info("The lock file is: %v", lockFilePath)
 
+	deadline := time.Now().Add(timeout)
+	waiting := false
+
+	for {
+		lockFile, err := tryLockFile(lockFilePath)
+		if err != nil {
+			return err
+		}
+
+		if lockFile != nil {
+			lock, err := newDirectoryLock(lockFile)
+			if err != nil {
+				lockapi.UnlockFile(lockFile)
+				lockFile.Close()
+				return err
+			}
+			log.Notice("Lock acquired")
+
+			app.lock = lock
+			return nil
+		}
+
+		owner, err := ReadLockOwner(lockFilePath)
+		if err != nil {
+			log.Debug("Cannot read the lock owner: %v", err)
+			owner = nil
+		}
+
+		if owner != nil && owner.IsStale() {
+			log.Warning("Reclaiming the stale lock held by %v...", owner)
+			removed, err := removeLockFile(lockFilePath, owner)
+			if err != nil {
+				return err
+			}
+			if removed {
+				log.Notice("Stale lock file deleted")
+			}
+
+			continue
+		}
+
+		if !time.Now().Before(deadline) {
+			return &AppLocked{
+				AppName: app.bootDescriptor.GetName(),
+				Owner:   owner,
+			}
+		}
+
+		if !waiting {
+			log.Notice("The app dir is locked by another process: waiting for at most %v...", timeout)
+			waiting = true
+
+			if waitCallback != nil {
+				waitCallback()
+			}
+		}
+
+		err = networking.SleepWithContext(ctx, lockPollingInterval)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+/*
+tryLockFile returns the locked file, or nil if another process is holding
+the lock. As the lock file is deleted when unlocking, the lock is also
+considered busy if the file has been replaced while acquiring it
+*/
+func tryLockFile(lockFilePath string) (lockFile *os.File, err error) {
 	log.Info("Opening the lock file...")
-	lockFile, err := os.OpenFile(lockFilePath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0600)
+	lockFile, err = os.OpenFile(lockFilePath, os.O_CREATE|os.O_RDWR, 0600)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	log.Info("Obtaining the API lock...")
 	err = lockapi.TryLockFile(lockFile)
 	if err != nil {
+		log.Debug("Cannot lock the file: %v", err)
 		lockFile.Close()
-		return err
+		return nil, nil
 	}
-	log.Notice("Lock acquired")
 
-	app.lockFile = lockFile
+	if !isSameFile(lockFile, lockFilePath) {
+		log.Debug("The lock file has been replaced while locking it")
+		lockapi.UnlockFile(lockFile)
+		lockFile.Close()
+		return nil, nil
+	}
 
-	return nil
+	return lockFile, nil
 }
 
+func isSameFile(file *os.File, path string) bool {
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	pathInfo, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(fileInfo, pathInfo)
+}
+
+/*
+removeLockFile deletes the given lock file, provided that it still belongs
+to the expected owner - not to a process that has just reclaimed it;
+removed is false if the lock file has changed owner or does not exist
+*/
+func removeLockFile(lockFilePath string, expectedOwner *LockOwner) (removed bool, err error) {
+	currentOwner, err := ReadLockOwner(lockFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		if expectedOwner != nil {
+			return false, err
+		}
+
+		//
+		//An unreadable owner is unknown - just like the expected one
+		//
+		currentOwner = nil
+	}
+
+	if !isSameLockOwner(currentOwner, expectedOwner) {
+		log.Notice("The lock file has changed owner in the meantime")
+		return false, nil
+	}
+
+	err = os.Remove(lockFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+/*
+isSameLockOwner returns true if both owners are unknown - that is, nil -
+or if they describe the same acquisition of the lock
+*/
+func isSameLockOwner(owner *LockOwner, otherOwner *LockOwner) bool {
+	if owner == nil || otherOwner == nil {
+		return owner == otherOwner
+	}
+
+	return owner.PID == otherOwner.PID &&
+		owner.Hostname == otherOwner.Hostname &&
+		owner.AcquiredAt.Equal(otherOwner.AcquiredAt)
+}
+
+/*
+UnlockDirectory deletes the lock file - while still holding the lock, wherever
+the OS allows it, so that waiting processes cannot lock a file being deleted -
+and releases the lock
+*/
 func (app *App) UnlockDirectory() (err error) {
-	if app.lockFile == nil {
+	lock := app.lock
+	if lock == nil {
 		return nil
 	}
 
-	if !caravel.FileExists(app.lockFile.Name()) {
-		return nil
+	app.lock = nil
+
+	lock.stopRefreshing()
+
+	lockFilePath := lock.file.Name()
+
+	//
+	//The lock file might have been force-released and replaced by another process
+	//
+	lockFileOwned := isSameFile(lock.file, lockFilePath)
+	lockFileDeleted := false
+
+	if lockFileOwned {
+		log.Info("Deleting lock file...")
+		lockFileDeleted = os.Remove(lockFilePath) == nil
+	} else {
+		log.Warning("The lock file had been force-released")
 	}
 
 	log.Info("Releasing the API lock...")
-	err = lockapi.UnlockFile(app.lockFile)
+	err = lockapi.UnlockFile(lock.file)
 	if err != nil {
+		lock.file.Close()
 		return err
 	}
 	log.Notice("Lock released")
 
 	log.Info("Closing lock file...")
-	err = app.lockFile.Close()
+	err = lock.file.Close()
 	if err != nil {
 		return err
 	}
 	log.Notice("Lock file closed")
 
-	log.Info("Deleting lock file...")
-	err = os.Remove(app.lockFile.Name())
-	if err != nil {
-		return err
+	if lockFileOwned && !lockFileDeleted {
+		log.Info("Deleting lock file after closing it...")
+		err = os.Remove(lockFilePath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		lockFileDeleted = true
 	}
-	log.Notice("Lock file deleted")
 
-	app.lockFile = nil
+	if lockFileDeleted {
+		log.Notice("Lock file deleted")
+	}
 
 	return nil
 }
@@ -0,0 +1,233 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package apps
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/giancosta86/caravel"
+
+	"github.com/giancosta86/moondeploy/v3/config"
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+const outputLogsDirName = "logs"
+const outputLogBaseName = "output"
+const outputLogExtension = ".log"
+
+const outputLineTimeFormat = "2006-01-02 15:04:05.000"
+
+func (app *App) getOutputLogsDirectory() string {
+	return filepath.Join(app.Directory, outputLogsDirName)
+}
+
+/*
+GetOutputLogPath returns the path of the latest log file receiving the output
+of the app; older log files are named output.1.log, output.2.log, ...
+*/
+func (app *App) GetOutputLogPath() string {
+	return app.getRotatedOutputLogPath(0)
+}
+
+func (app *App) getRotatedOutputLogPath(rotationIndex int) string {
+	if rotationIndex == 0 {
+		return filepath.Join(app.getOutputLogsDirectory(), outputLogBaseName+outputLogExtension)
+	}
+
+	return filepath.Join(app.getOutputLogsDirectory(),
+		fmt.Sprintf("%v.%v%v", outputLogBaseName, rotationIndex, outputLogExtension))
+}
+
+/*
+rotateOutputLogs renames the latest log file to output.1.log, shifting
+the older ones and deleting those exceeding the retained files
+*/
+func (app *App) rotateOutputLogs(retainedFiles int) (err error) {
+	log.Info("Rotating the output logs...")
+
+	oldestLogPath := app.getRotatedOutputLogPath(retainedFiles)
+	if caravel.FileExists(oldestLogPath) {
+		err = os.Remove(oldestLogPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	for rotationIndex := retainedFiles - 1; rotationIndex >= 0; rotationIndex-- {
+		sourcePath := app.getRotatedOutputLogPath(rotationIndex)
+		if !caravel.FileExists(sourcePath) {
+			continue
+		}
+
+		err = os.Rename(sourcePath, app.getRotatedOutputLogPath(rotationIndex+1))
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Notice("Output logs rotated")
+
+	return nil
+}
+
+/*
+openOutputLog opens the latest log file for appending - rotating it first
+if it has reached the maximum size
+*/
+func (app *App) openOutputLog(settings config.Settings) (outputLog *os.File, err error) {
+	err = os.MkdirAll(app.getOutputLogsDirectory(), 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	outputLogPath := app.GetOutputLogPath()
+	maxSize := settings.GetAppLogMaxSizeInMB() * 1024 * 1024
+
+	outputLogInfo, err := os.Stat(outputLogPath)
+	if err == nil && outputLogInfo.Size() >= maxSize {
+		err = app.rotateOutputLogs(settings.GetAppLogRetainedFiles())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return os.OpenFile(outputLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+}
+
+/*
+openOutputLogForRun opens the output log, writing a header that marks
+the beginning of a new run of the app
+*/
+func (app *App) openOutputLogForRun(settings config.Settings) (outputLog *os.File, err error) {
+	outputLog, err = app.openOutputLog(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = fmt.Fprintf(outputLog, "===== %v started at %v =====\n",
+		app.bootDescriptor.GetName(),
+		time.Now().Format(time.RFC3339))
+	if err != nil {
+		outputLog.Close()
+		return nil, err
+	}
+
+	return outputLog, nil
+}
+
+/*
+outputLogWriter writes timestamped output lines to the output log of an app,
+rotating it as soon as it reaches the maximum size; it can be shared
+by the routines reading the standard output and the standard error
+*/
+type outputLogWriter struct {
+	app      *App
+	settings config.Settings
+
+	mutex     sync.Mutex
+	outputLog *os.File
+	size      int64
+}
+
+func newOutputLogWriter(app *App, settings config.Settings) (writer *outputLogWriter, err error) {
+	outputLog, err := app.openOutputLogForRun(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	outputLogInfo, err := outputLog.Stat()
+	if err != nil {
+		outputLog.Close()
+		return nil, err
+	}
+
+	return &outputLogWriter{
+		app:       app,
+		settings:  settings,
+		outputLog: outputLog,
+		size:      outputLogInfo.Size(),
+	}, nil
+}
+
+/*
+writeLine falls back to the MoonDeploy log should the output log fail
+*/
+func (writer *outputLogWriter) writeLine(streamName string, line string) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	err := writer.tryToWriteLine(streamName, line)
+	if err != nil {
+		log.Warning("Cannot write to the output log: %v", err)
+		logOutputLine(streamName, line)
+	}
+}
+
+func (writer *outputLogWriter) tryToWriteLine(streamName string, line string) (err error) {
+	if writer.outputLog == nil {
+		return fmt.Errorf("The output log is closed")
+	}
+
+	if writer.size >= writer.settings.GetAppLogMaxSizeInMB()*1024*1024 {
+		err = writer.outputLog.Close()
+		writer.outputLog = nil
+		if err != nil {
+			return err
+		}
+
+		err = writer.app.rotateOutputLogs(writer.settings.GetAppLogRetainedFiles())
+		if err != nil {
+			return err
+		}
+
+		writer.outputLog, err = writer.app.openOutputLog(writer.settings)
+		if err != nil {
+			return err
+		}
+		writer.size = 0
+	}
+
+	writtenBytes, err := fmt.Fprintf(writer.outputLog, "%v [%v] %v\n",
+		time.Now().Format(outputLineTimeFormat),
+		streamName,
+		line)
+	writer.size += int64(writtenBytes)
+
+	return err
+}
+
+func (writer *outputLogWriter) close() (err error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.outputLog == nil {
+		return nil
+	}
+
+	err = writer.outputLog.Close()
+	writer.outputLog = nil
+
+	return err
+}
@@ -0,0 +1,42 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package apps
+
+import "syscall"
+
+const processQueryLimitedInformation = 0x1000
+const stillActiveExitCode = 259
+
+func isProcessAlive(pid int) bool {
+	processHandle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(processHandle)
+
+	var exitCode uint32
+	err = syscall.GetExitCodeProcess(processHandle, &exitCode)
+	if err != nil {
+		return true
+	}
+
+	return exitCode == stillActiveExitCode
+}
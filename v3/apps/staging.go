@@ -0,0 +1,490 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package apps
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/giancosta86/caravel"
+
+	"github.com/giancosta86/moondeploy/v3/config"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+const stagingDirName = "staging"
+const replacedFilesDirName = "files.replaced"
+
+func (app *App) getStagingDirectory() string {
+	return filepath.Join(app.Directory, stagingDirName)
+}
+
+func (app *App) getStagedFilesDirectory() string {
+	return filepath.Join(app.getStagingDirectory(), filesDirName)
+}
+
+func (app *App) getStagedDescriptorPath() string {
+	return filepath.Join(app.getStagingDirectory(), app.bootDescriptor.GetDescriptorFileName())
+}
+
+/*
+abandonedPreparationAge is how old the private staging directory of an update
+must be to be considered abandoned - for example, after a crash
+*/
+const abandonedPreparationAge = 24 * time.Hour
+
+/*
+PreparedUpdate is an update retrieved into a private staging directory,
+without locking the app directory: it only becomes the staged update
+of the app when committed via CommitUpdate()
+*/
+type PreparedUpdate struct {
+	directory            string
+	descriptor           descriptors.AppDescriptor
+	localDescriptorBytes []byte
+}
+
+func (update *PreparedUpdate) GetDescriptor() descriptors.AppDescriptor {
+	return update.descriptor
+}
+
+/*
+StageUpdate retrieves the packages changed in the remote descriptor into
+a staging directory, leaving the installed files untouched - so that the app
+can keep running; the next CheckFiles() just swaps the staged files in.
+
+It is the composition of PrepareUpdate() and CommitUpdate(), so the app
+directory must be locked by the caller for the whole process.
+*/
+func (app *App) StageUpdate(
+	ctx context.Context,
+	settings config.Settings,
+	progressCallback PackageProgressCallback) (staged bool, err error) {
+
+	update, err := app.PrepareUpdate(ctx, settings, progressCallback)
+	if err != nil || update == nil {
+		return false, err
+	}
+	defer app.DiscardPreparedUpdate(update)
+
+	return app.CommitUpdate(ctx, update)
+}
+
+/*
+PrepareUpdate retrieves the packages changed in the remote descriptor into
+a private staging directory, which does not require the app directory
+to be locked; it returns nil if there is nothing to stage - because the app
+is not installed, because its local descriptor skips the update check or
+because the update has already been staged.
+
+The returned update must be passed to CommitUpdate() - and then,
+in any case, to DiscardPreparedUpdate()
+*/
+func (app *App) PrepareUpdate(
+	ctx context.Context,
+	settings config.Settings,
+	progressCallback PackageProgressCallback) (update *PreparedUpdate, err error) {
+
+	localDescriptor := app.GetLocalDescriptor(ctx)
+	if localDescriptor == nil {
+		log.Notice("Skipping the staging, as the app is not installed")
+		return nil, nil
+	}
+
+	remoteDescriptor := app.GetRemoteDescriptor(ctx)
+	if remoteDescriptor == nil {
+		log.Notice("Skipping the staging, as the remote descriptor is missing")
+		return nil, ctx.Err()
+	}
+
+	packagesToUpdate := app.getPackagesToUpdate(ctx)
+	if len(packagesToUpdate) == 0 {
+		log.Notice("All the packages are up-to-date")
+		return nil, nil
+	}
+
+	stagedDescriptor := app.getStagedDescriptor(ctx)
+	if stagedDescriptor != nil && !remoteDescriptor.GetAppVersion().NewerThan(stagedDescriptor.GetAppVersion()) {
+		log.Notice("The update to version %v has already been staged", stagedDescriptor.GetAppVersion())
+		return nil, nil
+	}
+
+	localDescriptorBytes, err := ioutil.ReadFile(app.localDescriptorPath)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Creating the private staging directory...")
+	preparationDirectory, err := ioutil.TempDir(app.Directory, stagingDirName+".")
+	if err != nil {
+		return nil, err
+	}
+	log.Notice("Private staging directory created: '%v'", preparationDirectory)
+
+	update = &PreparedUpdate{
+		directory:            preparationDirectory,
+		descriptor:           remoteDescriptor,
+		localDescriptorBytes: localDescriptorBytes,
+	}
+
+	defer func() {
+		if err != nil {
+			app.DiscardPreparedUpdate(update)
+			update = nil
+		}
+	}()
+
+	preparedFilesDirectory := filepath.Join(preparationDirectory, filesDirName)
+
+	retrieveAllPackages := (len(packagesToUpdate) == len(remoteDescriptor.GetPackageVersions()))
+	log.Notice("Must retrieve all the remote packages? %v", retrieveAllPackages)
+
+	if !retrieveAllPackages && caravel.DirectoryExists(app.filesDirectory) {
+		log.Info("Copying the app files to the private staging directory...")
+		err = copyDirectory(app.filesDirectory, preparedFilesDirectory)
+		if err != nil {
+			return nil, err
+		}
+		log.Notice("App files copied")
+	}
+
+	for packageIndex, packageName := range packagesToUpdate {
+		log.Notice("Staging %v...", packageName)
+
+		packageOrdinal := packageIndex + 1
+
+		err = app.installPackage(
+			ctx,
+			packageName,
+			preparedFilesDirectory,
+			settings,
+			func(retrievedSize int64, totalSize int64) {
+				progressCallback(packageName, packageOrdinal, len(packagesToUpdate), retrievedSize, totalSize)
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Info("Saving the staged descriptor...")
+	remoteDescriptorBytes, err := remoteDescriptor.GetBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ioutil.WriteFile(
+		filepath.Join(preparationDirectory, app.bootDescriptor.GetDescriptorFileName()),
+		remoteDescriptorBytes,
+		0600)
+	if err != nil {
+		return nil, err
+	}
+	log.Notice("Update to version %v prepared", remoteDescriptor.GetAppVersion())
+
+	return update, nil
+}
+
+/*
+CommitUpdate turns the given prepared update into the staged update of the app,
+replacing any older staged update; the app directory must be locked by the caller.
+
+Nothing is staged - and false is returned - if the installed app or the staged
+update have changed while the update was being prepared
+*/
+func (app *App) CommitUpdate(ctx context.Context, update *PreparedUpdate) (staged bool, err error) {
+	app.removeAbandonedPreparations(update)
+
+	localDescriptorBytes, err := ioutil.ReadFile(app.localDescriptorPath)
+	if err != nil {
+		return false, err
+	}
+
+	if !bytes.Equal(localDescriptorBytes, update.localDescriptorBytes) {
+		log.Notice("Discarding the prepared update, as the app has changed in the meantime")
+		return false, nil
+	}
+
+	updateVersion := update.descriptor.GetAppVersion()
+
+	stagedDescriptor := app.getStagedDescriptor(ctx)
+	if stagedDescriptor != nil && !updateVersion.NewerThan(stagedDescriptor.GetAppVersion()) {
+		log.Notice("Discarding the prepared update, as version %v has already been staged",
+			stagedDescriptor.GetAppVersion())
+		return false, nil
+	}
+
+	err = app.discardStagedUpdate()
+	if err != nil {
+		return false, err
+	}
+
+	log.Info("Committing the prepared update...")
+	err = os.Rename(update.directory, app.getStagingDirectory())
+	if err != nil {
+		return false, err
+	}
+	log.Notice("Update to version %v staged", updateVersion)
+
+	return true, nil
+}
+
+/*
+DiscardPreparedUpdate deletes the private staging directory of the given update,
+unless it has been committed
+*/
+func (app *App) DiscardPreparedUpdate(update *PreparedUpdate) {
+	if !caravel.DirectoryExists(update.directory) {
+		return
+	}
+
+	log.Info("Removing the private staging directory...")
+	err := os.RemoveAll(update.directory)
+	if err != nil {
+		log.Warning("Could not remove the private staging directory: %v", err)
+		return
+	}
+	log.Notice("Private staging directory removed")
+}
+
+/*
+removeAbandonedPreparations deletes the private staging directories - other
+than the one of the given update - that have not been modified for
+abandonedPreparationAge
+*/
+func (app *App) removeAbandonedPreparations(update *PreparedUpdate) {
+	preparationDirectories, err := filepath.Glob(filepath.Join(app.Directory, stagingDirName+".*"))
+	if err != nil {
+		log.Warning(err.Error())
+		return
+	}
+
+	for _, preparationDirectory := range preparationDirectories {
+		if preparationDirectory == update.directory {
+			continue
+		}
+
+		info, err := os.Stat(preparationDirectory)
+		if err != nil || !info.IsDir() || time.Since(info.ModTime()) < abandonedPreparationAge {
+			continue
+		}
+
+		log.Info("Removing the abandoned private staging directory '%v'...", preparationDirectory)
+		err = os.RemoveAll(preparationDirectory)
+		if err != nil {
+			log.Warning("Could not remove the abandoned private staging directory: %v", err)
+			continue
+		}
+		log.Notice("Abandoned private staging directory removed")
+	}
+}
+
+/*
+DeferUpdate is an alternative to CheckFiles() while the app is running:
+the update is just staged, and the local descriptor becomes the reference
+descriptor - which is returned - so that the installed files are kept
+*/
+func (app *App) DeferUpdate(
+	ctx context.Context,
+	settings config.Settings,
+	progressCallback PackageProgressCallback) (referenceDescriptor descriptors.AppDescriptor, err error) {
+
+	localDescriptor := app.GetLocalDescriptor(ctx)
+	if localDescriptor == nil {
+		return nil, fmt.Errorf("Cannot defer the update: the app is not installed")
+	}
+
+	log.Info("Deferring the update, as the app is running...")
+	_, err = app.StageUpdate(ctx, settings, progressCallback)
+	if err != nil {
+		return nil, err
+	}
+
+	app.referenceDescriptor = localDescriptor
+	app.referenceDescriptorCached = true
+	log.Notice("Update deferred: the installed version will be used")
+
+	return localDescriptor, nil
+}
+
+/*
+getStagedDescriptor returns the descriptor of the staged update - which is
+saved last, so it only exists if the staging has been completed
+*/
+func (app *App) getStagedDescriptor(ctx context.Context) descriptors.AppDescriptor {
+	stagedDescriptorPath := app.getStagedDescriptorPath()
+	if !caravel.FileExists(stagedDescriptorPath) {
+		return nil
+	}
+
+	stagedDescriptor, err := descriptors.NewAppDescriptorFromPath(ctx, stagedDescriptorPath)
+	if err != nil {
+		log.Warning("Cannot open the staged descriptor: %v", err)
+		return nil
+	}
+
+	return stagedDescriptor
+}
+
+/*
+applyStagedUpdate replaces the app files with the staged ones, provided that
+the staged version is the one declared by the remote descriptor; an obsolete
+staged update is discarded. Nothing happens while the app is running
+*/
+func (app *App) applyStagedUpdate(ctx context.Context) (err error) {
+	stagedDescriptor := app.getStagedDescriptor(ctx)
+	if stagedDescriptor == nil {
+		return nil
+	}
+
+	if app.IsRunning() {
+		log.Notice("Keeping the staged update, as the app is running")
+		return nil
+	}
+
+	remoteDescriptor := app.GetRemoteDescriptor(ctx)
+	if remoteDescriptor == nil {
+		log.Notice("Keeping the staged update, as the remote descriptor is missing")
+		return nil
+	}
+
+	if remoteDescriptor.GetAppVersion().String() != stagedDescriptor.GetAppVersion().String() {
+		log.Notice("Discarding the staged update, as the remote descriptor has version %v",
+			remoteDescriptor.GetAppVersion())
+		return app.discardStagedUpdate()
+	}
+
+	log.Info("Applying the staged update to version %v...", stagedDescriptor.GetAppVersion())
+
+	if caravel.FileExists(app.localDescriptorPath) {
+		err = os.Remove(app.localDescriptorPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	//
+	//Should the swap fail, the app files will be retrieved from scratch
+	//
+	app.localDescriptor = nil
+	app.localDescriptorCached = true
+
+	replacedFilesDirectory := filepath.Join(app.Directory, replacedFilesDirName)
+
+	err = os.RemoveAll(replacedFilesDirectory)
+	if err != nil {
+		return err
+	}
+
+	if caravel.DirectoryExists(app.filesDirectory) {
+		err = os.Rename(app.filesDirectory, replacedFilesDirectory)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Rename(app.getStagedFilesDirectory(), app.filesDirectory)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(app.getStagedDescriptorPath(), app.localDescriptorPath)
+	if err != nil {
+		return err
+	}
+
+	app.localDescriptor = stagedDescriptor
+	app.localDescriptorCached = true
+
+	log.Notice("Staged update applied")
+
+	err = os.RemoveAll(replacedFilesDirectory)
+	if err != nil {
+		log.Warning("Could not remove the replaced app files: %v", err)
+	}
+
+	return app.discardStagedUpdate()
+}
+
+func (app *App) discardStagedUpdate() (err error) {
+	stagingDirectory := app.getStagingDirectory()
+	if !caravel.DirectoryExists(stagingDirectory) {
+		return nil
+	}
+
+	log.Info("Removing the staging directory...")
+	err = os.RemoveAll(stagingDirectory)
+	if err != nil {
+		return err
+	}
+	log.Notice("Staging directory removed")
+
+	return nil
+}
+
+/*
+copyDirectory recursively copies a directory tree, preserving
+the permissions and the symbolic links
+*/
+func copyDirectory(sourceDirectory string, targetDirectory string) (err error) {
+	return filepath.Walk(sourceDirectory, func(sourcePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(sourceDirectory, sourcePath)
+		if err != nil {
+			return err
+		}
+
+		targetPath := filepath.Join(targetDirectory, relativePath)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(targetPath, info.Mode().Perm()|0700)
+
+		case info.Mode()&os.ModeSymlink != 0:
+			linkTarget, err := os.Readlink(sourcePath)
+			if err != nil {
+				return err
+			}
+
+			return os.Symlink(linkTarget, targetPath)
+
+		default:
+			return copyFile(sourcePath, targetPath, info.Mode().Perm())
+		}
+	})
+}
+
+/*
+isPreparationDirectoryName returns true for the names of the private staging
+directories created by PrepareUpdate()
+*/
+func isPreparationDirectoryName(directoryName string) bool {
+	return strings.HasPrefix(directoryName, stagingDirName+".")
+}
@@ -0,0 +1,184 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package authoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/giancosta86/moondeploy/v3/apps"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+const generatedDescriptorVersion = "3.0"
+
+/*
+GenerationSettings are the fields of a generated descriptor that cannot
+be inferred from the packages
+*/
+type GenerationSettings struct {
+	BaseURL     string
+	Name        string
+	Version     string
+	Publisher   string
+	Description string
+	CommandLine []string
+}
+
+type generatedDescriptor struct {
+	DescriptorVersion string
+
+	BaseURL string
+
+	Name        string
+	Version     string
+	Publisher   string
+	Description string
+
+	SkipPackageLevels int `json:",omitempty"`
+
+	Packages    map[string]string
+	RawPackages map[string]generatedRawPackage `json:",omitempty"`
+
+	CommandLine []string
+}
+
+type generatedRawPackage struct {
+	Version    string
+	Executable bool `json:",omitempty"`
+}
+
+/*
+GenerateDescriptor creates a V3 descriptor declaring all the files in the given
+packages directory: archives become packages - all with the app version - while
+any other file becomes a raw package.
+
+SkipPackageLevels is set to 1 if every archive wraps its content into a single directory.
+The descriptor is validated - offline - before being returned.
+*/
+func GenerateDescriptor(packagesDirectory string, settings GenerationSettings) (descriptorBytes []byte, err error) {
+	log.Info("Scanning the packages directory: '%v'...", packagesDirectory)
+
+	fileInfos, err := ioutil.ReadDir(packagesDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor := generatedDescriptor{
+		DescriptorVersion: generatedDescriptorVersion,
+		BaseURL:           settings.BaseURL,
+		Name:              settings.Name,
+		Version:           settings.Version,
+		Publisher:         settings.Publisher,
+		Description:       settings.Description,
+		Packages:          make(map[string]string),
+		RawPackages:       make(map[string]generatedRawPackage),
+		CommandLine:       settings.CommandLine,
+	}
+
+	allArchivesWrapped := true
+
+	for _, fileInfo := range fileInfos {
+		packageName := fileInfo.Name()
+
+		if !fileInfo.Mode().IsRegular() ||
+			strings.HasPrefix(packageName, ".") ||
+			strings.HasSuffix(packageName, ".moondeploy") {
+			continue
+		}
+
+		if !apps.IsArchivePackageName(packageName) {
+			log.Notice("Raw package found: %v", packageName)
+
+			descriptor.RawPackages[packageName] = generatedRawPackage{
+				Version:    settings.Version,
+				Executable: fileInfo.Mode()&0111 != 0,
+			}
+			continue
+		}
+
+		log.Notice("Package found: %v", packageName)
+		descriptor.Packages[packageName] = settings.Version
+
+		entries, err := apps.ListPackageEntries(packageName, filepath.Join(packagesDirectory, packageName))
+		if err != nil {
+			return nil, fmt.Errorf("Cannot inspect package '%v': %v", packageName, err)
+		}
+
+		if !isWrappedPackage(entries) {
+			allArchivesWrapped = false
+		}
+	}
+
+	if len(descriptor.Packages) == 0 && len(descriptor.RawPackages) == 0 {
+		return nil, fmt.Errorf("No packages found in '%v'", packagesDirectory)
+	}
+
+	if len(descriptor.Packages) > 0 && allArchivesWrapped {
+		descriptor.SkipPackageLevels = 1
+	}
+
+	descriptorBytes, err = json.MarshalIndent(descriptor, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Validating the generated descriptor...")
+	_, err = descriptors.ParseAppDescriptorForPlatform(descriptorBytes, descriptors.GetCurrentPlatform())
+	if err != nil {
+		return nil, err
+	}
+	log.Notice("Generated descriptor validated")
+
+	return descriptorBytes, nil
+}
+
+/*
+isWrappedPackage returns true if all the content of the package
+is within a single top-level directory
+*/
+func isWrappedPackage(entries []apps.PackageEntry) bool {
+	topComponent := ""
+
+	for _, entry := range entries {
+		pathComponents := entry.GetPathComponents()
+
+		if len(pathComponents) == 0 {
+			continue
+		}
+
+		if len(pathComponents) == 1 && !entry.IsDirectory {
+			return false
+		}
+
+		if topComponent == "" {
+			topComponent = pathComponents[0]
+		} else if pathComponents[0] != topComponent {
+			return false
+		}
+	}
+
+	return topComponent != ""
+}
@@ -0,0 +1,292 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package authoring
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/giancosta86/caravel"
+
+	"github.com/giancosta86/moondeploy/v3/apps"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+var knownOperatingSystems = map[string]bool{
+	"aix":       true,
+	"android":   true,
+	"darwin":    true,
+	"dragonfly": true,
+	"freebsd":   true,
+	"illumos":   true,
+	"ios":       true,
+	"linux":     true,
+	"netbsd":    true,
+	"openbsd":   true,
+	"plan9":     true,
+	"solaris":   true,
+	"windows":   true,
+}
+
+type linter struct {
+	descriptorBytes   []byte
+	packagesDirectory string
+
+	warnings      []string
+	foundWarnings map[string]bool
+}
+
+/*
+Lint looks for common mistakes in a descriptor that is valid but will probably
+not work as expected - for each of the platforms it declares.
+
+If packagesDirectory is not empty, the packages it contains are inspected as well:
+in particular, their layout is compared with SkipPackageLevels.
+
+The descriptor is inspected offline, unless resolvingURLs is true: in that case,
+its actual base URL is resolved as well, and a failed resolution is a warning.
+*/
+func Lint(ctx context.Context, descriptorPath string, packagesDirectory string, resolvingURLs bool) (warnings []string, err error) {
+	descriptorBytes, err := ioutil.ReadFile(descriptorPath)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptorBytes, err = descriptors.ConvertToJSON(descriptorBytes, descriptors.GetEncodingFromFileName(descriptorPath))
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor, err := descriptors.ParseAppDescriptorForPlatform(descriptorBytes, descriptors.GetCurrentPlatform())
+	if err != nil {
+		return nil, err
+	}
+
+	linter := &linter{
+		descriptorBytes:   descriptorBytes,
+		packagesDirectory: packagesDirectory,
+		warnings:          []string{},
+		foundWarnings:     make(map[string]bool),
+	}
+
+	schemaWarnings, err := descriptors.CheckSchema(descriptorBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, schemaWarning := range schemaWarnings {
+		linter.addWarning("%v", schemaWarning)
+	}
+
+	if resolvingURLs {
+		err = linter.lintActualBaseURL(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	platforms := descriptor.GetDeclaredPlatforms()
+	if len(platforms) == 0 {
+		linter.lintDescriptor(descriptor, "Default settings")
+		return linter.warnings, nil
+	}
+
+	for _, platform := range platforms {
+		linter.lintPlatform(platform)
+	}
+
+	return linter.warnings, nil
+}
+
+func (linter *linter) addWarning(format string, args ...interface{}) {
+	warning := fmt.Sprintf(format, args...)
+
+	if linter.foundWarnings[warning] {
+		return
+	}
+
+	log.Warning(warning)
+
+	linter.foundWarnings[warning] = true
+	linter.warnings = append(linter.warnings, warning)
+}
+
+func (linter *linter) lintPlatform(platform descriptors.Platform) {
+	log.Info("Linting the descriptor for platform %v...", platform)
+
+	if !knownOperatingSystems[platform.OS] {
+		linter.addWarning("Unknown operating system: '%v'", platform.OS)
+	}
+
+	platformDescriptor, err := descriptors.ParseAppDescriptorForPlatform(linter.descriptorBytes, platform)
+	if err != nil {
+		invalidDescriptorErr, isInvalidDescriptor := err.(*descriptors.InvalidDescriptor)
+		if !isInvalidDescriptor {
+			linter.addWarning("%v: %v", platform, err)
+			return
+		}
+
+		for _, problem := range invalidDescriptorErr.Problems {
+			linter.addWarning("%v: %v", platform, problem)
+		}
+		return
+	}
+
+	linter.lintDescriptor(platformDescriptor, platform.String())
+}
+
+func (linter *linter) lintActualBaseURL(ctx context.Context) (err error) {
+	log.Info("Resolving the actual base URL...")
+
+	descriptor, err := descriptors.NewAppDescriptorFromBytes(ctx, linter.descriptorBytes)
+	if err != nil {
+		return err
+	}
+
+	actualBaseURLErr := descriptor.GetActualBaseURLError()
+	if actualBaseURLErr != nil {
+		linter.addWarning("Cannot resolve the actual base URL: %v", actualBaseURLErr)
+		return nil
+	}
+
+	log.Notice("Actual base URL: '%v'", descriptor.GetActualBaseURL().Redacted())
+
+	return nil
+}
+
+func (linter *linter) lintDescriptor(descriptor descriptors.AppDescriptor, platformLabel string) {
+	packageVersions := descriptor.GetPackageVersions()
+
+	if len(packageVersions) == 0 {
+		linter.addWarning("%v: no packages are declared", platformLabel)
+		return
+	}
+
+	packageNames := []string{}
+	for packageName := range packageVersions {
+		packageNames = append(packageNames, packageName)
+	}
+	sort.Strings(packageNames)
+
+	for _, packageName := range packageNames {
+		linter.lintPackage(descriptor, packageName)
+	}
+}
+
+func (linter *linter) lintPackage(descriptor descriptors.AppDescriptor, packageName string) {
+	isRawPackage := descriptor.GetRawPackage(packageName) != nil
+
+	if !isRawPackage && !apps.IsArchivePackageName(packageName) {
+		linter.addWarning(
+			"Package '%v' does not have an archive extension: its format will be inferred from its content. If it must be copied as it is, declare it in RawPackages",
+			packageName)
+	}
+
+	if linter.packagesDirectory == "" {
+		return
+	}
+
+	packagePath := filepath.Join(linter.packagesDirectory, filepath.FromSlash(packageName))
+	if !caravel.FileExists(packagePath) {
+		linter.addWarning("Package '%v' cannot be found in '%v'", packageName, linter.packagesDirectory)
+		return
+	}
+
+	if isRawPackage {
+		return
+	}
+
+	entries, err := apps.ListPackageEntries(packageName, packagePath)
+	if err != nil {
+		linter.addWarning("Cannot inspect package '%v': %v", packageName, err)
+		return
+	}
+
+	for _, layoutProblem := range getLayoutProblems(entries, descriptor.GetSkipPackageLevels()) {
+		linter.addWarning("Package '%v': %v", packageName, layoutProblem)
+	}
+}
+
+/*
+getLayoutProblems compares the structure of a package with the number of levels
+that will be skipped while extracting it
+*/
+func getLayoutProblems(entries []apps.PackageEntry, skipLevels int) (problems []string) {
+	problems = []string{}
+
+	if len(entries) == 0 {
+		return append(problems, "the package is empty")
+	}
+
+	droppedFiles := []string{}
+	skippedPrefixes := make(map[string]bool)
+	topComponents := make(map[string]bool)
+	hasTopLevelFiles := false
+
+	for _, entry := range entries {
+		pathComponents := entry.GetPathComponents()
+
+		if len(pathComponents) <= skipLevels {
+			if !entry.IsDirectory {
+				droppedFiles = append(droppedFiles, entry.Name)
+			}
+			continue
+		}
+
+		skippedPrefixes[strings.Join(pathComponents[:skipLevels], "/")] = true
+		topComponents[pathComponents[skipLevels]] = true
+
+		if len(pathComponents) == skipLevels+1 && !entry.IsDirectory {
+			hasTopLevelFiles = true
+		}
+	}
+
+	if len(droppedFiles) > 0 {
+		problems = append(problems,
+			fmt.Sprintf("%v file(s) would not be extracted, as SkipPackageLevels is %v - for example: '%v'",
+				len(droppedFiles),
+				skipLevels,
+				droppedFiles[0]))
+	}
+
+	if len(skippedPrefixes) > 1 {
+		problems = append(problems,
+			fmt.Sprintf("the content of %v different directories would be merged, as SkipPackageLevels is %v",
+				len(skippedPrefixes),
+				skipLevels))
+	}
+
+	if len(topComponents) == 1 && !hasTopLevelFiles {
+		for topComponent := range topComponents {
+			problems = append(problems,
+				fmt.Sprintf("all the content is within '%v' - SkipPackageLevels should probably be %v",
+					topComponent,
+					skipLevels+1))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,198 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package authoring
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/giancosta86/moondeploy/v3/apps"
+)
+
+/*
+createZipPackage zips the content of the source directory, prefixing each entry
+with the given wrapper path - that can be empty
+*/
+func createZipPackage(sourceDirectory string, zipPath string, wrapperPath string) (err error) {
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := zipFile.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	zipWriter := zip.NewWriter(zipFile)
+
+	err = filepath.Walk(sourceDirectory, func(sourcePath string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(sourceDirectory, sourcePath)
+		if err != nil {
+			return err
+		}
+
+		if relativePath == "." {
+			return nil
+		}
+
+		return addZipEntry(zipWriter, sourcePath, wrapperPath+filepath.ToSlash(relativePath), fileInfo)
+	})
+	if err != nil {
+		zipWriter.Close()
+		return err
+	}
+
+	return zipWriter.Close()
+}
+
+func addZipEntry(zipWriter *zip.Writer, sourcePath string, entryName string, fileInfo os.FileInfo) (err error) {
+	header, err := zip.FileInfoHeader(fileInfo)
+	if err != nil {
+		return err
+	}
+
+	header.Name = entryName
+
+	if fileInfo.IsDir() {
+		header.Name += "/"
+		_, err = zipWriter.CreateHeader(header)
+		return err
+	}
+
+	header.Method = zip.Deflate
+
+	entryWriter, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if fileInfo.Mode()&os.ModeSymlink != 0 {
+		linkTarget, err := os.Readlink(sourcePath)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(entryWriter, filepath.ToSlash(linkTarget))
+		return err
+	}
+
+	if !fileInfo.Mode().IsRegular() {
+		return fmt.Errorf("Unsupported file type: '%v'", sourcePath)
+	}
+
+	sourceFile, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	_, err = io.Copy(entryWriter, sourceFile)
+	return err
+}
+
+/*
+getZipContentDigest returns a digest of the files in a zip package - their paths
+after skipping the given levels, their executable flag and their content - so that
+packages with the same content match even if zipped with different tools
+*/
+func getZipContentDigest(zipPath string, skipLevels int) (digest string, err error) {
+	zipReader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return "", err
+	}
+	defer zipReader.Close()
+
+	zipEntries := make(map[string]*zip.File)
+	entryNames := []string{}
+
+	for _, zipEntry := range zipReader.File {
+		if zipEntry.Mode().IsDir() {
+			continue
+		}
+
+		pathComponents := apps.PackageEntry{Name: zipEntry.Name}.GetPathComponents()
+		if len(pathComponents) <= skipLevels {
+			continue
+		}
+
+		entryName := strings.Join(pathComponents[skipLevels:], "/")
+
+		zipEntries[entryName] = zipEntry
+		entryNames = append(entryNames, entryName)
+	}
+
+	sort.Strings(entryNames)
+
+	hash := sha256.New()
+
+	for _, entryName := range entryNames {
+		zipEntry := zipEntries[entryName]
+
+		fmt.Fprintf(hash, "%v\x00%v\x00%v\x00%v\x00",
+			entryName,
+			zipEntry.Mode()&os.ModeSymlink != 0,
+			zipEntry.Mode()&0111 != 0,
+			zipEntry.UncompressedSize64)
+
+		entryReader, err := zipEntry.Open()
+		if err != nil {
+			return "", err
+		}
+
+		_, err = io.Copy(hash, entryReader)
+		entryReader.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func getFileDigest(filePath string) (digest string, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,590 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package authoring
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/networking"
+	"github.com/giancosta86/moondeploy/v3/versioning"
+)
+
+const rawPackageSourceKey = "Source"
+
+/*
+ReleaseSettings describe a release.
+
+The spec is a V3 descriptor where each value of Packages - both default and
+OS-specific - is the path of a directory, relative to the build directory,
+that will be zipped; every raw package declares its file via a "Source" key
+instead of "Version".
+
+PreviousDescriptor is the path or the URL of the descriptor of the previous release:
+if it is empty, all the packages are considered new.
+*/
+type ReleaseSettings struct {
+	SpecPath           string
+	BuildDirectory     string
+	OutputDirectory    string
+	PreviousDescriptor string
+}
+
+/*
+ReleaseResult summarizes the outcome of a release
+*/
+type ReleaseResult struct {
+	Title             string
+	DescriptorPath    string
+	ChangedPackages   []string
+	UnchangedPackages []string
+}
+
+type releasePackage struct {
+	name    string
+	source  string
+	isRaw   bool
+	version string
+}
+
+type previousRelease struct {
+	descriptor       descriptors.AppDescriptor
+	packageVersions  map[string]string
+	packagesLocation string
+	isRemote         bool
+	tempDirectory    string
+}
+
+/*
+Release builds the packages declared by a release spec and writes them, together with
+the resulting descriptor, to the output directory.
+
+A package gets the new app version only if its content differs from the one
+in the previous release; otherwise, it keeps its previous version.
+*/
+func Release(ctx context.Context, settings ReleaseSettings) (result *ReleaseResult, err error) {
+	log.Info("Reading the release spec: '%v'...", settings.SpecPath)
+	spec, err := readDescriptorMap(settings.SpecPath)
+	if err != nil {
+		return nil, err
+	}
+	log.Notice("Release spec read")
+
+	appVersionString, _ := spec["Version"].(string)
+	appVersion, err := versioning.ParseVersion(appVersionString)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid app version in the release spec: '%v'", appVersionString)
+	}
+
+	descriptorVersionString, _ := spec["DescriptorVersion"].(string)
+	descriptorVersion, err := versioning.ParseVersion(descriptorVersionString)
+	if err != nil || descriptorVersion.Major != 3 {
+		return nil, fmt.Errorf("The release spec must declare a V3 descriptor - found: '%v'", descriptorVersionString)
+	}
+
+	skipLevels, err := getIntField(spec, "SkipPackageLevels")
+	if err != nil {
+		return nil, err
+	}
+
+	releasePackages, err := getReleasePackages(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(releasePackages) == 0 {
+		return nil, fmt.Errorf("The release spec declares no packages")
+	}
+
+	previous, err := loadPreviousRelease(ctx, settings.PreviousDescriptor)
+	if err != nil {
+		return nil, err
+	}
+	defer previous.dispose()
+
+	if previous != nil && !appVersion.NewerThan(previous.descriptor.GetAppVersion()) {
+		return nil, fmt.Errorf("The release version (%v) must be newer than the previous one (%v)",
+			appVersion,
+			previous.descriptor.GetAppVersion())
+	}
+
+	err = os.MkdirAll(settings.OutputDirectory, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	result = &ReleaseResult{
+		ChangedPackages:   []string{},
+		UnchangedPackages: []string{},
+	}
+
+	for _, releasePackage := range releasePackages {
+		changed, err := buildReleasePackage(ctx, releasePackage, settings, skipLevels, previous)
+		if err != nil {
+			return nil, err
+		}
+
+		if changed {
+			releasePackage.version = appVersion.String()
+			result.ChangedPackages = append(result.ChangedPackages, releasePackage.name)
+		} else {
+			releasePackage.version = previous.packageVersions[releasePackage.name]
+			result.UnchangedPackages = append(result.UnchangedPackages, releasePackage.name)
+		}
+
+		log.Notice("Package '%v' has version %v", releasePackage.name, releasePackage.version)
+	}
+
+	setReleasePackageVersions(spec, releasePackages)
+
+	descriptorBytes, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Validating the released descriptor...")
+	descriptor, err := descriptors.ParseAppDescriptorForPlatform(descriptorBytes, descriptors.GetCurrentPlatform())
+	if err != nil {
+		return nil, err
+	}
+
+	if previous != nil {
+		err = descriptors.CheckDescriptorMatch(previous.descriptor, descriptor)
+		if err != nil {
+			return nil, err
+		}
+	}
+	log.Notice("Released descriptor validated")
+
+	result.Title = descriptor.GetTitle()
+	result.DescriptorPath = filepath.Join(settings.OutputDirectory, descriptor.GetDescriptorFileName())
+
+	log.Info("Saving the released descriptor to '%v'...", result.DescriptorPath)
+	err = ioutil.WriteFile(result.DescriptorPath, descriptorBytes, 0644)
+	if err != nil {
+		return nil, err
+	}
+	log.Notice("Released descriptor saved")
+
+	return result, nil
+}
+
+func readDescriptorMap(descriptorPath string) (descriptorMap map[string]interface{}, err error) {
+	descriptorBytes, err := ioutil.ReadFile(descriptorPath)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptorBytes, err = descriptors.ConvertToJSON(descriptorBytes, descriptors.GetEncodingFromFileName(descriptorPath))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseDescriptorMap(descriptorBytes)
+}
+
+func parseDescriptorMap(descriptorBytes []byte) (descriptorMap map[string]interface{}, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(descriptorBytes))
+	decoder.UseNumber()
+
+	err = decoder.Decode(&descriptorMap)
+	if err != nil {
+		return nil, err
+	}
+
+	return descriptorMap, nil
+}
+
+func getIntField(descriptorMap map[string]interface{}, fieldName string) (value int, err error) {
+	rawValue, fieldFound := descriptorMap[fieldName]
+	if !fieldFound {
+		return 0, nil
+	}
+
+	numberValue, isNumber := rawValue.(json.Number)
+	if !isNumber {
+		return 0, fmt.Errorf("Field %v must be a number", fieldName)
+	}
+
+	int64Value, err := numberValue.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("Field %v must be an integer", fieldName)
+	}
+
+	return int(int64Value), nil
+}
+
+/*
+getPackageSections returns the maps declaring packages in a descriptor:
+the descriptor itself and each of its OS-specific sections
+*/
+func getPackageSections(descriptorMap map[string]interface{}) []map[string]interface{} {
+	sections := []map[string]interface{}{descriptorMap}
+
+	osSections, _ := descriptorMap["OS"].(map[string]interface{})
+
+	platformKeys := []string{}
+	for platformKey := range osSections {
+		platformKeys = append(platformKeys, platformKey)
+	}
+	sort.Strings(platformKeys)
+
+	for _, platformKey := range platformKeys {
+		osSection, isSection := osSections[platformKey].(map[string]interface{})
+		if isSection {
+			sections = append(sections, osSection)
+		}
+	}
+
+	return sections
+}
+
+func getReleasePackages(spec map[string]interface{}) (releasePackages []*releasePackage, err error) {
+	packagesByName := make(map[string]*releasePackage)
+
+	addPackage := func(newPackage *releasePackage) error {
+		existingPackage := packagesByName[newPackage.name]
+		if existingPackage == nil {
+			packagesByName[newPackage.name] = newPackage
+			return nil
+		}
+
+		if existingPackage.source != newPackage.source || existingPackage.isRaw != newPackage.isRaw {
+			return fmt.Errorf("Package '%v' is declared more than once, with different sources", newPackage.name)
+		}
+
+		return nil
+	}
+
+	for _, section := range getPackageSections(spec) {
+		packages, _ := section["Packages"].(map[string]interface{})
+		for packageName, rawSource := range packages {
+			source, _ := rawSource.(string)
+			if strings.TrimSpace(source) == "" {
+				return nil, fmt.Errorf("Package '%v' must declare its source directory", packageName)
+			}
+
+			if !strings.HasSuffix(strings.ToLower(packageName), ".zip") {
+				return nil, fmt.Errorf("Package '%v' must be a .zip file, or a raw package", packageName)
+			}
+
+			err = addPackage(&releasePackage{
+				name:   packageName,
+				source: source,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		rawPackages, _ := section["RawPackages"].(map[string]interface{})
+		for packageName, rawPackageValue := range rawPackages {
+			rawPackage, _ := rawPackageValue.(map[string]interface{})
+			source, _ := rawPackage[rawPackageSourceKey].(string)
+			if strings.TrimSpace(source) == "" {
+				return nil, fmt.Errorf("Raw package '%v' must declare its %v file", packageName, rawPackageSourceKey)
+			}
+
+			err = addPackage(&releasePackage{
+				name:   packageName,
+				source: source,
+				isRaw:  true,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	packageNames := []string{}
+	for packageName := range packagesByName {
+		packageNames = append(packageNames, packageName)
+	}
+	sort.Strings(packageNames)
+
+	releasePackages = []*releasePackage{}
+	for _, packageName := range packageNames {
+		releasePackages = append(releasePackages, packagesByName[packageName])
+	}
+
+	return releasePackages, nil
+}
+
+func setReleasePackageVersions(spec map[string]interface{}, releasePackages []*releasePackage) {
+	packageVersions := make(map[string]string)
+	for _, releasePackage := range releasePackages {
+		packageVersions[releasePackage.name] = releasePackage.version
+	}
+
+	for _, section := range getPackageSections(spec) {
+		packages, _ := section["Packages"].(map[string]interface{})
+		for packageName := range packages {
+			packages[packageName] = packageVersions[packageName]
+		}
+
+		rawPackages, _ := section["RawPackages"].(map[string]interface{})
+		for packageName, rawPackageValue := range rawPackages {
+			rawPackage := rawPackageValue.(map[string]interface{})
+
+			delete(rawPackage, rawPackageSourceKey)
+			rawPackage["Version"] = packageVersions[packageName]
+		}
+	}
+}
+
+/*
+buildReleasePackage writes the package to the output directory, returning true
+if its content differs from the same package in the previous release
+*/
+func buildReleasePackage(ctx context.Context, releasePackage *releasePackage, settings ReleaseSettings, skipLevels int, previous *previousRelease) (changed bool, err error) {
+	sourcePath := filepath.Join(settings.BuildDirectory, filepath.FromSlash(releasePackage.source))
+	targetPath := filepath.Join(settings.OutputDirectory, filepath.FromSlash(releasePackage.name))
+
+	err = os.MkdirAll(filepath.Dir(targetPath), 0755)
+	if err != nil {
+		return false, err
+	}
+
+	var getDigest func(packagePath string, skipLevels int) (string, error)
+
+	if releasePackage.isRaw {
+		log.Info("Copying raw package '%v' from '%v'...", releasePackage.name, sourcePath)
+		err = copyReleaseFile(sourcePath, targetPath)
+
+		getDigest = func(packagePath string, skipLevels int) (string, error) {
+			return getFileDigest(packagePath)
+		}
+	} else {
+		log.Info("Zipping package '%v' from '%v'...", releasePackage.name, sourcePath)
+
+		packageBaseName := strings.TrimSuffix(path.Base(releasePackage.name), path.Ext(releasePackage.name))
+		wrapperPath := strings.Repeat(packageBaseName+"/", skipLevels)
+
+		err = createZipPackage(sourcePath, targetPath, wrapperPath)
+
+		getDigest = getZipContentDigest
+	}
+	if err != nil {
+		return false, err
+	}
+	log.Notice("Package '%v' built", releasePackage.name)
+
+	if previous == nil || previous.packageVersions[releasePackage.name] == "" {
+		log.Notice("Package '%v' is new", releasePackage.name)
+		return true, nil
+	}
+
+	previousPackagePath, err := previous.getPackagePath(ctx, releasePackage.name)
+	if err != nil {
+		log.Warning("Cannot retrieve the previous version of package '%v' - considering it changed: %v", releasePackage.name, err)
+		return true, nil
+	}
+
+	previousDigest, err := getDigest(previousPackagePath, previous.descriptor.GetSkipPackageLevels())
+	if err != nil {
+		log.Warning("Cannot inspect the previous version of package '%v' - considering it changed: %v", releasePackage.name, err)
+		return true, nil
+	}
+
+	currentDigest, err := getDigest(targetPath, skipLevels)
+	if err != nil {
+		return false, err
+	}
+
+	return currentDigest != previousDigest, nil
+}
+
+func copyReleaseFile(sourcePath string, targetPath string) (err error) {
+	sourceFileInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return err
+	}
+
+	if !sourceFileInfo.Mode().IsRegular() {
+		return fmt.Errorf("Not a regular file: '%v'", sourcePath)
+	}
+
+	sourceFile, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	targetFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, sourceFileInfo.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := targetFile.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	_, err = io.Copy(targetFile, sourceFile)
+	return err
+}
+
+func loadPreviousRelease(ctx context.Context, previousDescriptorLocation string) (previous *previousRelease, err error) {
+	if previousDescriptorLocation == "" {
+		log.Notice("No previous release")
+		return nil, nil
+	}
+
+	log.Info("Loading the previous descriptor: '%v'...", previousDescriptorLocation)
+
+	previous = &previousRelease{}
+
+	var descriptorBytes []byte
+
+	previousDescriptorURL, err := url.Parse(previousDescriptorLocation)
+	if err == nil && (previousDescriptorURL.Scheme == "http" || previousDescriptorURL.Scheme == "https") {
+		previous.isRemote = true
+
+		descriptorBytes, err = networking.RetrieveFromURL(ctx, previousDescriptorURL)
+	} else {
+		previous.packagesLocation = filepath.Dir(previousDescriptorLocation)
+
+		descriptorBytes, err = ioutil.ReadFile(previousDescriptorLocation)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	descriptorBytes, err = descriptors.ConvertToJSON(descriptorBytes, descriptors.GetEncodingFromFileName(previousDescriptorLocation))
+	if err != nil {
+		return nil, err
+	}
+
+	previous.descriptor, err = descriptors.NewAppDescriptorFromBytes(ctx, descriptorBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid previous descriptor: %v", err)
+	}
+
+	descriptorMap, err := parseDescriptorMap(descriptorBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	previous.packageVersions = getDeclaredPackageVersions(descriptorMap)
+
+	log.Notice("Previous descriptor loaded: %v", previous.descriptor.GetTitle())
+
+	return previous, nil
+}
+
+/*
+getDeclaredPackageVersions returns the version of every package declared
+by a descriptor of any version, in any of its sections
+*/
+func getDeclaredPackageVersions(descriptorMap map[string]interface{}) map[string]string {
+	packageVersions := make(map[string]string)
+
+	addVersion := func(packageName string, rawVersion interface{}) {
+		version, _ := rawVersion.(string)
+
+		if version != "" && packageVersions[packageName] == "" {
+			packageVersions[packageName] = version
+		}
+	}
+
+	for _, section := range getPackageSections(descriptorMap) {
+		for _, packagesKey := range []string{"Packages", "PackageVersions"} {
+			packages, _ := section[packagesKey].(map[string]interface{})
+			for packageName, rawVersion := range packages {
+				addVersion(packageName, rawVersion)
+			}
+		}
+
+		rawPackages, _ := section["RawPackages"].(map[string]interface{})
+		for packageName, rawPackageValue := range rawPackages {
+			rawPackage, _ := rawPackageValue.(map[string]interface{})
+			addVersion(packageName, rawPackage["Version"])
+		}
+	}
+
+	return packageVersions
+}
+
+/*
+getPackagePath returns the local path of a package of the previous release,
+downloading it if the previous release is remote
+*/
+func (previous *previousRelease) getPackagePath(ctx context.Context, packageName string) (packagePath string, err error) {
+	if !previous.isRemote {
+		return filepath.Join(previous.packagesLocation, filepath.FromSlash(packageName)), nil
+	}
+
+	if previous.tempDirectory == "" {
+		previous.tempDirectory, err = ioutil.TempDir(os.TempDir(), "moondeploy-release")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	packageURL, err := previous.descriptor.GetRemoteFileURL(packageName)
+	if err != nil {
+		return "", err
+	}
+
+	log.Info("Retrieving the previous package: %v...", packageURL.Redacted())
+	packageBytes, err := networking.RetrieveFromURL(ctx, packageURL)
+	if err != nil {
+		return "", err
+	}
+	log.Notice("Previous package retrieved")
+
+	packageFile, err := ioutil.TempFile(previous.tempDirectory, path.Base(packageName))
+	if err != nil {
+		return "", err
+	}
+	defer packageFile.Close()
+
+	_, err = packageFile.Write(packageBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return packageFile.Name(), nil
+}
+
+func (previous *previousRelease) dispose() {
+	if previous == nil || previous.tempDirectory == "" {
+		return
+	}
+
+	err := os.RemoveAll(previous.tempDirectory)
+	if err != nil {
+		log.Warning("Cannot remove the temp directory: %v", err)
+	}
+}
@@ -29,7 +29,22 @@ type Settings interface {
 	GetBufferSize() int64
 	GetLoggingLevel() logging.Level
 	IsSkipAppOutput() bool
+	GetLaunchMode() string
 	GetBackgroundColor() int
 	GetForegroundColor() int
 	GetLogMaxAgeInHours() int
+	GetCredentialsFile() string
+	GetInsecureCredentialHosts() []string
+	GetAppLogMaxSizeInMB() int64
+	GetAppLogRetainedFiles() int
+	GetGitHubToken() string
+	GetGitHubEnterpriseHosts() []string
+	GetGiteaHosts() []string
+	GetActualBaseURLCacheTTLInMinutes() int
+	GetUpdateCheckIntervalInMinutes() int
+	GetLockTimeoutInSeconds() int
+	GetRetrievalAttempts() int
+	GetRetryDelayInMilliseconds() int
+	GetMaxPackageEntries() int
+	GetMaxPackageSizeInMB() int64
 }
@@ -0,0 +1,78 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package credentials
+
+import (
+	"net/http"
+	"strings"
+)
+
+/*
+Credentials are the secrets sent to a host requiring authentication:
+a non-empty Token - ignoring its surrounding whitespace - is sent as a Bearer token,
+otherwise Username and Password are sent via Basic authentication.
+
+They are redacted whenever formatted, so they cannot reach the logs.
+*/
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+func (credentials *Credentials) IsEmpty() bool {
+	return credentials == nil ||
+		(credentials.getToken() == "" && credentials.Username == "")
+}
+
+func (credentials *Credentials) getToken() string {
+	return strings.TrimSpace(credentials.Token)
+}
+
+func (credentials *Credentials) Apply(request *http.Request) {
+	if credentials.IsEmpty() {
+		return
+	}
+
+	token := credentials.getToken()
+
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		request.SetBasicAuth(credentials.Username, credentials.Password)
+	}
+}
+
+func (credentials *Credentials) String() string {
+	if credentials.IsEmpty() {
+		return "<no credentials>"
+	}
+
+	if credentials.getToken() != "" {
+		return "<bearer token>"
+	}
+
+	return "<basic credentials>"
+}
+
+func (credentials *Credentials) GoString() string {
+	return credentials.String()
+}
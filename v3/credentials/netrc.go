@@ -0,0 +1,105 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package credentials
+
+import (
+	"bufio"
+	"io/ioutil"
+	"strings"
+)
+
+/*
+parseNetrc supports the machine, default, login and password tokens of the
+.netrc format; macro definitions are skipped
+*/
+func parseNetrc(netrcPath string) (result map[string]*Credentials, defaultCredentials *Credentials, err error) {
+	netrcBytes, err := ioutil.ReadFile(netrcPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result = make(map[string]*Credentials)
+
+	var currentCredentials *Credentials
+	inMacro := false
+
+	lineScanner := bufio.NewScanner(strings.NewReader(string(netrcBytes)))
+
+	for lineScanner.Scan() {
+		line := lineScanner.Text()
+
+		if inMacro {
+			if strings.TrimSpace(line) == "" {
+				inMacro = false
+			}
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		tokens := strings.Fields(line)
+
+		for tokenIndex := 0; tokenIndex < len(tokens); tokenIndex++ {
+			token := tokens[tokenIndex]
+
+			nextToken := func() string {
+				if tokenIndex+1 < len(tokens) {
+					tokenIndex++
+					return tokens[tokenIndex]
+				}
+				return ""
+			}
+
+			switch token {
+			case "machine":
+				currentCredentials = &Credentials{}
+				result[nextToken()] = currentCredentials
+
+			case "default":
+				currentCredentials = &Credentials{}
+				defaultCredentials = currentCredentials
+
+			case "login":
+				value := nextToken()
+				if currentCredentials != nil {
+					currentCredentials.Username = value
+				}
+
+			case "password":
+				value := nextToken()
+				if currentCredentials != nil {
+					currentCredentials.Password = value
+				}
+
+			case "account":
+				nextToken()
+
+			case "macdef":
+				inMacro = true
+				tokenIndex = len(tokens)
+			}
+		}
+	}
+
+	return result, defaultCredentials, lineScanner.Err()
+}
@@ -0,0 +1,268 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package credentials
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/giancosta86/caravel"
+
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+/*
+Prompt interactively asks the user for the credentials of the given host.
+It must return nil if the user declines.
+*/
+type Prompt func(host string) *Credentials
+
+const environmentVariablePrefix = "MOONDEPLOY_"
+
+var environmentVariableHostRegex = regexp.MustCompile(`[^A-Z0-9]+`)
+
+var fileCredentials = make(map[string]*Credentials)
+var netrcCredentials = make(map[string]*Credentials)
+var netrcDefaultCredentials *Credentials
+var sessionCredentials = make(map[string]*Credentials)
+var insecureHosts = make(map[string]bool)
+
+var prompt Prompt = func(host string) *Credentials { return nil }
+
+var mutex sync.Mutex
+
+/*
+Setup loads the per-host credentials from the given credentials file (a JSON
+object mapping each host to its Username/Password or Token) and from the user's
+.netrc file. Missing files are not an error.
+
+Credentials are sent over plain HTTP only to the given insecure hosts.
+*/
+func Setup(credentialsFilePath string, insecureCredentialHosts []string) (err error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	fileCredentials = make(map[string]*Credentials)
+	netrcCredentials = make(map[string]*Credentials)
+	netrcDefaultCredentials = nil
+
+	insecureHosts = make(map[string]bool)
+	for _, insecureHost := range insecureCredentialHosts {
+		insecureHosts[strings.ToLower(insecureHost)] = true
+	}
+
+	if credentialsFilePath != "" && caravel.FileExists(credentialsFilePath) {
+		log.Debug("Loading the credentials file: '%v'...", credentialsFilePath)
+
+		credentialsBytes, err := ioutil.ReadFile(credentialsFilePath)
+		if err != nil {
+			return err
+		}
+
+		err = json.Unmarshal(credentialsBytes, &fileCredentials)
+		if err != nil {
+			return err
+		}
+
+		log.Debug("Credentials file loaded: %v host(s) found", len(fileCredentials))
+	}
+
+	netrcPath := getNetrcPath()
+	if netrcPath != "" && caravel.FileExists(netrcPath) {
+		log.Debug("Loading the netrc file: '%v'...", netrcPath)
+
+		netrcCredentials, netrcDefaultCredentials, err = parseNetrc(netrcPath)
+		if err != nil {
+			return err
+		}
+
+		log.Debug("Netrc file loaded: %v host(s) found", len(netrcCredentials))
+	}
+
+	return nil
+}
+
+func getNetrcPath() string {
+	netrcPath := os.Getenv("NETRC")
+	if netrcPath != "" {
+		return netrcPath
+	}
+
+	userDir, err := caravel.GetUserDirectory()
+	if err != nil {
+		return ""
+	}
+
+	if runtime.GOOS == "windows" {
+		return filepath.Join(userDir, "_netrc")
+	}
+
+	return filepath.Join(userDir, ".netrc")
+}
+
+/*
+SetPrompt sets the function called when a host requires credentials
+that are not available - or have been rejected
+*/
+func SetPrompt(credentialsPrompt Prompt) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	prompt = credentialsPrompt
+}
+
+/*
+Lookup returns the credentials for the given host - which can include a port -
+or nil if none are available.
+
+The sources are checked in this order: credentials provided during the current
+session, environment variables (MOONDEPLOY_TOKEN_<HOST> or
+MOONDEPLOY_USERNAME_<HOST> and MOONDEPLOY_PASSWORD_<HOST>, where <HOST> is
+uppercase with every non-alphanumeric sequence replaced by '_'),
+the credentials file and, finally, .netrc.
+
+The "default" entry of .netrc is never returned, as it would be sent to every host:
+see LookupDefault().
+*/
+func Lookup(host string) *Credentials {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, candidateHost := range getCandidateHosts(host) {
+		if credentials := sessionCredentials[candidateHost]; !credentials.IsEmpty() {
+			return credentials
+		}
+
+		if credentials := getEnvironmentCredentials(candidateHost); !credentials.IsEmpty() {
+			return credentials
+		}
+
+		if credentials := fileCredentials[candidateHost]; !credentials.IsEmpty() {
+			return credentials
+		}
+
+		if credentials := netrcCredentials[candidateHost]; !credentials.IsEmpty() {
+			return credentials
+		}
+	}
+
+	return nil
+}
+
+/*
+LookupDefault returns the "default" entry of .netrc, or nil; it should only be sent
+to a host that has just required authentication
+*/
+func LookupDefault() *Credentials {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if netrcDefaultCredentials.IsEmpty() {
+		return nil
+	}
+
+	return netrcDefaultCredentials
+}
+
+/*
+AcceptsCredentials returns true if credentials can be sent to the given URL:
+that is, if it is an HTTPS URL or its host has been declared as insecure in Setup()
+*/
+func AcceptsCredentials(requestURL *url.URL) bool {
+	if strings.EqualFold(requestURL.Scheme, "https") {
+		return true
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, candidateHost := range getCandidateHosts(strings.ToLower(requestURL.Host)) {
+		if insecureHosts[candidateHost] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getCandidateHosts(host string) []string {
+	hostName := host
+	if colonIndex := strings.LastIndex(host, ":"); colonIndex >= 0 && !strings.HasSuffix(host, "]") {
+		hostName = host[:colonIndex]
+	}
+
+	if hostName == host {
+		return []string{host}
+	}
+
+	return []string{host, hostName}
+}
+
+func getEnvironmentCredentials(host string) *Credentials {
+	hostSuffix := strings.Trim(
+		environmentVariableHostRegex.ReplaceAllString(strings.ToUpper(host), "_"),
+		"_")
+
+	return &Credentials{
+		Token:    os.Getenv(environmentVariablePrefix + "TOKEN_" + hostSuffix),
+		Username: os.Getenv(environmentVariablePrefix + "USERNAME_" + hostSuffix),
+		Password: os.Getenv(environmentVariablePrefix + "PASSWORD_" + hostSuffix),
+	}
+}
+
+/*
+Ask calls the current prompt for the given host; the credentials it returns
+are kept in memory - and never persisted - for the rest of the session
+*/
+func Ask(host string) *Credentials {
+	mutex.Lock()
+	currentPrompt := prompt
+	mutex.Unlock()
+
+	credentials := currentPrompt(host)
+	if credentials.IsEmpty() {
+		return nil
+	}
+
+	mutex.Lock()
+	sessionCredentials[host] = credentials
+	mutex.Unlock()
+
+	return credentials
+}
+
+/*
+Forget discards the session credentials for the given host, for example
+because the server has rejected them
+*/
+func Forget(host string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	delete(sessionCredentials, host)
+}
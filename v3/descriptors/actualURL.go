@@ -21,25 +21,87 @@
 package descriptors
 
 import (
+	"context"
 	"net/url"
+	"regexp"
 
 	"github.com/giancosta86/moondeploy/v3/gitHubUtils"
+	"github.com/giancosta86/moondeploy/v3/gitLabUtils"
+	"github.com/giancosta86/moondeploy/v3/giteaUtils"
 	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/networking"
 )
 
-type actualBaseURLSearchStrategy func(AppDescriptor) *url.URL
+/*
+ActualBaseURLSearchStrategy tries to find the actual base URL referenced
+by the declared base URL of a descriptor - for example, the versioned URL
+of the latest release of a project - returning a nil URL if it is not applicable.
 
-var actualBaseURLCache = make(map[string]*url.URL)
+An error means that the strategy is applicable but could not resolve the URL.
+*/
+type ActualBaseURLSearchStrategy func(context.Context, AppDescriptor) (*url.URL, error)
+
+/*
+ActualBaseURLSearchStrategies are tried in order whenever a descriptor is
+initialized: the first non-nil URL becomes its actual base URL; if none of
+them succeeds, the actual base URL matches the declared base URL.
+If a strategy fails, the search stops and the error is available
+via the descriptor's GetActualBaseURLError().
+
+Custom installers can alter the list - or call RegisterActualBaseURLSearchStrategy() -
+before creating any descriptor.
+*/
+var ActualBaseURLSearchStrategies = []ActualBaseURLSearchStrategy{
+	LookForActualURLInCache,
+	LookForActualURLOnGitHub,
+	LookForActualURLOnGitLab,
+	LookForActualURLOnGitea,
+	LookForActualURLViaRedirect}
+
+/*
+RedirectingBaseURLRegex is matched against the path of the declared base URL
+to decide whether LookForActualURLViaRedirect should send a request
+*/
+var RedirectingBaseURLRegex = regexp.MustCompile(`/latest/$`)
+
+/*
+RegisterActualBaseURLSearchStrategy adds a strategy right after the cache
+lookup, so that it takes precedence over the built-in strategies
+*/
+func RegisterActualBaseURLSearchStrategy(searchStrategy ActualBaseURLSearchStrategy) {
+	insertionIndex := 0
+	if len(ActualBaseURLSearchStrategies) > 0 {
+		insertionIndex = 1
+	}
+
+	ActualBaseURLSearchStrategies = append(
+		ActualBaseURLSearchStrategies[:insertionIndex],
+		append(
+			[]ActualBaseURLSearchStrategy{searchStrategy},
+			ActualBaseURLSearchStrategies[insertionIndex:]...)...)
+}
+
+func getActualBaseURL(ctx context.Context, descriptor AppDescriptor) (actualBaseURL *url.URL, err error) {
+	declaredBaseURL := descriptor.GetDeclaredBaseURL()
 
-var actualBaseURLSearchStrategies = []actualBaseURLSearchStrategy{
-	lookForActualURLInCache,
-	lookForActualURLOnGitHub}
+	for _, searchStrategy := range ActualBaseURLSearchStrategies {
+		actualBaseURL, err = searchStrategy(ctx, descriptor)
+
+		if ctx.Err() != nil {
+			return declaredBaseURL, ctx.Err()
+		}
+
+		if err != nil {
+			log.Warning("Cannot find the actual base URL: %v", err)
 
-func getActualBaseURL(descriptor AppDescriptor) *url.URL {
-	var actualBaseURL *url.URL
+			expiredActualBaseURL := actualBaseURLCache.get(declaredBaseURL, true)
+			if expiredActualBaseURL != nil {
+				log.Notice("Employing the expired actual base URL found in the cache: '%v'", expiredActualBaseURL.Redacted())
+				return expiredActualBaseURL, nil
+			}
 
-	for _, searchStrategy := range actualBaseURLSearchStrategies {
-		actualBaseURL = searchStrategy(descriptor)
+			return declaredBaseURL, err
+		}
 
 		if actualBaseURL != nil {
 			log.Notice("The actual base URL has been found by a search strategy!")
@@ -47,51 +109,163 @@ func getActualBaseURL(descriptor AppDescriptor) *url.URL {
 		}
 	}
 
-	if actualBaseURL == nil {
+	if actualBaseURL == nil || actualBaseURL.String() == declaredBaseURL.String() {
 		log.Debug("The actual base URL just matches the declared base URL")
-		actualBaseURL = descriptor.GetDeclaredBaseURL()
+		InvalidateActualBaseURL(declaredBaseURL)
+		return declaredBaseURL, nil
 	}
 
-	actualBaseURLCache[descriptor.GetDeclaredBaseURL().String()] = actualBaseURL
+	actualBaseURLCache.put(declaredBaseURL, actualBaseURL)
 
-	return actualBaseURL
+	return actualBaseURL, nil
 }
 
-func lookForActualURLInCache(descriptor AppDescriptor) *url.URL {
+func LookForActualURLInCache(ctx context.Context, descriptor AppDescriptor) (*url.URL, error) {
 	log.Debug("Checking if the Base URL is a key of the Actual Base URL cache...")
 
-	cachedActualURL := actualBaseURLCache[descriptor.GetDeclaredBaseURL().String()]
+	cachedActualURL := actualBaseURLCache.get(descriptor.GetDeclaredBaseURL(), false)
 
 	if cachedActualURL != nil {
-		log.Debug("Actual URL found in the cache! '%v'", cachedActualURL)
-		return cachedActualURL
+		log.Debug("Actual URL found in the cache! '%v'", cachedActualURL.Redacted())
+		return cachedActualURL, nil
 	}
 
 	log.Debug("Actual URL not in the cache")
-	return nil
+	return nil, nil
 }
 
-func lookForActualURLOnGitHub(descriptor AppDescriptor) *url.URL {
+/*
+LookForActualURLOnGitHub supports base URLs such as
+https://github.com/<owner>/<repo>/releases/latest - or the equivalent URLs
+on the configured GitHub Enterprise hosts - honouring the descriptor's
+release selection
+*/
+func LookForActualURLOnGitHub(ctx context.Context, descriptor AppDescriptor) (*url.URL, error) {
 	log.Debug("Checking if the Declared Base URL points to the 'latest' release of a GitHub repo...")
-	gitHubDescriptorInfo := gitHubUtils.GetGitHubDescriptorInfo(
+	gitHubDescriptorInfo, err := gitHubUtils.GetGitHubDescriptorInfo(
+		ctx,
 		descriptor.GetDeclaredBaseURL(),
-		descriptor.GetDescriptorFileName())
+		descriptor.GetDescriptorFileName(),
+		descriptor.GetGitHubReleaseSelection())
+	if err != nil {
+		return nil, err
+	}
 
 	if gitHubDescriptorInfo != nil {
 		log.Debug("The given base URL actually references version '%v', whose descriptor is at URL: '%v'",
 			gitHubDescriptorInfo.Version,
-			gitHubDescriptorInfo.DescriptorURL)
+			gitHubDescriptorInfo.DescriptorURL.Redacted())
 
-		parentDirURL, err := url.Parse(".")
-		if err != nil {
-			panic(err)
-		}
+		actualBaseURL := getParentDirURL(gitHubDescriptorInfo.DescriptorURL)
+
+		log.Debug("The actual base URL returned by the GitHub API is: '%v'", actualBaseURL.Redacted())
+		return actualBaseURL, nil
+	}
 
-		actualBaseURL := gitHubDescriptorInfo.DescriptorURL.ResolveReference(parentDirURL)
+	return nil, nil
+}
+
+/*
+LookForActualURLOnGitLab supports base URLs such as
+https://<GitLab server>/<group>/<project>/-/releases/permalink/latest
+*/
+func LookForActualURLOnGitLab(ctx context.Context, descriptor AppDescriptor) (*url.URL, error) {
+	log.Debug("Checking if the Declared Base URL points to the 'latest' release of a GitLab project...")
+	gitLabDescriptorInfo, err := gitLabUtils.GetGitLabDescriptorInfo(
+		ctx,
+		descriptor.GetDeclaredBaseURL(),
+		descriptor.GetDescriptorFileName())
+	if err != nil {
+		return nil, err
+	}
+
+	if gitLabDescriptorInfo != nil {
+		log.Debug("The given base URL actually references version '%v', whose descriptor is at URL: '%v'",
+			gitLabDescriptorInfo.Version,
+			gitLabDescriptorInfo.DescriptorURL.Redacted())
+
+		actualBaseURL := getParentDirURL(gitLabDescriptorInfo.DescriptorURL)
+
+		log.Debug("The actual base URL returned by the GitLab API is: '%v'", actualBaseURL.Redacted())
+		return actualBaseURL, nil
+	}
+
+	return nil, nil
+}
+
+/*
+LookForActualURLOnGitea supports base URLs such as
+https://<Gitea server>/<owner>/<repo>/releases/latest
+*/
+func LookForActualURLOnGitea(ctx context.Context, descriptor AppDescriptor) (*url.URL, error) {
+	log.Debug("Checking if the Declared Base URL points to the 'latest' release of a Gitea repo...")
+	giteaDescriptorInfo, err := giteaUtils.GetGiteaDescriptorInfo(
+		ctx,
+		descriptor.GetDeclaredBaseURL(),
+		descriptor.GetDescriptorFileName())
+	if err != nil {
+		return nil, err
+	}
+
+	if giteaDescriptorInfo != nil {
+		log.Debug("The given base URL actually references version '%v', whose descriptor is at URL: '%v'",
+			giteaDescriptorInfo.Version,
+			giteaDescriptorInfo.DescriptorURL.Redacted())
+
+		actualBaseURL := getParentDirURL(giteaDescriptorInfo.DescriptorURL)
+
+		log.Debug("The actual base URL returned by the Gitea API is: '%v'", actualBaseURL.Redacted())
+		return actualBaseURL, nil
+	}
+
+	return nil, nil
+}
+
+/*
+LookForActualURLViaRedirect supports plain HTTP servers - including S3 buckets
+with redirection rules - redirecting the descriptor of a declared base URL
+matching RedirectingBaseURLRegex (by default, ending with /latest/)
+to a versioned path: the directory of the URL finally reached
+becomes the actual base URL
+*/
+func LookForActualURLViaRedirect(ctx context.Context, descriptor AppDescriptor) (*url.URL, error) {
+	declaredBaseURL := descriptor.GetDeclaredBaseURL()
+
+	log.Debug("Checking if the Declared Base URL should be resolved by following redirects...")
+	if !RedirectingBaseURLRegex.MatchString(declaredBaseURL.Path) {
+		log.Debug("The Declared Base URL does not match the redirecting base URL pattern")
+		return nil, nil
+	}
+
+	descriptorRelativeURL, err := url.Parse(descriptor.GetDescriptorFileName())
+	if err != nil {
+		return nil, err
+	}
+
+	descriptorURL := declaredBaseURL.ResolveReference(descriptorRelativeURL)
+
+	log.Debug("Following the redirects of '%v'...", descriptorURL.Redacted())
+	finalDescriptorURL, err := networking.ResolveRedirects(ctx, descriptorURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if finalDescriptorURL.String() == descriptorURL.String() {
+		log.Debug("The server did not redirect the descriptor URL")
+		return nil, nil
+	}
+
+	actualBaseURL := getParentDirURL(finalDescriptorURL)
+
+	log.Debug("The actual base URL reached by following the redirects is: '%v'", actualBaseURL.Redacted())
+	return actualBaseURL, nil
+}
 
-		log.Debug("The actual base URL returned by the GitHub API is: '%v'", actualBaseURL)
-		return actualBaseURL
+func getParentDirURL(fileURL *url.URL) *url.URL {
+	parentDirURL, err := url.Parse(".")
+	if err != nil {
+		panic(err)
 	}
 
-	return nil
+	return fileURL.ResolveReference(parentDirURL)
 }
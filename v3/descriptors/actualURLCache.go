@@ -0,0 +1,407 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package descriptors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/giancosta86/LockAPI/lockapi"
+	"github.com/giancosta86/caravel"
+
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+/*
+cacheFileLockTimeout is how long a process waits for the other processes
+to finish updating the cache file
+*/
+const cacheFileLockTimeout = 5 * time.Second
+
+const cacheFileLockPollingInterval = 50 * time.Millisecond
+
+type actualBaseURLCacheEntry struct {
+	ActualBaseURL string
+	ResolvedAt    time.Time
+
+	actualBaseURL *url.URL
+}
+
+/*
+actualBaseURLCacheStruct maps each declared base URL to its actual base URL;
+it is safe for concurrent use and, once set up, it is persisted to a file
+shared by every MoonDeploy process: every change is merged into the latest
+content of the file, while holding a lock on a companion lock file
+*/
+type actualBaseURLCacheStruct struct {
+	mutex    sync.Mutex
+	entries  map[string]*actualBaseURLCacheEntry
+	filePath string
+	ttl      time.Duration
+}
+
+var actualBaseURLCache = &actualBaseURLCacheStruct{
+	entries: make(map[string]*actualBaseURLCacheEntry),
+}
+
+/*
+SetupActualBaseURLCache loads the actual base URLs resolved by previous runs
+from the given file - where newly resolved URLs will be saved, too.
+
+Entries older than ttl are ignored - and resolved again - unless every search
+strategy fails, for example when offline; a ttl <= 0 disables the persistence,
+just like an empty file path.
+
+Without calling this function, the cache only lives in memory - and its entries
+never expire: long-running processes should call ResetActualBaseURLCache
+periodically.
+*/
+func SetupActualBaseURLCache(cacheFilePath string, ttl time.Duration) (err error) {
+	actualBaseURLCache.mutex.Lock()
+	defer actualBaseURLCache.mutex.Unlock()
+
+	actualBaseURLCache.entries = make(map[string]*actualBaseURLCacheEntry)
+
+	if ttl <= 0 {
+		log.Debug("The persistence of the actual base URL cache is disabled")
+		actualBaseURLCache.filePath = ""
+		actualBaseURLCache.ttl = 0
+		return nil
+	}
+
+	actualBaseURLCache.filePath = cacheFilePath
+	actualBaseURLCache.ttl = ttl
+
+	if cacheFilePath == "" {
+		return nil
+	}
+
+	log.Debug("Loading the actual base URL cache: '%v'...", cacheFilePath)
+
+	entries, err := readCacheFile(cacheFilePath)
+	if err != nil {
+		return err
+	}
+
+	actualBaseURLCache.entries = entries
+
+	log.Debug("Actual base URL cache loaded: %v entries found", len(actualBaseURLCache.entries))
+
+	return nil
+}
+
+/*
+ResetActualBaseURLCache discards the entries in memory - reloading them from
+the cache file, if the cache is persistent - so that the actual base URLs
+will be resolved again unless another process has resolved them in the
+meantime; the cache file itself is left untouched
+*/
+func ResetActualBaseURLCache() {
+	actualBaseURLCache.mutex.Lock()
+	defer actualBaseURLCache.mutex.Unlock()
+
+	log.Debug("Resetting the actual base URL cache...")
+
+	actualBaseURLCache.entries = make(map[string]*actualBaseURLCacheEntry)
+
+	if actualBaseURLCache.filePath == "" {
+		return
+	}
+
+	entries, err := readCacheFile(actualBaseURLCache.filePath)
+	if err != nil {
+		log.Warning("Cannot reload the actual base URL cache: %v", err)
+		return
+	}
+
+	actualBaseURLCache.entries = entries
+
+	log.Debug("Actual base URL cache reset: %v entries found", len(entries))
+}
+
+/*
+InvalidateActualBaseURL removes the actual base URL cached for the given
+declared base URL, so that it will be resolved again
+*/
+func InvalidateActualBaseURL(declaredBaseURL *url.URL) {
+	actualBaseURLCache.mutex.Lock()
+	defer actualBaseURLCache.mutex.Unlock()
+
+	key := getCacheKey(declaredBaseURL)
+
+	if actualBaseURLCache.entries[key] == nil {
+		return
+	}
+
+	log.Info("Invalidating the cached actual base URL for '%v'...", declaredBaseURL.Redacted())
+
+	actualBaseURLCache.update(func(entries map[string]*actualBaseURLCacheEntry) {
+		delete(entries, key)
+	})
+}
+
+/*
+ClearActualBaseURLCache removes every cached actual base URL - from the file
+as well, if the cache is persistent - so that they will be resolved again
+*/
+func ClearActualBaseURLCache() (err error) {
+	actualBaseURLCache.mutex.Lock()
+	defer actualBaseURLCache.mutex.Unlock()
+
+	log.Info("Clearing the actual base URL cache...")
+	err = actualBaseURLCache.tryUpdate(func(entries map[string]*actualBaseURLCacheEntry) {
+		for key := range entries {
+			delete(entries, key)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	log.Notice("Actual base URL cache cleared")
+
+	return nil
+}
+
+/*
+get returns the actual base URL cached for the given declared base URL;
+expired entries are returned only if acceptExpired is true
+*/
+func (cache *actualBaseURLCacheStruct) get(declaredBaseURL *url.URL, acceptExpired bool) *url.URL {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	entry := cache.entries[getCacheKey(declaredBaseURL)]
+	if entry == nil {
+		return nil
+	}
+
+	if !acceptExpired && cache.ttl > 0 && time.Since(entry.ResolvedAt) > cache.ttl {
+		log.Debug("The cached actual URL has expired")
+		return nil
+	}
+
+	return entry.actualBaseURL
+}
+
+/*
+put caches the actual base URL of the given declared base URL - unless the
+actual base URL includes a password, which must never be saved
+*/
+func (cache *actualBaseURLCacheStruct) put(declaredBaseURL *url.URL, actualBaseURL *url.URL) {
+	if _, hasPassword := actualBaseURL.User.Password(); hasPassword {
+		log.Debug("The actual base URL includes a password, so it will not be cached")
+		return
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	key := getCacheKey(declaredBaseURL)
+
+	existingEntry := cache.entries[key]
+	if existingEntry != nil &&
+		existingEntry.ActualBaseURL == actualBaseURL.String() &&
+		(cache.ttl <= 0 || time.Since(existingEntry.ResolvedAt) <= cache.ttl) {
+		return
+	}
+
+	newEntry := &actualBaseURLCacheEntry{
+		ActualBaseURL: actualBaseURL.String(),
+		ResolvedAt:    time.Now(),
+		actualBaseURL: actualBaseURL,
+	}
+
+	cache.update(func(entries map[string]*actualBaseURLCacheEntry) {
+		entries[key] = newEntry
+	})
+}
+
+/*
+update applies the given change to the cache, logging any error - as the cache
+is just an optimization; it must be called while holding the mutex
+*/
+func (cache *actualBaseURLCacheStruct) update(change func(entries map[string]*actualBaseURLCacheEntry)) {
+	err := cache.tryUpdate(change)
+	if err != nil {
+		log.Warning("Cannot save the actual base URL cache: %v", err)
+	}
+}
+
+/*
+tryUpdate applies the given change to the entries in memory and - if the cache
+is persistent - to the latest content of the cache file, which is then saved;
+this way, the entries saved by other processes in the meantime are preserved.
+It must be called while holding the mutex
+*/
+func (cache *actualBaseURLCacheStruct) tryUpdate(change func(entries map[string]*actualBaseURLCacheEntry)) (err error) {
+	change(cache.entries)
+
+	if cache.filePath == "" {
+		return nil
+	}
+
+	err = os.MkdirAll(filepath.Dir(cache.filePath), 0700)
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := lockCacheFile(cache.filePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		lockapi.UnlockFile(lockFile)
+		lockFile.Close()
+	}()
+
+	entries, err := readCacheFile(cache.filePath)
+	if err != nil {
+		log.Warning("Cannot read the actual base URL cache, which will be overwritten: %v", err)
+		entries = make(map[string]*actualBaseURLCacheEntry)
+	}
+
+	change(entries)
+
+	err = writeCacheFile(cache.filePath, entries)
+	if err != nil {
+		return err
+	}
+
+	cache.entries = entries
+
+	return nil
+}
+
+/*
+lockCacheFile locks the companion lock file of the cache file, waiting
+for at most cacheFileLockTimeout; the lock file is never deleted, so that
+all the processes always lock the very same file
+*/
+func lockCacheFile(cacheFilePath string) (lockFile *os.File, err error) {
+	lockFile, err = os.OpenFile(cacheFilePath+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(cacheFileLockTimeout)
+
+	for {
+		err = lockapi.TryLockFile(lockFile)
+		if err == nil {
+			return lockFile, nil
+		}
+
+		if !time.Now().Before(deadline) {
+			lockFile.Close()
+			return nil, fmt.Errorf("The actual base URL cache is locked by another process")
+		}
+
+		time.Sleep(cacheFileLockPollingInterval)
+	}
+}
+
+/*
+readCacheFile returns the valid entries of the given cache file - no entries
+if it does not exist
+*/
+func readCacheFile(cacheFilePath string) (entries map[string]*actualBaseURLCacheEntry, err error) {
+	entries = make(map[string]*actualBaseURLCacheEntry)
+
+	if !caravel.FileExists(cacheFilePath) {
+		return entries, nil
+	}
+
+	cacheBytes, err := ioutil.ReadFile(cacheFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	persistedEntries := make(map[string]*actualBaseURLCacheEntry)
+	err = json.Unmarshal(cacheBytes, &persistedEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, entry := range persistedEntries {
+		declaredBaseURL, err := url.Parse(key)
+		if err != nil || getCacheKey(declaredBaseURL) != key {
+			log.Warning("Skipping an invalid cache key")
+			continue
+		}
+
+		entry.actualBaseURL, err = url.Parse(entry.ActualBaseURL)
+		if err != nil {
+			log.Warning("Skipping an invalid cached actual base URL")
+			continue
+		}
+
+		entries[key] = entry
+	}
+
+	return entries, nil
+}
+
+/*
+getCacheKey returns the redacted declared base URL, so that no password
+is saved to the cache file
+*/
+func getCacheKey(declaredBaseURL *url.URL) string {
+	return declaredBaseURL.Redacted()
+}
+
+/*
+writeCacheFile atomically replaces the given cache file
+*/
+func writeCacheFile(cacheFilePath string, entries map[string]*actualBaseURLCacheEntry) (err error) {
+	cacheBytes, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temporaryFile, err := ioutil.TempFile(filepath.Dir(cacheFilePath), filepath.Base(cacheFilePath)+".")
+	if err != nil {
+		return err
+	}
+	temporaryPath := temporaryFile.Name()
+
+	_, err = temporaryFile.Write(cacheBytes)
+	closeErr := temporaryFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temporaryPath, cacheFilePath)
+	}
+
+	if err != nil {
+		os.Remove(temporaryPath)
+		return err
+	}
+
+	log.Debug("Actual base URL cache saved")
+	return nil
+}
@@ -21,6 +21,7 @@
 package descriptors
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -34,53 +35,132 @@ type BasicDescriptor struct {
 	DescriptorVersion string
 }
 
-func NewAppDescriptorFromPath(descriptorPath string) (descriptor AppDescriptor, err error) {
+func NewAppDescriptorFromPath(ctx context.Context, descriptorPath string) (descriptor AppDescriptor, err error) {
 	descriptorBytes, err := ioutil.ReadFile(descriptorPath)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewAppDescriptorFromBytes(descriptorBytes)
+	descriptorBytes, err = ConvertToJSON(descriptorBytes, GetEncodingFromFileName(descriptorPath))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAppDescriptorFromBytes(ctx, descriptorBytes)
+}
+
+func NewAppDescriptorFromBytes(ctx context.Context, descriptorBytes []byte) (descriptor AppDescriptor, err error) {
+	return NewAppDescriptorForPlatform(ctx, descriptorBytes, GetCurrentPlatform())
+}
+
+/*
+NewAppDescriptorForPlatform creates a descriptor whose platform-specific settings
+- packages, command line, icon path... - are the ones for the given platform;
+the context bounds the resolution of its actual base URL
+*/
+func NewAppDescriptorForPlatform(ctx context.Context, descriptorBytes []byte, platform Platform) (descriptor AppDescriptor, err error) {
+	return newAppDescriptor(ctx, descriptorBytes, platform, true)
+}
+
+/*
+ParseAppDescriptorForPlatform is like NewAppDescriptorForPlatform, but never
+accesses the network: the actual base URL of the descriptor is just its declared base URL.
+
+It is meant for authoring tools, that can check a descriptor before publishing it
+*/
+func ParseAppDescriptorForPlatform(descriptorBytes []byte, platform Platform) (descriptor AppDescriptor, err error) {
+	return newAppDescriptor(context.Background(), descriptorBytes, platform, false)
+}
+
+/*
+parsableDescriptor is implemented by the descriptors of every version:
+parse() fills the descriptor from its declared fields - without accessing the network -
+and returns all the problems found, including the ones reported by validation;
+resolveActualBaseURL() then replaces the declared base URL with the actual one
+*/
+type parsableDescriptor interface {
+	AppDescriptor
+
+	parse() (problems []string)
+	resolveActualBaseURL(ctx context.Context)
 }
 
-func NewAppDescriptorFromBytes(descriptorBytes []byte) (descriptor AppDescriptor, err error) {
+func newAppDescriptor(ctx context.Context, descriptorBytes []byte, platform Platform, resolvingActualBaseURL bool) (descriptor AppDescriptor, err error) {
+	descriptorBytes, err = ConvertToJSON(descriptorBytes, UnknownEncoding)
+	if err != nil {
+		return nil, err
+	}
+
 	basicDescriptor, err := createBasicDescriptor(descriptorBytes)
 	if err != nil {
 		return nil, err
 	}
 
-	descriptorVersion, err := versioning.ParseVersion(basicDescriptor.DescriptorVersion)
+	descriptorVersion, err := parseDescriptorVersion(basicDescriptor.DescriptorVersion)
 	if err != nil {
-		return nil, fmt.Errorf("Invalid Descriptor Version: %v", err.Error())
+		return nil, err
 	}
 
+	schemaWarnings, err := CheckSchema(descriptorBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, schemaWarning := range schemaWarnings {
+		log.Warning("Descriptor - %v", schemaWarning)
+	}
+
+	var parsedDescriptor AppDescriptor
+
 	switch descriptorVersion.Major {
 	case 3:
 		log.Notice("V3 descriptor found! Deserializing it")
-		descriptor, err = createV3Descriptor(descriptorBytes)
+		parsedDescriptor, err = createV3Descriptor(descriptorBytes, platform)
 
-	case 2:
-	case 1:
+	case 1, 2:
 		log.Notice("V1/V2 descriptor found! Deserializing it")
-		descriptor, err = createV1V2Descriptor(descriptorBytes)
+		parsedDescriptor, err = createV1V2Descriptor(descriptorBytes, platform)
 
 	default:
-		return nil, fmt.Errorf("Unsupported descriptor version (%v). Please, consider updating MoonDeploy - your current version is %v.",
-			descriptorVersion,
-			moondeploy.Version)
+		return nil, newUnsupportedDescriptorVersionError(descriptorVersion.Major)
 	}
-
-	err = descriptor.Init()
 	if err != nil {
 		return nil, err
 	}
 
-	err = validate(descriptor)
+	parsable := parsedDescriptor.(parsableDescriptor)
+
+	problems := parsable.parse()
+	if len(problems) > 0 {
+		return nil, &InvalidDescriptor{
+			Problems: problems,
+		}
+	}
+
+	if resolvingActualBaseURL {
+		parsable.resolveActualBaseURL(ctx)
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	return parsable, nil
+}
+
+func parseDescriptorVersion(descriptorVersionString string) (descriptorVersion *versioning.Version, err error) {
+	descriptorVersion, err = versioning.ParseVersion(descriptorVersionString)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("Invalid Descriptor Version: %v", err.Error())
 	}
 
-	return descriptor, nil
+	return descriptorVersion, nil
+}
+
+func newUnsupportedDescriptorVersionError(descriptorMajorVersion int) error {
+	return fmt.Errorf("Unsupported descriptor version (%v). Please, consider updating MoonDeploy - your current version is %v.",
+		descriptorMajorVersion,
+		moondeploy.Version)
 }
 
 func createBasicDescriptor(descriptorBytes []byte) (descriptor *BasicDescriptor, err error) {
@@ -88,14 +168,21 @@ func createBasicDescriptor(descriptorBytes []byte) (descriptor *BasicDescriptor,
 
 	err = json.Unmarshal(descriptorBytes, descriptor)
 	if err != nil {
+		typeErr, isTypeErr := err.(*json.UnmarshalTypeError)
+		if isTypeErr && typeErr.Field == "DescriptorVersion" {
+			return nil, fmt.Errorf("DescriptorVersion must be a string - please, enclose it in quotes")
+		}
+
 		return nil, err
 	}
 
 	return descriptor, nil
 }
 
-func createV3Descriptor(descriptorBytes []byte) (descriptor AppDescriptor, err error) {
-	descriptor = &appDescriptorV3{}
+func createV3Descriptor(descriptorBytes []byte, platform Platform) (descriptor AppDescriptor, err error) {
+	descriptor = &appDescriptorV3{
+		platform: platform,
+	}
 
 	err = json.Unmarshal(descriptorBytes, descriptor)
 	if err != nil {
@@ -105,8 +192,10 @@ func createV3Descriptor(descriptorBytes []byte) (descriptor AppDescriptor, err e
 	return descriptor, nil
 }
 
-func createV1V2Descriptor(descriptorBytes []byte) (descriptor AppDescriptor, err error) {
-	descriptor = &appDescriptorV1V2{}
+func createV1V2Descriptor(descriptorBytes []byte, platform Platform) (descriptor AppDescriptor, err error) {
+	descriptor = &appDescriptorV1V2{
+		platform: platform,
+	}
 
 	err = json.Unmarshal(descriptorBytes, descriptor)
 	if err != nil {
@@ -21,18 +21,30 @@
 package descriptors
 
 import (
+	"context"
 	"net/url"
 
+	"github.com/giancosta86/moondeploy/v3/gitHubUtils"
 	"github.com/giancosta86/moondeploy/v3/versioning"
 )
 
-const defaultDescriptorFileName = "App.moondeploy"
+const DefaultDescriptorFileName = "App.moondeploy"
+
+/*
+RawPackage describes a package that must be copied as it is - instead of
+being extracted - to the given path, relative to the app's files directory
+*/
+type RawPackage struct {
+	TargetPath string
+	Executable bool
+}
 
 type AppDescriptor interface {
 	GetDescriptorVersion() *versioning.Version
 
 	GetDeclaredBaseURL() *url.URL
 	GetActualBaseURL() *url.URL
+	GetActualBaseURLError() error
 	GetDescriptorFileName() string
 
 	GetName() string
@@ -41,18 +53,35 @@ type AppDescriptor interface {
 	GetDescription() string
 
 	GetPackageVersions() map[string]*versioning.Version
+	GetRawPackage(packageName string) *RawPackage
 	GetCommandLine() []string
 	GetSkipPackageLevels() int
+	GetMaxPackageEntries() int
+	GetMaxPackageSizeInMB() int64
 	IsSkipUpdateCheck() bool
+	IsSingleInstance() bool
 
 	GetIconPath() string
 
+	GetGitHubReleaseSelection() *gitHubUtils.ReleaseSelection
+
 	GetTitle() string
 
-	Init() (err error)
-	CheckRequirements() (err error)
+	GetRequirements() *Requirements
+
+	GetRestartPolicy() *RestartPolicy
+	GetLaunchMode() string
+
+	GetPlatform() Platform
+	GetDeclaredPlatforms() []Platform
+
+	Init(ctx context.Context) (err error)
+	CheckRequirements(installDirectory string) (err error)
+
+	GetMirrorBaseURLs() []*url.URL
 
 	GetRemoteFileURL(relativePath string) (remoteFileURL *url.URL, err error)
+	GetRemoteFileURLs(relativePath string) (remoteFileURLs []*url.URL, err error)
 
 	GetBytes() (bytes []byte, err error)
 }
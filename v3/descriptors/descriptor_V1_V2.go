@@ -21,11 +21,13 @@
 package descriptors
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
-	"runtime"
+	"strings"
 
+	"github.com/giancosta86/moondeploy/v3/gitHubUtils"
 	"github.com/giancosta86/moondeploy/v3/versioning"
 )
 
@@ -40,14 +42,14 @@ type appDescriptorV1V2 struct {
 	Publisher string
 
 	Description string
-	IconPath    map[string]string
+	IconPath    map[string]string `json:",omitempty"`
 
-	SkipUpdateCheck   bool
-	SkipPackageLevels int
+	SkipUpdateCheck   bool `json:",omitempty"`
+	SkipPackageLevels int  `json:",omitempty"`
 
 	CommandLine map[string][]string
 
-	PackageVersions map[string]string
+	PackageVersions map[string]string `json:",omitempty"`
 
 	//
 	//Computed fields
@@ -56,10 +58,13 @@ type appDescriptorV1V2 struct {
 	appVersion        *versioning.Version
 	declaredBaseURL   *url.URL
 	actualBaseURL     *url.URL
+	actualBaseURLErr  error
 	iconPath          string
 	commandLine       []string
 
 	packageVersions map[string]*versioning.Version
+
+	platform Platform
 }
 
 func (descriptor *appDescriptorV1V2) GetDescriptorVersion() *versioning.Version {
@@ -70,12 +75,16 @@ func (descriptor *appDescriptorV1V2) GetActualBaseURL() *url.URL {
 	return descriptor.actualBaseURL
 }
 
+func (descriptor *appDescriptorV1V2) GetActualBaseURLError() error {
+	return descriptor.actualBaseURLErr
+}
+
 func (descriptor *appDescriptorV1V2) GetDeclaredBaseURL() *url.URL {
 	return descriptor.declaredBaseURL
 }
 
 func (descriptor *appDescriptorV1V2) GetDescriptorFileName() string {
-	return defaultDescriptorFileName
+	return DefaultDescriptorFileName
 }
 
 func (descriptor *appDescriptorV1V2) GetName() string {
@@ -98,6 +107,10 @@ func (descriptor *appDescriptorV1V2) GetPackageVersions() map[string]*versioning
 	return descriptor.packageVersions
 }
 
+func (descriptor *appDescriptorV1V2) GetRawPackage(packageName string) *RawPackage {
+	return nil
+}
+
 func (descriptor *appDescriptorV1V2) GetCommandLine() []string {
 	return descriptor.commandLine
 }
@@ -106,32 +119,88 @@ func (descriptor *appDescriptorV1V2) GetSkipPackageLevels() int {
 	return descriptor.SkipPackageLevels
 }
 
+func (descriptor *appDescriptorV1V2) GetMaxPackageEntries() int {
+	return 0
+}
+
+func (descriptor *appDescriptorV1V2) GetMaxPackageSizeInMB() int64 {
+	return 0
+}
+
 func (descriptor *appDescriptorV1V2) IsSkipUpdateCheck() bool {
 	return descriptor.SkipUpdateCheck
 }
 
+func (descriptor *appDescriptorV1V2) IsSingleInstance() bool {
+	return false
+}
+
 func (descriptor *appDescriptorV1V2) GetIconPath() string {
 	return descriptor.iconPath
 }
 
+func (descriptor *appDescriptorV1V2) GetGitHubReleaseSelection() *gitHubUtils.ReleaseSelection {
+	return nil
+}
+
 func (descriptor *appDescriptorV1V2) GetTitle() string {
 	return fmt.Sprintf("%v %v", descriptor.Name, descriptor.Version)
 }
 
-func (descriptor *appDescriptorV1V2) Init() (err error) {
+func (descriptor *appDescriptorV1V2) GetPlatform() Platform {
+	return descriptor.platform
+}
+
+/*
+GetDeclaredPlatforms returns the platforms having a specific command line
+*/
+func (descriptor *appDescriptorV1V2) GetDeclaredPlatforms() []Platform {
+	platformKeys := []string{}
+	for platformKey := range descriptor.CommandLine {
+		if platformKey != anyOS {
+			platformKeys = append(platformKeys, platformKey)
+		}
+	}
+
+	return parsePlatformKeys(platformKeys)
+}
+
+func (descriptor *appDescriptorV1V2) Init(ctx context.Context) (err error) {
+	problems := descriptor.parse()
+	if len(problems) > 0 {
+		return &InvalidDescriptor{
+			Problems: problems,
+		}
+	}
+
+	descriptor.resolveActualBaseURL(ctx)
+
+	return nil
+}
+
+func (descriptor *appDescriptorV1V2) resolveActualBaseURL(ctx context.Context) {
+	descriptor.actualBaseURL, descriptor.actualBaseURLErr = getActualBaseURL(ctx, descriptor)
+}
+
+func (descriptor *appDescriptorV1V2) parse() (problems []string) {
+	var err error
+	problems = []string{}
+
 	descriptor.descriptorVersion, err = versioning.ParseVersion(descriptor.DescriptorVersion)
 	if err != nil {
-		return err
+		problems = append(problems, fmt.Sprintf("Error while parsing the Descriptor Version: %v", err.Error()))
 	}
 
 	descriptor.appVersion, err = versioning.ParseVersion(descriptor.Version)
 	if err != nil {
-		return err
+		problems = append(problems, fmt.Sprintf("Error while parsing the app version: %v", err.Error()))
 	}
 
-	descriptor.declaredBaseURL, err = url.Parse(ensureTrailingSlash(descriptor.BaseURL))
-	if err != nil {
-		return err
+	if strings.TrimSpace(descriptor.BaseURL) != "" {
+		descriptor.declaredBaseURL, err = url.Parse(ensureTrailingSlash(descriptor.BaseURL))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Error while parsing the Base URL: %v", err.Error()))
+		}
 	}
 
 	descriptor.setIconPath()
@@ -140,12 +209,12 @@ func (descriptor *appDescriptorV1V2) Init() (err error) {
 
 	descriptor.packageVersions, err = parsePackageVersions(descriptor.PackageVersions)
 	if err != nil {
-		return err
+		problems = append(problems, fmt.Sprintf("Error while parsing the package versions: %v", err.Error()))
 	}
 
-	descriptor.actualBaseURL = getActualBaseURL(descriptor)
+	descriptor.actualBaseURL = descriptor.declaredBaseURL
 
-	return nil
+	return append(problems, getValidationProblems(descriptor)...)
 }
 
 func (descriptor *appDescriptorV1V2) setIconPath() {
@@ -153,7 +222,15 @@ func (descriptor *appDescriptorV1V2) setIconPath() {
 		return
 	}
 
-	osSpecificIconPath := descriptor.IconPath[runtime.GOOS]
+	currentPlatform := descriptor.platform
+
+	platformSpecificIconPath := descriptor.IconPath[currentPlatform.String()]
+	if platformSpecificIconPath != "" {
+		descriptor.iconPath = platformSpecificIconPath
+		return
+	}
+
+	osSpecificIconPath := descriptor.IconPath[currentPlatform.OS]
 	if osSpecificIconPath != "" {
 		descriptor.iconPath = osSpecificIconPath
 		return
@@ -170,7 +247,15 @@ func (descriptor *appDescriptorV1V2) setCommandLine() {
 		return
 	}
 
-	osSpecificCommandLine := descriptor.CommandLine[runtime.GOOS]
+	currentPlatform := descriptor.platform
+
+	platformSpecificCommandLine := descriptor.CommandLine[currentPlatform.String()]
+	if platformSpecificCommandLine != nil {
+		descriptor.commandLine = platformSpecificCommandLine
+		return
+	}
+
+	osSpecificCommandLine := descriptor.CommandLine[currentPlatform.OS]
 	if osSpecificCommandLine != nil {
 		descriptor.commandLine = osSpecificCommandLine
 		return
@@ -182,7 +267,19 @@ func (descriptor *appDescriptorV1V2) setCommandLine() {
 	}
 }
 
-func (descriptor *appDescriptorV1V2) CheckRequirements() (err error) {
+func (descriptor *appDescriptorV1V2) GetRequirements() *Requirements {
+	return &Requirements{}
+}
+
+func (descriptor *appDescriptorV1V2) GetRestartPolicy() *RestartPolicy {
+	return &RestartPolicy{}
+}
+
+func (descriptor *appDescriptorV1V2) GetLaunchMode() string {
+	return ""
+}
+
+func (descriptor *appDescriptorV1V2) CheckRequirements(installDirectory string) (err error) {
 	return nil
 }
 
@@ -190,6 +287,14 @@ func (descriptor *appDescriptorV1V2) GetRemoteFileURL(relativePath string) (remo
 	return getRemoteFileURL(descriptor, relativePath)
 }
 
+func (descriptor *appDescriptorV1V2) GetMirrorBaseURLs() []*url.URL {
+	return []*url.URL{}
+}
+
+func (descriptor *appDescriptorV1V2) GetRemoteFileURLs(relativePath string) (remoteFileURLs []*url.URL, err error) {
+	return getRemoteFileURLs(descriptor, relativePath)
+}
+
 func (descriptor *appDescriptorV1V2) GetBytes() (bytes []byte, err error) {
 	return json.Marshal(*descriptor)
 }
@@ -21,11 +21,14 @@
 package descriptors
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
-	"runtime"
+	"path"
+	"strings"
 
+	"github.com/giancosta86/moondeploy/v3/gitHubUtils"
 	"github.com/giancosta86/moondeploy/v3/versioning"
 )
 
@@ -33,21 +36,29 @@ type appDescriptorV3 struct {
 	DescriptorVersion string
 
 	BaseURL            string
-	DescriptorFileName string
+	DescriptorFileName string `json:",omitempty"`
+
+	Mirrors []string `json:",omitempty"`
+
+	GitHubRelease *gitHubUtils.ReleaseSelection `json:",omitempty"`
 
 	Name        string
 	Version     string
 	Publisher   string
 	Description string
 
-	SkipPackageLevels int
-	SkipUpdateCheck   bool
+	SkipPackageLevels int  `json:",omitempty"`
+	SkipUpdateCheck   bool `json:",omitempty"`
+
+	SupportedOS []string `json:",omitempty"`
+
+	Requirements *Requirements `json:",omitempty"`
 
-	SupportedOS []string
+	RestartPolicy *RestartPolicy `json:",omitempty"`
 
 	osSettingsStruct
 
-	OS map[string]osSettingsStruct
+	OS map[string]osSettingsStruct `json:",omitempty"`
 
 	//
 	//Computed fields
@@ -56,6 +67,8 @@ type appDescriptorV3 struct {
 
 	declaredBaseURL    *url.URL
 	actualBaseURL      *url.URL
+	actualBaseURLErr   error
+	mirrorBaseURLs     []*url.URL
 	descriptorFileName string
 
 	name        string
@@ -65,18 +78,41 @@ type appDescriptorV3 struct {
 
 	skipPackageLevels int
 	skipUpdateCheck   bool
+	singleInstance    bool
+
+	maxPackageEntries  int
+	maxPackageSizeInMB int64
 
 	supportedSystems []string
 
+	requirements  *Requirements
+	restartPolicy *RestartPolicy
+	launchMode    string
+
 	packageVersions map[string]*versioning.Version
+	rawPackages     map[string]*RawPackage
 	commandLine     []string
 	iconPath        string
+
+	platform Platform
 }
 
 type osSettingsStruct struct {
-	Packages    map[string]string
-	CommandLine []string
-	IconPath    string
+	Packages    map[string]string           `json:",omitempty"`
+	RawPackages map[string]rawPackageStruct `json:",omitempty"`
+	CommandLine []string                    `json:",omitempty"`
+	IconPath    string                      `json:",omitempty"`
+
+	SingleInstance     *bool  `json:",omitempty"`
+	MaxPackageEntries  *int   `json:",omitempty"`
+	MaxPackageSizeInMB *int64 `json:",omitempty"`
+	LaunchMode         string `json:",omitempty"`
+}
+
+type rawPackageStruct struct {
+	Version    string
+	TargetPath string `json:",omitempty"`
+	Executable bool   `json:",omitempty"`
 }
 
 func (descriptor *appDescriptorV3) GetDescriptorVersion() *versioning.Version {
@@ -91,6 +127,14 @@ func (descriptor *appDescriptorV3) GetActualBaseURL() *url.URL {
 	return descriptor.actualBaseURL
 }
 
+func (descriptor *appDescriptorV3) GetActualBaseURLError() error {
+	return descriptor.actualBaseURLErr
+}
+
+func (descriptor *appDescriptorV3) GetMirrorBaseURLs() []*url.URL {
+	return descriptor.mirrorBaseURLs
+}
+
 func (descriptor *appDescriptorV3) GetDescriptorFileName() string {
 	return descriptor.descriptorFileName
 }
@@ -115,6 +159,10 @@ func (descriptor *appDescriptorV3) GetPackageVersions() map[string]*versioning.V
 	return descriptor.packageVersions
 }
 
+func (descriptor *appDescriptorV3) GetRawPackage(packageName string) *RawPackage {
+	return descriptor.rawPackages[packageName]
+}
+
 func (descriptor *appDescriptorV3) GetCommandLine() []string {
 	return descriptor.commandLine
 }
@@ -123,40 +171,83 @@ func (descriptor *appDescriptorV3) GetIconPath() string {
 	return descriptor.iconPath
 }
 
+func (descriptor *appDescriptorV3) GetGitHubReleaseSelection() *gitHubUtils.ReleaseSelection {
+	return descriptor.GitHubRelease
+}
+
 func (descriptor *appDescriptorV3) GetSkipPackageLevels() int {
 	return descriptor.skipPackageLevels
 }
 
+func (descriptor *appDescriptorV3) GetMaxPackageEntries() int {
+	return descriptor.maxPackageEntries
+}
+
+func (descriptor *appDescriptorV3) GetMaxPackageSizeInMB() int64 {
+	return descriptor.maxPackageSizeInMB
+}
+
 func (descriptor *appDescriptorV3) IsSkipUpdateCheck() bool {
 	return descriptor.skipUpdateCheck
 }
 
+func (descriptor *appDescriptorV3) IsSingleInstance() bool {
+	return descriptor.singleInstance
+}
+
 func (descriptor *appDescriptorV3) GetTitle() string {
 	return fmt.Sprintf("%v %v", descriptor.GetName(), descriptor.GetAppVersion())
 }
 
-func (descriptor *appDescriptorV3) Init() (err error) {
+func (descriptor *appDescriptorV3) Init(ctx context.Context) (err error) {
+	problems := descriptor.parse()
+	if len(problems) > 0 {
+		return &InvalidDescriptor{
+			Problems: problems,
+		}
+	}
+
+	descriptor.resolveActualBaseURL(ctx)
+
+	return nil
+}
+
+func (descriptor *appDescriptorV3) resolveActualBaseURL(ctx context.Context) {
+	descriptor.actualBaseURL, descriptor.actualBaseURLErr = getActualBaseURL(ctx, descriptor)
+}
+
+func (descriptor *appDescriptorV3) parse() (problems []string) {
+	var err error
+	problems = []string{}
+
 	descriptor.descriptorVersion, err = versioning.ParseVersion(descriptor.DescriptorVersion)
 	if err != nil {
-		return fmt.Errorf("Error while parsing the Descriptor Version: %v", err.Error())
+		problems = append(problems, fmt.Sprintf("Error while parsing the Descriptor Version: %v", err.Error()))
+	}
+
+	if strings.TrimSpace(descriptor.BaseURL) != "" {
+		descriptor.declaredBaseURL, err = url.Parse(ensureTrailingSlash(descriptor.BaseURL))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Error while parsing the Base URL: %v", err.Error()))
+		}
 	}
 
-	descriptor.declaredBaseURL, err = url.Parse(ensureTrailingSlash(descriptor.BaseURL))
+	descriptor.mirrorBaseURLs, err = parseMirrorBaseURLs(descriptor.Mirrors)
 	if err != nil {
-		return fmt.Errorf("Error while parsing the Base URL: %v", err.Error())
+		problems = append(problems, fmt.Sprintf("Error while parsing the mirrors: %v", err.Error()))
 	}
 
 	if descriptor.DescriptorFileName != "" {
 		descriptor.descriptorFileName = descriptor.DescriptorFileName
 	} else {
-		descriptor.descriptorFileName = defaultDescriptorFileName
+		descriptor.descriptorFileName = DefaultDescriptorFileName
 	}
 
 	descriptor.name = descriptor.Name
 
 	descriptor.appVersion, err = versioning.ParseVersion(descriptor.Version)
 	if err != nil {
-		return fmt.Errorf("Error while parsing the app version: %v", err.Error())
+		problems = append(problems, fmt.Sprintf("Error while parsing the app version: %v", err.Error()))
 	}
 
 	descriptor.publisher = descriptor.Publisher
@@ -171,50 +262,210 @@ func (descriptor *appDescriptorV3) Init() (err error) {
 		descriptor.supportedSystems = []string{}
 	}
 
-	osSettings, osSettingsFound := descriptor.OS[runtime.GOOS]
+	for platformKey := range descriptor.OS {
+		if !isValidPlatformKey(platformKey) {
+			problems = append(problems, fmt.Sprintf("Invalid OS key: '%v'. Expected <os> or <os>/<arch>", platformKey))
+		}
+	}
 
-	if osSettingsFound && osSettings.Packages != nil {
-		descriptor.packageVersions, err = parsePackageVersions(osSettings.Packages)
+	platformSettings := descriptor.getPlatformSettings(descriptor.platform)
+
+	descriptor.packageVersions, err = parsePackageVersions(platformSettings.Packages)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("Error while parsing the package versions: %v", err.Error()))
 	} else {
-		descriptor.packageVersions, err = parsePackageVersions(descriptor.Packages)
+		err = descriptor.setRawPackages(platformSettings.RawPackages)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Error while parsing the raw packages: %v", err.Error()))
+		}
+	}
+
+	descriptor.commandLine = platformSettings.CommandLine
+	descriptor.iconPath = platformSettings.IconPath
+
+	if platformSettings.SingleInstance != nil {
+		descriptor.singleInstance = *platformSettings.SingleInstance
+	}
+
+	if platformSettings.MaxPackageEntries != nil {
+		descriptor.maxPackageEntries = *platformSettings.MaxPackageEntries
+	}
+
+	if platformSettings.MaxPackageSizeInMB != nil {
+		descriptor.maxPackageSizeInMB = *platformSettings.MaxPackageSizeInMB
+	}
+
+	descriptor.launchMode = platformSettings.LaunchMode
+
+	descriptor.requirements = descriptor.Requirements
+	if descriptor.requirements == nil {
+		descriptor.requirements = &Requirements{}
+	}
+
+	descriptor.restartPolicy = descriptor.RestartPolicy
+	if descriptor.restartPolicy == nil {
+		descriptor.restartPolicy = &RestartPolicy{}
 	}
 
+	err = descriptor.requirements.init()
 	if err != nil {
-		return fmt.Errorf("Error while parsing the package versions: %v", err.Error())
+		problems = append(problems, fmt.Sprintf("Error while parsing the requirements: %v", err.Error()))
 	}
 
-	if osSettingsFound && osSettings.CommandLine != nil {
-		descriptor.commandLine = osSettings.CommandLine
-	} else {
-		descriptor.commandLine = descriptor.CommandLine
+	if descriptor.GitHubRelease != nil {
+		err = descriptor.GitHubRelease.Init()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Error while parsing the GitHub release selection: %v", err.Error()))
+		}
 	}
 
-	if osSettingsFound && osSettings.IconPath != "" {
-		descriptor.iconPath = osSettings.IconPath
-	} else {
-		descriptor.iconPath = descriptor.IconPath
+	descriptor.actualBaseURL = descriptor.declaredBaseURL
+
+	return append(problems, getValidationProblems(descriptor)...)
+}
+
+/*
+getPlatformSettings applies to the default settings the ones declared
+for "<os>" and then for "<os>/<arch>", each one overriding the previous
+*/
+func (descriptor *appDescriptorV3) getPlatformSettings(platform Platform) (result osSettingsStruct) {
+	result = descriptor.osSettingsStruct
+
+	for _, platformKey := range platform.getSettingsKeys() {
+		overridingSettings, overridingSettingsFound := descriptor.OS[platformKey]
+		if !overridingSettingsFound {
+			continue
+		}
+
+		if overridingSettings.Packages != nil {
+			result.Packages = overridingSettings.Packages
+		}
+
+		if overridingSettings.RawPackages != nil {
+			result.RawPackages = overridingSettings.RawPackages
+		}
+
+		if overridingSettings.CommandLine != nil {
+			result.CommandLine = overridingSettings.CommandLine
+		}
+
+		if overridingSettings.IconPath != "" {
+			result.IconPath = overridingSettings.IconPath
+		}
+
+		if overridingSettings.SingleInstance != nil {
+			result.SingleInstance = overridingSettings.SingleInstance
+		}
+
+		if overridingSettings.MaxPackageEntries != nil {
+			result.MaxPackageEntries = overridingSettings.MaxPackageEntries
+		}
+
+		if overridingSettings.MaxPackageSizeInMB != nil {
+			result.MaxPackageSizeInMB = overridingSettings.MaxPackageSizeInMB
+		}
+
+		if overridingSettings.LaunchMode != "" {
+			result.LaunchMode = overridingSettings.LaunchMode
+		}
 	}
 
-	descriptor.actualBaseURL = getActualBaseURL(descriptor)
+	return result
+}
+
+func (descriptor *appDescriptorV3) setRawPackages(rawPackageStructs map[string]rawPackageStruct) (err error) {
+	descriptor.rawPackages = make(map[string]*RawPackage)
+
+	for packageName, rawPackage := range rawPackageStructs {
+		if _, alreadyDeclared := descriptor.packageVersions[packageName]; alreadyDeclared {
+			return fmt.Errorf("Package '%v' is declared both as an archive and as a raw package", packageName)
+		}
+
+		if rawPackage.Version != "" {
+			descriptor.packageVersions[packageName], err = versioning.ParseVersion(rawPackage.Version)
+			if err != nil {
+				return fmt.Errorf("Invalid version string for package '%v': '%v'",
+					packageName,
+					rawPackage.Version)
+			}
+		} else {
+			descriptor.packageVersions[packageName] = nil
+		}
+
+		targetPath := rawPackage.TargetPath
+		if targetPath == "" {
+			targetPath = path.Base(packageName)
+		}
+
+		descriptor.rawPackages[packageName] = &RawPackage{
+			TargetPath: targetPath,
+			Executable: rawPackage.Executable,
+		}
+	}
 
 	return nil
 }
 
-func (descriptor *appDescriptorV3) CheckRequirements() (err error) {
+func (descriptor *appDescriptorV3) GetPlatform() Platform {
+	return descriptor.platform
+}
+
+/*
+GetDeclaredPlatforms returns the platforms listed in SupportedOS or,
+if it is empty, the ones having specific settings in the OS section
+*/
+func (descriptor *appDescriptorV3) GetDeclaredPlatforms() []Platform {
+	if len(descriptor.supportedSystems) > 0 {
+		return parsePlatformKeys(descriptor.supportedSystems)
+	}
+
+	platformKeys := []string{}
+	for platformKey := range descriptor.OS {
+		platformKeys = append(platformKeys, platformKey)
+	}
+
+	return parsePlatformKeys(platformKeys)
+}
+
+func (descriptor *appDescriptorV3) GetRequirements() *Requirements {
+	return descriptor.requirements
+}
+
+func (descriptor *appDescriptorV3) GetRestartPolicy() *RestartPolicy {
+	return descriptor.restartPolicy
+}
+
+func (descriptor *appDescriptorV3) GetLaunchMode() string {
+	return descriptor.launchMode
+}
+
+func (descriptor *appDescriptorV3) CheckRequirements(installDirectory string) (err error) {
+	problems := []string{}
+
 	if len(descriptor.supportedSystems) > 0 {
-		foundOS := false
+		currentPlatform := descriptor.platform
+		foundPlatform := false
 
 		for _, supportedSystem := range descriptor.supportedSystems {
-			if supportedSystem == runtime.GOOS {
-				foundOS = true
+			if currentPlatform.Matches(supportedSystem) {
+				foundPlatform = true
 				break
 			}
 		}
 
-		if !foundOS {
-			return fmt.Errorf("The current OS (%v) is not supported by %v.", runtime.GOOS, descriptor.GetTitle())
+		if !foundPlatform {
+			problems = append(problems, fmt.Sprintf("The current platform (%v) is not supported", currentPlatform))
+		}
+	}
+
+	problems = append(problems, descriptor.requirements.Check(installDirectory)...)
+	if len(problems) > 0 {
+		return &UnsatisfiedRequirements{
+			Title:    descriptor.GetTitle(),
+			Problems: problems,
 		}
 	}
+
 	return nil
 }
 
@@ -222,6 +473,10 @@ func (descriptor *appDescriptorV3) GetRemoteFileURL(relativePath string) (remote
 	return getRemoteFileURL(descriptor, relativePath)
 }
 
+func (descriptor *appDescriptorV3) GetRemoteFileURLs(relativePath string) (remoteFileURLs []*url.URL, err error) {
+	return getRemoteFileURLs(descriptor, relativePath)
+}
+
 func (descriptor *appDescriptorV3) GetBytes() (bytes []byte, err error) {
 	return json.Marshal(*descriptor)
 }
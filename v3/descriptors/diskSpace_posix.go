@@ -0,0 +1,36 @@
+//go:build linux || darwin
+
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package descriptors
+
+import "syscall"
+
+func getFreeDiskSpace(directory string) (freeBytes uint64, err error) {
+	var fileSystemStats syscall.Statfs_t
+
+	err = syscall.Statfs(directory, &fileSystemStats)
+	if err != nil {
+		return 0, err
+	}
+
+	return fileSystemStats.Bavail * uint64(fileSystemStats.Bsize), nil
+}
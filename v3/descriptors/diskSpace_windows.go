@@ -0,0 +1,49 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package descriptors
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceExProc = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+func getFreeDiskSpace(directory string) (freeBytes uint64, err error) {
+	directoryPointer, err := syscall.UTF16PtrFromString(directory)
+	if err != nil {
+		return 0, err
+	}
+
+	var totalBytes, totalFreeBytes uint64
+
+	result, _, callErr := getDiskFreeSpaceExProc.Call(
+		uintptr(unsafe.Pointer(directoryPointer)),
+		uintptr(unsafe.Pointer(&freeBytes)),
+		uintptr(unsafe.Pointer(&totalBytes)),
+		uintptr(unsafe.Pointer(&totalFreeBytes)))
+
+	if result == 0 {
+		return 0, callErr
+	}
+
+	return freeBytes, nil
+}
@@ -0,0 +1,235 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package descriptors
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+Encoding is the text format of a descriptor file: whatever the encoding,
+descriptors are converted to JSON before being deserialized
+*/
+type Encoding int
+
+const (
+	UnknownEncoding Encoding = iota
+	JSONEncoding
+	YAMLEncoding
+	TOMLEncoding
+)
+
+func (encoding Encoding) String() string {
+	switch encoding {
+	case JSONEncoding:
+		return "JSON"
+	case YAMLEncoding:
+		return "YAML"
+	case TOMLEncoding:
+		return "TOML"
+	default:
+		return "unknown"
+	}
+}
+
+var tomlLineRegex = regexp.MustCompile(`^(\[.*\]|[A-Za-z0-9_."'-]+\s*=)`)
+
+var jsonNumberRegex = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+/*
+GetEncodingFromFileName infers the encoding from the extension of the file name;
+it returns UnknownEncoding for .moondeploy files, whose encoding must be detected
+*/
+func GetEncodingFromFileName(fileName string) Encoding {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		return JSONEncoding
+	case ".yaml", ".yml":
+		return YAMLEncoding
+	case ".toml":
+		return TOMLEncoding
+	default:
+		return UnknownEncoding
+	}
+}
+
+/*
+DetectEncoding infers the encoding from the content of the descriptor:
+JSON objects start with '{', while TOML documents start with a table header
+or a "key = value" line; anything else is considered YAML
+*/
+func DetectEncoding(descriptorBytes []byte) Encoding {
+	for _, line := range strings.Split(string(descriptorBytes), "\n") {
+		trimmedLine := strings.TrimSpace(strings.TrimPrefix(line, "\ufeff"))
+
+		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(trimmedLine, "{") {
+			return JSONEncoding
+		}
+
+		if tomlLineRegex.MatchString(trimmedLine) {
+			return TOMLEncoding
+		}
+
+		return YAMLEncoding
+	}
+
+	return JSONEncoding
+}
+
+/*
+ConvertToJSON returns the JSON version of a descriptor having the given encoding;
+if the encoding is UnknownEncoding, it is detected from the content
+*/
+func ConvertToJSON(descriptorBytes []byte, encoding Encoding) (jsonBytes []byte, err error) {
+	if encoding == UnknownEncoding {
+		encoding = DetectEncoding(descriptorBytes)
+	}
+
+	switch encoding {
+	case JSONEncoding:
+		return descriptorBytes, nil
+
+	case YAMLEncoding:
+		return convertYAMLToJSON(descriptorBytes)
+
+	case TOMLEncoding:
+		return convertTOMLToJSON(descriptorBytes)
+
+	default:
+		return nil, fmt.Errorf("Unsupported descriptor encoding: %v", encoding)
+	}
+}
+
+func convertTOMLToJSON(descriptorBytes []byte) (jsonBytes []byte, err error) {
+	var descriptorMap map[string]interface{}
+
+	_, err = toml.Decode(string(descriptorBytes), &descriptorMap)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid TOML descriptor: %v", err)
+	}
+
+	return json.Marshal(descriptorMap)
+}
+
+func convertYAMLToJSON(descriptorBytes []byte) (jsonBytes []byte, err error) {
+	var documentNode yaml.Node
+
+	err = yaml.Unmarshal(descriptorBytes, &documentNode)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid YAML descriptor: %v", err)
+	}
+
+	descriptorValue, err := convertYAMLNode(&documentNode)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid YAML descriptor: %v", err)
+	}
+
+	return json.Marshal(descriptorValue)
+}
+
+/*
+convertYAMLNode converts a YAML node to a value that can be serialized to JSON;
+numbers keep their original text, so that - for example - 1.10 is not turned into 1.1
+*/
+func convertYAMLNode(node *yaml.Node) (value interface{}, err error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return convertYAMLNode(node.Content[0])
+
+	case yaml.AliasNode:
+		return convertYAMLNode(node.Alias)
+
+	case yaml.MappingNode:
+		mapValue := make(map[string]interface{})
+
+		for keyIndex := 0; keyIndex+1 < len(node.Content); keyIndex += 2 {
+			keyNode := node.Content[keyIndex]
+			if keyNode.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %v: keys must be scalars", keyNode.Line)
+			}
+
+			mapValue[keyNode.Value], err = convertYAMLNode(node.Content[keyIndex+1])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return mapValue, nil
+
+	case yaml.SequenceNode:
+		sliceValue := []interface{}{}
+
+		for _, itemNode := range node.Content {
+			itemValue, err := convertYAMLNode(itemNode)
+			if err != nil {
+				return nil, err
+			}
+
+			sliceValue = append(sliceValue, itemValue)
+		}
+
+		return sliceValue, nil
+
+	case yaml.ScalarNode:
+		return convertYAMLScalar(node)
+
+	default:
+		return nil, fmt.Errorf("line %v: unsupported YAML node", node.Line)
+	}
+}
+
+func convertYAMLScalar(node *yaml.Node) (value interface{}, err error) {
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+
+	case "!!bool":
+		var boolValue bool
+		err = node.Decode(&boolValue)
+		return boolValue, err
+
+	case "!!int", "!!float":
+		if jsonNumberRegex.MatchString(node.Value) {
+			return json.Number(node.Value), nil
+		}
+
+		var floatValue float64
+		err = node.Decode(&floatValue)
+		return floatValue, err
+
+	default:
+		return node.Value, nil
+	}
+}
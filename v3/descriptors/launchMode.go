@@ -0,0 +1,42 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package descriptors
+
+/*
+In the attached launch mode - the default one - MoonDeploy waits for the app
+to exit, logging its output; in the detached mode, the app is started in
+a session of its own, with its output written to a log file in the app
+directory, and MoonDeploy exits as soon as the app has started
+*/
+const (
+	AttachedLaunchMode = "attached"
+	DetachedLaunchMode = "detached"
+)
+
+func IsValidLaunchMode(launchMode string) bool {
+	switch launchMode {
+	case AttachedLaunchMode, DetachedLaunchMode:
+		return true
+
+	default:
+		return false
+	}
+}
@@ -0,0 +1,189 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package descriptors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+const migratedDescriptorVersion = "3.0"
+
+type migratedDescriptorV3 struct {
+	DescriptorVersion string
+
+	BaseURL string
+
+	Name        string
+	Version     string
+	Publisher   string
+	Description string
+
+	SkipPackageLevels int  `json:",omitempty"`
+	SkipUpdateCheck   bool `json:",omitempty"`
+
+	Packages    map[string]string `json:",omitempty"`
+	CommandLine []string          `json:",omitempty"`
+	IconPath    string            `json:",omitempty"`
+
+	OS map[string]*migratedOSSettings `json:",omitempty"`
+}
+
+type migratedOSSettings struct {
+	CommandLine []string `json:",omitempty"`
+	IconPath    string   `json:",omitempty"`
+}
+
+/*
+MigrateToV3 converts a V1/V2 descriptor into an equivalent V3 descriptor: the
+"*" command line and icon path become the default settings, while the other keys
+become OS-specific settings.
+
+The equivalence of the two descriptors is checked on each declared platform.
+*/
+func MigrateToV3(ctx context.Context, descriptorBytes []byte) (migratedBytes []byte, err error) {
+	descriptorBytes, err = ConvertToJSON(descriptorBytes, UnknownEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	basicDescriptor, err := createBasicDescriptor(descriptorBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptorVersion, err := parseDescriptorVersion(basicDescriptor.DescriptorVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if descriptorVersion.Major != 1 && descriptorVersion.Major != 2 {
+		return nil, fmt.Errorf("Only V1/V2 descriptors can be migrated - found version %v", descriptorVersion)
+	}
+
+	_, err = CheckSchema(descriptorBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceDescriptor := &appDescriptorV1V2{}
+	err = json.Unmarshal(descriptorBytes, sourceDescriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Migrating the descriptor to V3...")
+
+	migratedDescriptor := &migratedDescriptorV3{
+		DescriptorVersion: migratedDescriptorVersion,
+		BaseURL:           sourceDescriptor.BaseURL,
+		Name:              sourceDescriptor.Name,
+		Version:           sourceDescriptor.Version,
+		Publisher:         sourceDescriptor.Publisher,
+		Description:       sourceDescriptor.Description,
+		SkipPackageLevels: sourceDescriptor.SkipPackageLevels,
+		SkipUpdateCheck:   sourceDescriptor.SkipUpdateCheck,
+		Packages:          sourceDescriptor.PackageVersions,
+		OS:                make(map[string]*migratedOSSettings),
+	}
+
+	getOSSettings := func(platformKey string) *migratedOSSettings {
+		osSettings := migratedDescriptor.OS[platformKey]
+
+		if osSettings == nil {
+			osSettings = &migratedOSSettings{}
+			migratedDescriptor.OS[platformKey] = osSettings
+		}
+
+		return osSettings
+	}
+
+	for platformKey, commandLine := range sourceDescriptor.CommandLine {
+		if platformKey == anyOS {
+			migratedDescriptor.CommandLine = commandLine
+		} else {
+			getOSSettings(platformKey).CommandLine = commandLine
+		}
+	}
+
+	for platformKey, iconPath := range sourceDescriptor.IconPath {
+		if platformKey == anyOS {
+			migratedDescriptor.IconPath = iconPath
+		} else {
+			getOSSettings(platformKey).IconPath = iconPath
+		}
+	}
+
+	migratedBytes, err = json.MarshalIndent(migratedDescriptor, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkMigrationEquivalence(ctx, descriptorBytes, migratedBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Notice("Descriptor migrated")
+
+	return migratedBytes, nil
+}
+
+func checkMigrationEquivalence(ctx context.Context, sourceBytes []byte, migratedBytes []byte) (err error) {
+	sourceDescriptor, err := createV1V2Descriptor(sourceBytes, GetCurrentPlatform())
+	if err != nil {
+		return err
+	}
+
+	platforms := append(sourceDescriptor.GetDeclaredPlatforms(), GetCurrentPlatform())
+
+	for _, platform := range platforms {
+		sourcePlatformDescriptor, sourceErr := createV1V2Descriptor(sourceBytes, platform)
+		if sourceErr == nil {
+			sourceErr = sourcePlatformDescriptor.Init(ctx)
+		}
+
+		migratedPlatformDescriptor, migratedErr := NewAppDescriptorForPlatform(ctx, migratedBytes, platform)
+
+		if sourceErr != nil {
+			if migratedErr == nil {
+				return fmt.Errorf("The migrated descriptor is valid on %v, unlike the original one: %v", platform, sourceErr)
+			}
+			continue
+		}
+
+		if migratedErr != nil {
+			return fmt.Errorf("The migrated descriptor is invalid on %v: %v", platform, migratedErr)
+		}
+
+		if !reflect.DeepEqual(sourcePlatformDescriptor.GetCommandLine(), migratedPlatformDescriptor.GetCommandLine()) ||
+			sourcePlatformDescriptor.GetIconPath() != migratedPlatformDescriptor.GetIconPath() ||
+			!reflect.DeepEqual(sourcePlatformDescriptor.GetPackageVersions(), migratedPlatformDescriptor.GetPackageVersions()) {
+			return fmt.Errorf("The migrated descriptor is not equivalent to the original one on %v", platform)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package descriptors
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const platformSeparator = "/"
+
+/*
+Platform identifies an operating system and an architecture, using the values
+of runtime.GOOS and runtime.GOARCH
+*/
+type Platform struct {
+	OS   string
+	Arch string
+}
+
+func (platform Platform) String() string {
+	if platform.Arch == "" {
+		return platform.OS
+	}
+
+	return platform.OS + platformSeparator + platform.Arch
+}
+
+/*
+Matches returns true if the given key - either "<os>" or "<os>/<arch>" -
+refers to the platform
+*/
+func (platform Platform) Matches(platformKey string) bool {
+	return platformKey == platform.OS || platformKey == platform.String()
+}
+
+/*
+getSettingsKeys returns the keys that can declare platform-specific settings,
+from the least to the most specific
+*/
+func (platform Platform) getSettingsKeys() []string {
+	if platform.Arch == "" {
+		return []string{platform.OS}
+	}
+
+	return []string{platform.OS, platform.String()}
+}
+
+var currentPlatform = Platform{
+	OS:   runtime.GOOS,
+	Arch: runtime.GOARCH,
+}
+
+func GetCurrentPlatform() Platform {
+	return currentPlatform
+}
+
+func isValidPlatformKey(platformKey string) bool {
+	platformComponents := strings.Split(platformKey, platformSeparator)
+
+	for _, component := range platformComponents {
+		if strings.TrimSpace(component) == "" {
+			return false
+		}
+	}
+
+	return len(platformComponents) <= 2
+}
+
+/*
+ParsePlatformKey converts a key - either "<os>" or "<os>/<arch>" - to a Platform;
+the Arch field is empty when the key does not declare it
+*/
+func ParsePlatformKey(platformKey string) Platform {
+	platformComponents := strings.SplitN(platformKey, platformSeparator, 2)
+
+	platform := Platform{
+		OS: platformComponents[0],
+	}
+
+	if len(platformComponents) > 1 {
+		platform.Arch = platformComponents[1]
+	}
+
+	return platform
+}
+
+func parsePlatformKeys(platformKeys []string) []Platform {
+	sortedKeys := append([]string{}, platformKeys...)
+	sort.Strings(sortedKeys)
+
+	platforms := []Platform{}
+	for _, platformKey := range sortedKeys {
+		platforms = append(platforms, ParsePlatformKey(platformKey))
+	}
+
+	return platforms
+}
@@ -0,0 +1,287 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package descriptors
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/versioning"
+)
+
+const runtimeVersionTimeout = 15 * time.Second
+
+var defaultVersionArguments = []string{"-version"}
+
+/*
+runtimeVersionRegexes are tried in order on the output of a runtime's version command:
+first, the number following the word "version" - as in 'openjdk version "17.0.2"' -
+then, the first dotted number that is a word on its own - as in "Python 3.11.4" or "v18.12.0" -
+and finally a plain number making up the whole output.
+
+Therefore, other numbers - such as the ones in "Picked up _JAVA_OPTIONS" lines
+or leading build numbers - are not mistaken for the version
+*/
+var runtimeVersionRegexes = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bversion:?\s+"?[a-z]*(\d+(?:\.\d+){0,3})`),
+	regexp.MustCompile(`(?:^|\s)v?(\d+(?:\.\d+){1,3})(?:[^\d.]|$)`),
+	regexp.MustCompile(`^\s*v?(\d+)\s*$`),
+}
+
+/*
+Requirements declares what must be available on the user's system
+before the app can run
+*/
+type Requirements struct {
+	Runtimes             []RuntimeRequirement `json:",omitempty"`
+	MinFreeDiskSpaceInMB int64                `json:",omitempty"`
+	Executables          []string             `json:",omitempty"`
+}
+
+/*
+RuntimeRequirement declares a runtime - such as a JRE - whose Command must be
+on the PATH, with a version in the range [MinVersion; MaxVersion]; the version
+is parsed from the output of Command run with VersionArguments (by default,
+"-version").
+
+Descriptor can reference the MoonDeploy descriptor of a shared runtime app,
+installed whenever the runtime is missing: its BinDirectory, relative to
+the runtime app's files, is then prepended to the PATH.
+*/
+type RuntimeRequirement struct {
+	Name             string `json:",omitempty"`
+	Command          string
+	VersionArguments []string `json:",omitempty"`
+	MinVersion       string   `json:",omitempty"`
+	MaxVersion       string   `json:",omitempty"`
+
+	Descriptor   string `json:",omitempty"`
+	BinDirectory string `json:",omitempty"`
+
+	minVersion *versioning.Version
+	maxVersion *versioning.Version
+}
+
+/*
+UnsatisfiedRequirements is returned when one or more requirements of an app
+are not met; it lists all of them
+*/
+type UnsatisfiedRequirements struct {
+	Title    string
+	Problems []string
+}
+
+func (err *UnsatisfiedRequirements) Error() string {
+	return fmt.Sprintf("%v cannot run on this system:\n\n* %v",
+		err.Title,
+		strings.Join(err.Problems, "\n* "))
+}
+
+func (requirements *Requirements) init() (err error) {
+	if requirements.MinFreeDiskSpaceInMB < 0 {
+		return fmt.Errorf("MinFreeDiskSpaceInMB must be >= 0")
+	}
+
+	for runtimeIndex := range requirements.Runtimes {
+		runtimeRequirement := &requirements.Runtimes[runtimeIndex]
+
+		if strings.TrimSpace(runtimeRequirement.Command) == "" {
+			return fmt.Errorf("Runtime requirement #%v has no command", runtimeIndex+1)
+		}
+
+		if runtimeRequirement.MinVersion != "" {
+			runtimeRequirement.minVersion, err = versioning.ParseVersion(runtimeRequirement.MinVersion)
+			if err != nil {
+				return fmt.Errorf("Invalid min version for runtime '%v': %v", runtimeRequirement.GetName(), err)
+			}
+		}
+
+		if runtimeRequirement.MaxVersion != "" {
+			runtimeRequirement.maxVersion, err = versioning.ParseVersion(runtimeRequirement.MaxVersion)
+			if err != nil {
+				return fmt.Errorf("Invalid max version for runtime '%v': %v", runtimeRequirement.GetName(), err)
+			}
+		}
+
+		if runtimeRequirement.BinDirectory != "" && !isSafeRelativePath(runtimeRequirement.BinDirectory) {
+			return fmt.Errorf("The bin directory of runtime '%v' must be a relative path", runtimeRequirement.GetName())
+		}
+	}
+
+	return nil
+}
+
+/*
+Check returns the description of every requirement not met, checking the
+free disk space on the volume of installDirectory
+*/
+func (requirements *Requirements) Check(installDirectory string) (problems []string) {
+	problems = []string{}
+
+	for runtimeIndex := range requirements.Runtimes {
+		err := requirements.Runtimes[runtimeIndex].Check()
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	for _, executable := range requirements.Executables {
+		_, err := exec.LookPath(executable)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Required executable not found on the PATH: '%v'", executable))
+		}
+	}
+
+	if requirements.MinFreeDiskSpaceInMB > 0 {
+		problem := checkFreeDiskSpace(installDirectory, requirements.MinFreeDiskSpaceInMB)
+		if problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	return problems
+}
+
+/*
+Check returns nil if the runtime is available on the PATH with a suitable version
+*/
+func (runtimeRequirement *RuntimeRequirement) Check() (err error) {
+	commandPath, err := exec.LookPath(runtimeRequirement.Command)
+	if err != nil {
+		return fmt.Errorf("Required runtime not found: %v%v",
+			runtimeRequirement.GetName(),
+			runtimeRequirement.formatVersionRange())
+	}
+
+	if runtimeRequirement.minVersion == nil && runtimeRequirement.maxVersion == nil {
+		return nil
+	}
+
+	runtimeVersion, err := runtimeRequirement.getInstalledVersion(commandPath)
+	if err != nil {
+		return fmt.Errorf("Cannot detect the version of runtime %v: %v", runtimeRequirement.GetName(), err)
+	}
+
+	log.Debug("Runtime %v has version %v", runtimeRequirement.GetName(), runtimeVersion)
+
+	if (runtimeRequirement.minVersion != nil && runtimeRequirement.minVersion.NewerThan(runtimeVersion)) ||
+		(runtimeRequirement.maxVersion != nil && runtimeVersion.NewerThan(runtimeRequirement.maxVersion)) {
+		return fmt.Errorf("Runtime %v has version %v, but%v is required",
+			runtimeRequirement.GetName(),
+			runtimeVersion,
+			runtimeRequirement.formatVersionRange())
+	}
+
+	return nil
+}
+
+func (runtimeRequirement *RuntimeRequirement) getInstalledVersion(commandPath string) (runtimeVersion *versioning.Version, err error) {
+	versionArguments := runtimeRequirement.VersionArguments
+	if versionArguments == nil {
+		versionArguments = defaultVersionArguments
+	}
+
+	timeoutContext, cancel := context.WithTimeout(context.Background(), runtimeVersionTimeout)
+	defer cancel()
+
+	outputBytes, err := exec.CommandContext(timeoutContext, commandPath, versionArguments...).CombinedOutput()
+	if err != nil {
+		return nil, err
+	}
+
+	versionString := findRuntimeVersion(string(outputBytes))
+	if versionString == "" {
+		return nil, fmt.Errorf("no version found in the output of %v", runtimeRequirement.Command)
+	}
+
+	return versioning.ParseVersion(versionString)
+}
+
+func findRuntimeVersion(versionOutput string) (versionString string) {
+	for _, runtimeVersionRegex := range runtimeVersionRegexes {
+		match := runtimeVersionRegex.FindStringSubmatch(versionOutput)
+		if match != nil {
+			return match[1]
+		}
+	}
+
+	return ""
+}
+
+/*
+GetName returns the declared name of the runtime, or its command
+*/
+func (runtimeRequirement *RuntimeRequirement) GetName() string {
+	if runtimeRequirement.Name != "" {
+		return runtimeRequirement.Name
+	}
+
+	return runtimeRequirement.Command
+}
+
+func (runtimeRequirement *RuntimeRequirement) formatVersionRange() string {
+	switch {
+	case runtimeRequirement.minVersion != nil && runtimeRequirement.maxVersion != nil:
+		return fmt.Sprintf(" %v to %v", runtimeRequirement.minVersion, runtimeRequirement.maxVersion)
+	case runtimeRequirement.minVersion != nil:
+		return fmt.Sprintf(" %v+", runtimeRequirement.minVersion)
+	case runtimeRequirement.maxVersion != nil:
+		return fmt.Sprintf(" up to %v", runtimeRequirement.maxVersion)
+	default:
+		return ""
+	}
+}
+
+func checkFreeDiskSpace(installDirectory string, minFreeDiskSpaceInMB int64) (problem string) {
+	existingDirectory := installDirectory
+	for {
+		if _, err := os.Stat(existingDirectory); err == nil {
+			break
+		}
+
+		parentDirectory := filepath.Dir(existingDirectory)
+		if parentDirectory == existingDirectory {
+			break
+		}
+		existingDirectory = parentDirectory
+	}
+
+	freeBytes, err := getFreeDiskSpace(existingDirectory)
+	if err != nil {
+		log.Warning("Cannot compute the free disk space on %v: %v", runtime.GOOS, err)
+		return ""
+	}
+
+	freeMB := int64(freeBytes / (1024 * 1024))
+	if freeMB < minFreeDiskSpaceInMB {
+		return fmt.Sprintf("Not enough free disk space: %v MB required, %v MB available", minFreeDiskSpaceInMB, freeMB)
+	}
+
+	return ""
+}
@@ -0,0 +1,42 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package descriptors
+
+import "time"
+
+/*
+RestartPolicy declares whether an app that crashes - that is, exits with
+a non-zero exit code - must be restarted, for example in kiosk-style
+deployments; MaxRetries is the maximum number of consecutive restarts,
+and zero disables the policy
+*/
+type RestartPolicy struct {
+	MaxRetries          int `json:",omitempty"`
+	RetryDelayInSeconds int `json:",omitempty"`
+}
+
+func (policy *RestartPolicy) IsEnabled() bool {
+	return policy.MaxRetries > 0
+}
+
+func (policy *RestartPolicy) GetRetryDelay() time.Duration {
+	return time.Duration(policy.RetryDelayInSeconds) * time.Second
+}
@@ -0,0 +1,390 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package descriptors
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//go:embed schemas/*.schema.json
+var schemaFiles embed.FS
+
+const schemaDefinitionsPrefix = "#/definitions/"
+
+/*
+jsonSchema is the subset of JSON Schema used by the descriptor schemas
+*/
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Minimum              *float64               `json:"minimum"`
+	Enum                 []interface{}          `json:"enum"`
+	Definitions          map[string]*jsonSchema `json:"definitions"`
+}
+
+type schemaChecker struct {
+	rootSchema *jsonSchema
+	problems   []string
+	warnings   []string
+}
+
+/*
+GetSchema returns the JSON Schema describing the descriptors having the given major version
+*/
+func GetSchema(descriptorMajorVersion int) (schemaBytes []byte, err error) {
+	switch descriptorMajorVersion {
+	case 3:
+		return schemaFiles.ReadFile("schemas/descriptor-v3.schema.json")
+
+	case 1, 2:
+		return schemaFiles.ReadFile("schemas/descriptor-v1-v2.schema.json")
+
+	default:
+		return nil, newUnsupportedDescriptorVersionError(descriptorMajorVersion)
+	}
+}
+
+/*
+CheckSchema strictly validates the descriptor against the JSON Schema of its version.
+
+Violations are returned as an InvalidDescriptor error, whereas unknown keys - which
+are ignored while reading the descriptor - are returned as warnings.
+*/
+func CheckSchema(descriptorBytes []byte) (warnings []string, err error) {
+	descriptorBytes, err = ConvertToJSON(descriptorBytes, UnknownEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	basicDescriptor, err := createBasicDescriptor(descriptorBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptorVersion, err := parseDescriptorVersion(basicDescriptor.DescriptorVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaBytes, err := GetSchema(descriptorVersion.Major)
+	if err != nil {
+		return nil, err
+	}
+
+	rootSchema := &jsonSchema{}
+	err = json.Unmarshal(schemaBytes, rootSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(descriptorBytes))
+	decoder.UseNumber()
+
+	var descriptorValue interface{}
+	err = decoder.Decode(&descriptorValue)
+	if err != nil {
+		return nil, err
+	}
+
+	checker := &schemaChecker{
+		rootSchema: rootSchema,
+		problems:   []string{},
+		warnings:   []string{},
+	}
+
+	err = checker.check(rootSchema, descriptorValue, "")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid schema for descriptor version %v: %v", descriptorVersion.Major, err)
+	}
+
+	if len(checker.problems) > 0 {
+		return checker.warnings, &InvalidDescriptor{
+			Problems: checker.problems,
+		}
+	}
+
+	return checker.warnings, nil
+}
+
+func (checker *schemaChecker) addProblem(location string, format string, args ...interface{}) {
+	checker.problems = append(checker.problems, formatSchemaMessage(location, format, args...))
+}
+
+func (checker *schemaChecker) addWarning(location string, format string, args ...interface{}) {
+	checker.warnings = append(checker.warnings, formatSchemaMessage(location, format, args...))
+}
+
+func formatSchemaMessage(location string, format string, args ...interface{}) string {
+	message := fmt.Sprintf(format, args...)
+
+	if location == "" {
+		return message
+	}
+
+	return fmt.Sprintf("%v: %v", location, message)
+}
+
+func (checker *schemaChecker) resolve(schema *jsonSchema) (resolvedSchema *jsonSchema, err error) {
+	visitedRefs := make(map[string]bool)
+
+	for schema.Ref != "" {
+		if visitedRefs[schema.Ref] {
+			return nil, fmt.Errorf("Circular schema reference: '%v'", schema.Ref)
+		}
+		visitedRefs[schema.Ref] = true
+
+		definitionName := strings.TrimPrefix(schema.Ref, schemaDefinitionsPrefix)
+
+		referencedSchema := checker.rootSchema.Definitions[definitionName]
+		if referencedSchema == nil {
+			return nil, fmt.Errorf("Unresolved schema reference: '%v'", schema.Ref)
+		}
+
+		schema = referencedSchema
+	}
+
+	return schema, nil
+}
+
+/*
+check adds the problems and the warnings of the given value to the checker;
+the returned error, instead, is a defect of the schema itself
+*/
+func (checker *schemaChecker) check(schema *jsonSchema, value interface{}, location string) (err error) {
+	schema, err = checker.resolve(schema)
+	if err != nil {
+		return err
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	if len(schema.Enum) > 0 {
+		err = checker.checkEnum(schema, value, location)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch schema.Type {
+	case "object":
+		objectValue, isObject := value.(map[string]interface{})
+		if !isObject {
+			checker.addProblem(location, "must be an object")
+			return nil
+		}
+		return checker.checkObject(schema, objectValue, location)
+
+	case "array":
+		arrayValue, isArray := value.([]interface{})
+		if !isArray {
+			checker.addProblem(location, "must be an array")
+			return nil
+		}
+
+		if schema.Items != nil {
+			for itemIndex, item := range arrayValue {
+				err = checker.check(schema.Items, item, fmt.Sprintf("%v[%v]", location, itemIndex))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+	case "string":
+		if _, isString := value.(string); !isString {
+			if _, isNumber := value.(json.Number); isNumber {
+				checker.addProblem(location, "must be a string - please, enclose it in quotes")
+			} else {
+				checker.addProblem(location, "must be a string")
+			}
+		}
+
+	case "boolean":
+		if _, isBoolean := value.(bool); !isBoolean {
+			checker.addProblem(location, "must be a boolean")
+		}
+
+	case "integer":
+		numberValue, isNumber := value.(json.Number)
+		if !isNumber {
+			checker.addProblem(location, "must be an integer")
+			return nil
+		}
+
+		integerValue, parseErr := numberValue.Int64()
+		if parseErr != nil {
+			checker.addProblem(location, "must be an integer")
+			return nil
+		}
+
+		if schema.Minimum != nil && float64(integerValue) < *schema.Minimum {
+			checker.addProblem(location, "must be >= %v", *schema.Minimum)
+		}
+
+	case "":
+		return nil
+
+	default:
+		return fmt.Errorf("Unsupported schema type at '%v': '%v'", location, schema.Type)
+	}
+
+	return nil
+}
+
+/*
+checkEnum compares the JSON encoding of the value with the one of each allowed value,
+so that numbers match regardless of how they were decoded
+*/
+func (checker *schemaChecker) checkEnum(schema *jsonSchema, value interface{}, location string) (err error) {
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	allowedValues := []string{}
+
+	for _, allowedValue := range schema.Enum {
+		allowedValueBytes, err := json.Marshal(allowedValue)
+		if err != nil {
+			return fmt.Errorf("Invalid enum value in schema at '%v': %v", location, err)
+		}
+
+		if bytes.Equal(valueBytes, allowedValueBytes) {
+			return nil
+		}
+
+		allowedValues = append(allowedValues, string(allowedValueBytes))
+	}
+
+	checker.addProblem(location, "must be one of: %v", strings.Join(allowedValues, ", "))
+
+	return nil
+}
+
+func (checker *schemaChecker) checkObject(schema *jsonSchema, objectValue map[string]interface{}, location string) (err error) {
+	for _, requiredKey := range schema.Required {
+		if objectValue[requiredKey] == nil {
+			checker.addProblem(joinSchemaLocation(location, requiredKey), "field is missing")
+		}
+	}
+
+	keys := []string{}
+	for key := range objectValue {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	additionalPropertiesAllowed, additionalPropertiesSchema, err := checker.getAdditionalProperties(schema)
+	if err != nil {
+		return fmt.Errorf("Invalid additionalProperties in schema at '%v': %v", location, err)
+	}
+
+	for _, key := range keys {
+		keyLocation := joinSchemaLocation(location, key)
+
+		propertySchema := schema.Properties[key]
+		if propertySchema != nil {
+			err = checker.check(propertySchema, objectValue[key], keyLocation)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if additionalPropertiesSchema != nil {
+			err = checker.check(additionalPropertiesSchema, objectValue[key], keyLocation)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if additionalPropertiesAllowed {
+			continue
+		}
+
+		matchingKey := findKeyIgnoringCase(schema.Properties, key)
+		if matchingKey != "" {
+			checker.addWarning(keyLocation, "key should be written as '%v'", matchingKey)
+			err = checker.check(schema.Properties[matchingKey], objectValue[key], keyLocation)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		checker.addWarning(keyLocation, "unknown key - it will be ignored")
+	}
+
+	return nil
+}
+
+func (checker *schemaChecker) getAdditionalProperties(schema *jsonSchema) (allowed bool, additionalPropertiesSchema *jsonSchema, err error) {
+	rawAdditionalProperties := bytes.TrimSpace(schema.AdditionalProperties)
+
+	switch {
+	case len(rawAdditionalProperties) == 0:
+		return true, nil, nil
+
+	case bytes.Equal(rawAdditionalProperties, []byte("true")):
+		return true, nil, nil
+
+	case bytes.Equal(rawAdditionalProperties, []byte("false")):
+		return false, nil, nil
+	}
+
+	additionalPropertiesSchema = &jsonSchema{}
+	err = json.Unmarshal(rawAdditionalProperties, additionalPropertiesSchema)
+	if err != nil {
+		return false, nil, err
+	}
+
+	return true, additionalPropertiesSchema, nil
+}
+
+func findKeyIgnoringCase(properties map[string]*jsonSchema, key string) string {
+	for propertyName := range properties {
+		if strings.EqualFold(propertyName, key) {
+			return propertyName
+		}
+	}
+
+	return ""
+}
+
+func joinSchemaLocation(location string, key string) string {
+	if location == "" {
+		return key
+	}
+
+	return location + "." + key
+}
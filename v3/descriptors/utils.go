@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"net/url"
 	"path"
+	"strings"
 
 	"github.com/giancosta86/moondeploy/v3/versioning"
 )
@@ -64,8 +65,51 @@ func getRemoteFileURL(descriptor AppDescriptor, relativePath string) (*url.URL,
 	return descriptor.GetActualBaseURL().ResolveReference(relativeURL), nil
 }
 
+/*
+getRemoteFileURLs returns the URL of the given file on the actual base URL,
+followed by its URLs on the mirrors, in the order they were declared
+*/
+func getRemoteFileURLs(descriptor AppDescriptor, relativePath string) (result []*url.URL, err error) {
+	remoteFileURL, err := getRemoteFileURL(descriptor, relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	result = []*url.URL{remoteFileURL}
+
+	relativeURL, err := url.Parse(relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mirrorBaseURL := range descriptor.GetMirrorBaseURLs() {
+		result = append(result, mirrorBaseURL.ResolveReference(relativeURL))
+	}
+
+	return result, nil
+}
+
+func parseMirrorBaseURLs(mirrors []string) (result []*url.URL, err error) {
+	result = []*url.URL{}
+
+	for _, mirror := range mirrors {
+		mirrorBaseURL, err := url.Parse(ensureTrailingSlash(mirror))
+		if err != nil {
+			return nil, err
+		}
+
+		if !mirrorBaseURL.IsAbs() {
+			return nil, fmt.Errorf("Mirror base URLs must be absolute: '%v'", mirrorBaseURL.Redacted())
+		}
+
+		result = append(result, mirrorBaseURL)
+	}
+
+	return result, nil
+}
+
 func ensureTrailingSlash(path string) string {
-	if path[len(path)-1] != '/' {
+	if !strings.HasSuffix(path, "/") {
 		return path + "/"
 	}
 
@@ -27,80 +27,133 @@ import (
 	"strings"
 )
 
-func validate(descriptor AppDescriptor) (err error) {
+/*
+InvalidDescriptor is returned when a descriptor fails validation;
+it lists all the problems that were found
+*/
+type InvalidDescriptor struct {
+	Problems []string
+}
+
+func (err *InvalidDescriptor) Error() string {
+	return fmt.Sprintf("The descriptor is invalid:\n\n* %v",
+		strings.Join(err.Problems, "\n* "))
+}
+
+func getValidationProblems(descriptor AppDescriptor) (problems []string) {
+	problems = []string{}
+
 	if descriptor.GetDescriptorVersion() == nil {
-		return fmt.Errorf("Descriptor Version field is missing")
+		problems = append(problems, "Descriptor Version field is missing")
 	}
 
 	if descriptor.GetDeclaredBaseURL() == nil {
-		return fmt.Errorf("Declared Base URL field is missing")
-	}
-
-	if descriptor.GetActualBaseURL() == nil {
-		return fmt.Errorf("Actual Base URL field is missing")
+		problems = append(problems, "Declared Base URL field is missing")
+	} else if descriptor.GetActualBaseURL() == nil {
+		problems = append(problems, "Actual Base URL field is missing")
 	}
 
 	descriptorFileName := descriptor.GetDescriptorFileName()
 	if strings.TrimSpace(descriptorFileName) == "" {
-		return fmt.Errorf("Descriptor File Name field is missing")
-	}
-
-	if !strings.HasSuffix(descriptorFileName, ".moondeploy") {
-		return fmt.Errorf("The descriptor filename must end with .moondeploy")
-	}
-
-	if path.Base(descriptorFileName) != descriptorFileName {
-		return fmt.Errorf("Descriptor File Name cannot be a path")
+		problems = append(problems, "Descriptor File Name field is missing")
+	} else if !strings.HasSuffix(descriptorFileName, ".moondeploy") {
+		problems = append(problems, "The descriptor filename must end with .moondeploy")
+	} else if path.Base(descriptorFileName) != descriptorFileName {
+		problems = append(problems, "Descriptor File Name cannot be a path")
 	}
 
 	if strings.TrimSpace(descriptor.GetName()) == "" {
-		return fmt.Errorf("Name field is missing")
+		problems = append(problems, "Name field is missing")
 	}
 
 	if descriptor.GetAppVersion() == nil {
-		return fmt.Errorf("App version field is missing")
+		problems = append(problems, "App version field is missing")
 	}
 
 	if strings.TrimSpace(descriptor.GetPublisher()) == "" {
-		return fmt.Errorf("Publisher field is missing")
+		problems = append(problems, "Publisher field is missing")
 	}
 
 	if strings.TrimSpace(descriptor.GetDescription()) == "" {
-		return fmt.Errorf("Description field is missing")
+		problems = append(problems, "Description field is missing")
 	}
 
 	if descriptor.GetPackageVersions() == nil {
-		return fmt.Errorf("Package versions field is missing")
+		problems = append(problems, "Package versions field is missing")
+	}
+
+	for packageName := range descriptor.GetPackageVersions() {
+		rawPackage := descriptor.GetRawPackage(packageName)
+		if rawPackage == nil {
+			continue
+		}
+
+		if !isSafeRelativePath(rawPackage.TargetPath) {
+			problems = append(problems, fmt.Sprintf("The target path of raw package '%v' must be relative and within the app: '%v'",
+				packageName,
+				rawPackage.TargetPath))
+		}
 	}
 
 	iconPath := descriptor.GetIconPath()
 	if iconPath != "" {
 		if filepath.IsAbs(iconPath) {
-			return fmt.Errorf("The Icon Path must be relative")
+			problems = append(problems, "The Icon Path must be relative")
 		}
 	}
 
 	commandLine := descriptor.GetCommandLine()
 	if commandLine == nil || len(commandLine) == 0 {
-		return fmt.Errorf("Command Line field is missing")
+		problems = append(problems, "Command Line field is missing")
 	}
 
 	if descriptor.GetSkipPackageLevels() < 0 {
-		return fmt.Errorf("SkipPackageLevels field must be >= 0")
+		problems = append(problems, "SkipPackageLevels field must be >= 0")
+	}
+
+	if descriptor.GetMaxPackageEntries() < 0 {
+		problems = append(problems, "MaxPackageEntries field must be >= 0")
+	}
+
+	if descriptor.GetMaxPackageSizeInMB() < 0 {
+		problems = append(problems, "MaxPackageSizeInMB field must be >= 0")
+	}
+
+	restartPolicy := descriptor.GetRestartPolicy()
+
+	if restartPolicy.MaxRetries < 0 {
+		problems = append(problems, "The MaxRetries field of the restart policy must be >= 0")
+	}
+
+	if restartPolicy.RetryDelayInSeconds < 0 {
+		problems = append(problems, "The RetryDelayInSeconds field of the restart policy must be >= 0")
+	}
+
+	launchMode := descriptor.GetLaunchMode()
+
+	if launchMode != "" && !IsValidLaunchMode(launchMode) {
+		problems = append(problems, fmt.Sprintf("Invalid LaunchMode: '%v'. Expected '%v' or '%v'",
+			launchMode,
+			AttachedLaunchMode,
+			DetachedLaunchMode))
+	}
+
+	if launchMode == DetachedLaunchMode && restartPolicy.IsEnabled() {
+		problems = append(problems, "The restart policy requires the attached launch mode")
 	}
 
 	if strings.TrimSpace(descriptor.GetTitle()) == "" {
-		return fmt.Errorf("Title is missing")
+		problems = append(problems, "Title is missing")
 	}
 
-	return nil
+	return problems
 }
 
 func CheckDescriptorMatch(descriptor AppDescriptor, otherDescriptor AppDescriptor) (err error) {
 	if descriptor.GetDeclaredBaseURL().String() != otherDescriptor.GetDeclaredBaseURL().String() {
 		return fmt.Errorf("The descriptors have different BaseURL's:\n\t'%v'\n\t'%v'",
-			descriptor.GetDeclaredBaseURL(),
-			otherDescriptor.GetDeclaredBaseURL())
+			descriptor.GetDeclaredBaseURL().Redacted(),
+			otherDescriptor.GetDeclaredBaseURL().Redacted())
 	}
 
 	if descriptor.GetDescriptorFileName() != otherDescriptor.GetDescriptorFileName() {
@@ -117,3 +170,22 @@ func CheckDescriptorMatch(descriptor AppDescriptor, otherDescriptor AppDescripto
 
 	return nil
 }
+
+func isSafeRelativePath(relativePath string) bool {
+	if strings.TrimSpace(relativePath) == "" ||
+		path.IsAbs(relativePath) ||
+		filepath.IsAbs(relativePath) ||
+		filepath.VolumeName(relativePath) != "" {
+		return false
+	}
+
+	for _, component := range strings.FieldsFunc(relativePath, func(character rune) bool {
+		return character == '/' || character == '\\'
+	}) {
+		if component == ".." {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,737 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/giancosta86/moondeploy/v3/apps"
+	"github.com/giancosta86/moondeploy/v3/config"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/launchers"
+	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/networking"
+	"github.com/giancosta86/moondeploy/v3/ui"
+)
+
+/*
+stableRunDuration is how long the app must run before a crash is no longer
+considered consecutive to the previous ones
+*/
+const stableRunDuration = 1 * time.Minute
+
+/*
+Engine exposes the phases of Run as separate operations, so that custom
+installers can compose them - for example, to update an app without
+launching it, or to check for updates without downloading anything.
+
+Resolve() must be called before Update() and Launch(), as it locks the app
+directory and chooses the reference descriptor, while CheckForUpdates() can
+also be called without it - just reading the installed files; Close() must
+always be called at the end, to release the lock and dismiss the user interface.
+*/
+type Engine struct {
+	launcher       launchers.Launcher
+	userInterface  ui.UserInterface
+	bootDescriptor descriptors.AppDescriptor
+
+	settings    config.Settings
+	retryPolicy networking.RetryPolicy
+
+	events                   *eventBus
+	unsubscribeUserInterface func()
+
+	appGallery *apps.AppGallery
+	app        *apps.App
+	firstRun   bool
+
+	localDescriptor     descriptors.AppDescriptor
+	remoteDescriptor    descriptors.AppDescriptor
+	referenceDescriptor descriptors.AppDescriptor
+
+	updateOutcome *UpdateOutcome
+}
+
+func NewEngine(
+	launcher launchers.Launcher,
+	userInterface ui.UserInterface,
+	bootDescriptor descriptors.AppDescriptor) *Engine {
+
+	settings := launcher.GetSettings()
+
+	return &Engine{
+		launcher:       launcher,
+		userInterface:  userInterface,
+		bootDescriptor: bootDescriptor,
+
+		settings: settings,
+		retryPolicy: networking.RetryPolicy{
+			Attempts:     settings.GetRetrievalAttempts(),
+			InitialDelay: time.Duration(settings.GetRetryDelayInMilliseconds()) * time.Millisecond,
+		},
+
+		events: newEventBus(),
+	}
+}
+
+/*
+Subscribe registers a listener for the events emitted by this engine only,
+returning the function that cancels the subscription. Listeners are called
+synchronously, from the goroutine calling the engine, so they should return quickly
+*/
+func (engine *Engine) Subscribe(listener EventListener) (unsubscribe func()) {
+	return engine.events.subscribe(listener)
+}
+
+/*
+GetApp returns the resolved app, or nil if Resolve() has not succeeded
+*/
+func (engine *Engine) GetApp() *apps.App {
+	return engine.app
+}
+
+/*
+GetReferenceDescriptor returns the descriptor chosen by Resolve() - or nil
+*/
+func (engine *Engine) GetReferenceDescriptor() descriptors.AppDescriptor {
+	return engine.referenceDescriptor
+}
+
+/*
+GetUpdateOutcome returns what Update() has done - or nil if it has not succeeded
+*/
+func (engine *Engine) GetUpdateOutcome() *UpdateOutcome {
+	return engine.updateOutcome
+}
+
+/*
+Resolve shows the user interface, finds the app in the gallery - asking the
+user before a first run - locks its directory and chooses the reference
+descriptor between the local and the remote one
+*/
+func (engine *Engine) Resolve(ctx context.Context) (err error) {
+	stages := &stageTracker{emit: engine.emit}
+	defer func() {
+		stages.finish(err)
+	}()
+
+	defer engine.handleInterruption(ctx, &err)
+
+	defer func() {
+		if err != nil {
+			engine.unlock()
+		}
+	}()
+
+	if engine.referenceDescriptor != nil {
+		return nil
+	}
+
+	userInterface := engine.userInterface
+	bootDescriptor := engine.bootDescriptor
+
+	if engine.unsubscribeUserInterface == nil {
+		engine.unsubscribeUserInterface = engine.setupUserInterface()
+	}
+
+	ctx = engine.withRetrievals(ctx, bootDescriptor.GetName())
+
+	//----------------------------------------------------------------------------
+
+	stages.start(StartupStage, "Performing startup operations")
+
+	log.Debug("The boot descriptor is: %#v", bootDescriptor)
+
+	//----------------------------------------------------------------------------
+
+	userInterface.SetApp(bootDescriptor.GetName())
+
+	//----------------------------------------------------------------------------
+
+	app, err := engine.resolveApp()
+	if err != nil {
+		return err
+	}
+
+	firstRun := !app.DirectoryExists()
+	log.Debug("Is this a first run for the app? %v", firstRun)
+
+	//----------------------------------------------------------------------------
+
+	if firstRun {
+		log.Info("Now asking the user if the app can run...")
+
+		canRun := app.CanPerformFirstRun(userInterface)
+		if !canRun {
+			return &ExecutionCanceled{}
+		}
+
+		log.Debug("The user agreed to proceed")
+
+		log.Info("Ensuring the app dir is available...")
+		err = app.EnsureDirectory()
+		if err != nil {
+			return err
+		}
+		log.Notice("App dir available")
+	}
+
+	//----------------------------------------------------------------------------
+
+	log.Info("Locking the app dir...")
+	err = engine.lockAppDirectory(ctx, app, bootDescriptor.GetName())
+	if err != nil {
+		return err
+	}
+	log.Notice("App dir locked")
+
+	engine.firstRun = firstRun
+
+	//----------------------------------------------------------------------------
+
+	log.Info("Checking for conflicting local descriptors...")
+	err = app.CheckForConflictingLocalDescriptors()
+	if err != nil {
+		return err
+	}
+	log.Notice("No conflicting local descriptors found")
+
+	//----------------------------------------------------------------------------
+
+	stages.start(DescriptorResolutionStage, "Resolving the descriptors")
+
+	log.Info("Resolving the local descriptor...")
+	localDescriptor := app.GetLocalDescriptor(ctx)
+
+	log.Debug("Started with local descriptor? %v", localDescriptor != nil)
+
+	if localDescriptor != nil {
+		log.Info("Checking that local descriptor and boot descriptor actually match...")
+		err = descriptors.CheckDescriptorMatch(localDescriptor, bootDescriptor)
+		if err != nil {
+			return err
+		}
+		log.Notice("The descriptors match correctly")
+	}
+
+	//----------------------------------------------------------------------------
+
+	log.Info("Resolving the remote descriptor...")
+	remoteDescriptor := app.GetRemoteDescriptor(ctx)
+
+	if remoteDescriptor != nil {
+		log.Info("Checking that remote descriptor and boot descriptor actually match...")
+		err = descriptors.CheckDescriptorMatch(remoteDescriptor, bootDescriptor)
+		if err != nil {
+			return err
+		}
+		log.Notice("The descriptors match correctly")
+	}
+
+	//----------------------------------------------------------------------------
+
+	log.Info("Now choosing the reference descriptor...")
+	referenceDescriptor, err := app.GetReferenceDescriptor(ctx)
+	if err != nil {
+		return err
+	}
+	log.Notice("Reference descriptor chosen")
+
+	log.Debug("The reference descriptor is: %#v", referenceDescriptor)
+
+	engine.localDescriptor = localDescriptor
+	engine.remoteDescriptor = remoteDescriptor
+	engine.referenceDescriptor = referenceDescriptor
+
+	referenceIsRemote := remoteDescriptor != nil && referenceDescriptor == remoteDescriptor
+
+	if referenceIsRemote && localDescriptor != nil {
+		engine.emit(&UpdateAvailable{
+			App:              referenceDescriptor.GetName(),
+			InstalledVersion: localDescriptor.GetAppVersion(),
+			AvailableVersion: remoteDescriptor.GetAppVersion(),
+		})
+	}
+
+	if remoteDescriptor == nil && app.GetRemoteDescriptorError() != nil {
+		engine.emit(&RemoteDescriptorUnavailable{
+			App: bootDescriptor.GetName(),
+			Err: app.GetRemoteDescriptorError(),
+		})
+	}
+
+	engine.emit(&DescriptorChosen{
+		Descriptor: referenceDescriptor,
+		Remote:     referenceIsRemote,
+	})
+
+	//----------------------------------------------------------------------------
+
+	userInterface.SetApp(referenceDescriptor.GetTitle())
+
+	return nil
+}
+
+/*
+CheckForUpdates compares the local and the remote descriptor, without
+downloading any package; if Resolve() has not been called, the app directory
+is neither created nor locked.
+
+Failing to retrieve the remote descriptor is an error - unless the local
+descriptor skips the update check
+*/
+func (engine *Engine) CheckForUpdates(ctx context.Context) (updateCheck *UpdateCheck, err error) {
+	app, err := engine.resolveApp()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = engine.withRetrievals(ctx, engine.bootDescriptor.GetName())
+
+	updateCheck = &UpdateCheck{
+		App:            engine.bootDescriptor.GetName(),
+		PackageChanges: app.GetPackageChanges(ctx),
+	}
+
+	localDescriptor := app.GetLocalDescriptor(ctx)
+	if localDescriptor != nil {
+		updateCheck.InstalledVersion = localDescriptor.GetAppVersion()
+	}
+
+	remoteDescriptor := app.GetRemoteDescriptor(ctx)
+	if remoteDescriptor != nil {
+		updateCheck.AvailableVersion = remoteDescriptor.GetAppVersion()
+	}
+
+	if ctx.Err() != nil {
+		return nil, &ExecutionCanceled{}
+	}
+
+	remoteDescriptorErr := app.GetRemoteDescriptorError()
+	if remoteDescriptorErr != nil {
+		return nil, fmt.Errorf("Cannot retrieve the remote descriptor: %v", remoteDescriptorErr)
+	}
+
+	return updateCheck, nil
+}
+
+/*
+Update installs the missing runtimes, checks the requirements and retrieves
+the packages changed in the reference descriptor, which is then saved;
+after a first installation, it also offers to create a desktop shortcut.
+
+If the app is already running, its files are not replaced: the update
+is just staged, and the installed version is kept as the reference
+*/
+func (engine *Engine) Update(ctx context.Context) (err error) {
+	stages := &stageTracker{emit: engine.emit}
+	defer func() {
+		stages.finish(err)
+	}()
+
+	defer engine.handleInterruption(ctx, &err)
+
+	err = engine.checkResolved("update")
+	if err != nil {
+		return err
+	}
+
+	app := engine.app
+	referenceDescriptor := engine.referenceDescriptor
+	userInterface := engine.userInterface
+
+	ctx = engine.withRetrievals(ctx, referenceDescriptor.GetName())
+
+	//----------------------------------------------------------------------------
+
+	stages.start(RequirementsCheckStage, "Checking the requirements")
+
+	err = engine.provisionRuntimes(ctx, referenceDescriptor)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Checking the app requirements...")
+	err = referenceDescriptor.CheckRequirements(app.Directory)
+	if err != nil {
+		return err
+	}
+	log.Notice("Requirements satisfied")
+
+	//----------------------------------------------------------------------------
+
+	updateOutcome := &UpdateOutcome{
+		App:                 referenceDescriptor.GetName(),
+		RemoteDescriptorErr: app.GetRemoteDescriptorError(),
+	}
+
+	if engine.localDescriptor != nil {
+		updateOutcome.PreviousVersion = engine.localDescriptor.GetAppVersion()
+	}
+
+	if engine.localDescriptor != nil && app.IsRunning() {
+		stages.start(UpdateStagingStage, "The app is running: staging the update")
+
+		if engine.remoteDescriptor != nil &&
+			engine.remoteDescriptor.GetAppVersion().NewerThan(engine.localDescriptor.GetAppVersion()) {
+			updateOutcome.StagedVersion = engine.remoteDescriptor.GetAppVersion()
+		}
+
+		referenceDescriptor, err = app.DeferUpdate(ctx, engine.settings, engine.newDownloadProgressEmitter(referenceDescriptor.GetName()))
+		if err != nil {
+			return err
+		}
+
+		engine.referenceDescriptor = referenceDescriptor
+	} else {
+		stages.start(FilesCheckStage, "Checking the app files")
+
+		err = app.CheckFiles(ctx, engine.settings, engine.newDownloadProgressEmitter(referenceDescriptor.GetName()))
+		if err != nil {
+			return err
+		}
+	}
+
+	//----------------------------------------------------------------------------
+
+	referenceDescriptorSaved := app.SaveReferenceDescriptor(ctx)
+
+	if engine.localDescriptor == nil && referenceDescriptorSaved {
+		if userInterface.AskForDesktopShortcut(referenceDescriptor) {
+			log.Info("Creating desktop shortcut...")
+
+			err = app.CreateDesktopShortcut(engine.launcher, referenceDescriptor)
+			if err != nil {
+				log.Warning("Could not create desktop shortcut: %v", err)
+			} else {
+				log.Notice("Desktop shortcut created")
+			}
+		} else {
+			log.Info("The user refused to create a desktop shortcut")
+		}
+	}
+
+	engine.firstRun = false
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	updateOutcome.CurrentVersion = referenceDescriptor.GetAppVersion()
+	engine.updateOutcome = updateOutcome
+
+	return nil
+}
+
+/*
+StageUpdate retrieves, in the background, the packages of a newer version
+of an installed app, without altering the installed files: the next Update()
+will just swap them in. The packages are retrieved into a private directory
+without locking the app directory, which is only locked - unless Resolve()
+has already locked it - to commit the staged update
+*/
+func (engine *Engine) StageUpdate(ctx context.Context) (staged bool, err error) {
+	stages := &stageTracker{emit: engine.emit}
+	defer func() {
+		stages.finish(err)
+	}()
+
+	defer engine.handleInterruption(ctx, &err)
+
+	app, err := engine.resolveApp()
+	if err != nil {
+		return false, err
+	}
+
+	if !app.DirectoryExists() {
+		log.Notice("Skipping the staging, as the app is not installed")
+		return false, nil
+	}
+
+	stages.start(UpdateStagingStage, "Staging the update")
+
+	ctx = engine.withRetrievals(ctx, engine.bootDescriptor.GetName())
+
+	update, err := app.PrepareUpdate(ctx, engine.settings, engine.newDownloadProgressEmitter(engine.bootDescriptor.GetName()))
+	if err != nil || update == nil {
+		return false, err
+	}
+	defer app.DiscardPreparedUpdate(update)
+
+	if engine.referenceDescriptor == nil {
+		log.Info("Locking the app dir...")
+		err = engine.lockAppDirectory(ctx, app, engine.bootDescriptor.GetName())
+		if err != nil {
+			return false, err
+		}
+		defer engine.unlock()
+		log.Notice("App dir locked")
+	}
+
+	return app.CommitUpdate(ctx, update)
+}
+
+/*
+Launch releases the lock on the app directory and starts the app, waiting
+for it to exit - and restarting it if it crashes, according to the restart
+policy of the reference descriptor; the context is only checked before
+starting the app, which is not bound to it.
+
+In detached launch mode, Launch returns as soon as the app has started.
+
+While the app is running, it is registered as a running instance - so that
+other processes can detect it; a single-instance app cannot be launched
+if another instance is running
+*/
+func (engine *Engine) Launch(ctx context.Context) (err error) {
+	stages := &stageTracker{emit: engine.emit}
+	defer func() {
+		stages.finish(err)
+	}()
+
+	defer engine.handleInterruption(ctx, &err)
+
+	err = engine.checkResolved("launch")
+	if err != nil {
+		return err
+	}
+
+	app := engine.app
+
+	//----------------------------------------------------------------------------
+
+	stages.start(CommandPreparationStage, "Preparing the command...")
+
+	log.Info("Resolving the OS-specific app command line...")
+	commandLine := engine.referenceDescriptor.GetCommandLine()
+	log.Notice("Command line resolved")
+
+	log.Debug("Command line is: %v", commandLine)
+
+	log.Info("Creating the command...")
+	command := app.PrepareCommand(commandLine)
+	log.Notice("Command created")
+
+	log.Debug("Command path: %v", command.Path)
+	log.Debug("Command arguments: %v", command.Args)
+
+	//----------------------------------------------------------------------------
+
+	if engine.referenceDescriptor.IsSingleInstance() && app.IsRunning() {
+		return &apps.AppAlreadyRunning{AppName: engine.referenceDescriptor.GetName()}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	launchMode := engine.getLaunchMode()
+	log.Debug("Launch mode: %v", launchMode)
+
+	if launchMode == descriptors.DetachedLaunchMode {
+		stages.start(LaunchStage, "Launching the application")
+
+		return app.LaunchDetached(command, engine.settings, engine.userInterface)
+	}
+
+	log.Info("Registering the app instance...")
+	err = app.RegisterInstance()
+	if err != nil {
+		return err
+	}
+	defer engine.unregisterInstance()
+	log.Notice("App instance registered")
+
+	engine.unlock()
+
+	//----------------------------------------------------------------------------
+
+	stages.start(LaunchStage, "Launching the application")
+
+	return engine.superviseApp(ctx, command, commandLine)
+}
+
+/*
+getLaunchMode returns the launch mode declared by the reference descriptor,
+falling back to the one chosen in the settings - unless the descriptor
+declares a restart policy, which requires the attached mode
+*/
+func (engine *Engine) getLaunchMode() string {
+	launchMode := engine.referenceDescriptor.GetLaunchMode()
+	if launchMode != "" {
+		return launchMode
+	}
+
+	if engine.referenceDescriptor.GetRestartPolicy().IsEnabled() {
+		return descriptors.AttachedLaunchMode
+	}
+
+	return engine.settings.GetLaunchMode()
+}
+
+/*
+superviseApp runs the app, restarting it whenever it fails - as long as the
+restart policy allows it; the retry count is reset when the app crashes
+after running for at least stableRunDuration
+*/
+func (engine *Engine) superviseApp(ctx context.Context, command *exec.Cmd, commandLine []string) (err error) {
+	app := engine.app
+	appName := engine.referenceDescriptor.GetName()
+	restartPolicy := engine.referenceDescriptor.GetRestartPolicy()
+
+	retries := 0
+
+	for {
+		startTime := time.Now()
+
+		err = app.Launch(command, engine.settings, engine.userInterface)
+
+		_, appFailed := err.(*apps.AppFailed)
+		if !appFailed || !restartPolicy.IsEnabled() || ctx.Err() != nil {
+			return err
+		}
+
+		if time.Since(startTime) >= stableRunDuration {
+			retries = 0
+		}
+
+		if retries >= restartPolicy.MaxRetries {
+			log.Warning("The app has crashed too many times: giving up")
+			return err
+		}
+
+		retries++
+
+		log.Warning("%v - restarting it in %v (%v of %v)...",
+			err,
+			restartPolicy.GetRetryDelay(),
+			retries,
+			restartPolicy.MaxRetries)
+
+		engine.emit(&AppRestarting{
+			App:        appName,
+			Err:        err,
+			Retry:      retries,
+			MaxRetries: restartPolicy.MaxRetries,
+		})
+
+		err = networking.SleepWithContext(ctx, restartPolicy.GetRetryDelay())
+		if err != nil {
+			return err
+		}
+
+		command = app.PrepareCommand(commandLine)
+	}
+}
+
+/*
+Close releases the lock on the app directory and dismisses the user interface
+*/
+func (engine *Engine) Close() {
+	engine.unregisterInstance()
+	engine.unlock()
+
+	if engine.unsubscribeUserInterface != nil {
+		engine.dismissUserInterface()
+		engine.unsubscribeUserInterface = nil
+	}
+}
+
+/*
+resolveApp finds the app in the gallery, without accessing its directory
+*/
+func (engine *Engine) resolveApp() (app *apps.App, err error) {
+	if engine.app != nil {
+		return engine.app, nil
+	}
+
+	appGallery := apps.NewAppGallery(engine.settings.GetGalleryDirectory())
+	log.Debug("The app gallery is: %#v", appGallery)
+
+	log.Info("Resolving the app...")
+	app, err = appGallery.GetApp(engine.bootDescriptor)
+	if err != nil {
+		return nil, err
+	}
+	log.Notice("The app directory is: '%v'", app.Directory)
+
+	log.Debug("App is: %#v", app)
+
+	engine.appGallery = appGallery
+	engine.app = app
+
+	return app, nil
+}
+
+func (engine *Engine) checkResolved(operation string) error {
+	if engine.referenceDescriptor == nil {
+		return fmt.Errorf("Cannot %v: the app has not been resolved", operation)
+	}
+
+	return nil
+}
+
+func (engine *Engine) unlock() {
+	if engine.app == nil {
+		return
+	}
+
+	err := engine.app.UnlockDirectory()
+	if err != nil {
+		log.Warning(err.Error())
+	}
+}
+
+func (engine *Engine) unregisterInstance() {
+	if engine.app == nil {
+		return
+	}
+
+	err := engine.app.UnregisterInstance()
+	if err != nil {
+		log.Warning(err.Error())
+	}
+}
+
+/*
+handleInterruption converts any error caused by the context into
+ExecutionCanceled, also removing the app directory after an interrupted
+first run
+*/
+func (engine *Engine) handleInterruption(ctx context.Context, err *error) {
+	if *err == nil || ctx.Err() == nil {
+		return
+	}
+
+	log.Warning("Execution interrupted: %v", *err)
+
+	if engine.firstRun && engine.app != nil {
+		engine.unlock()
+		removeCanceledFirstRun(engine.app)
+		engine.app = nil
+		engine.referenceDescriptor = nil
+	}
+
+	*err = &ExecutionCanceled{}
+}
@@ -0,0 +1,355 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/networking"
+	"github.com/giancosta86/moondeploy/v3/versioning"
+)
+
+/*
+Event is a typed notification describing the progress of Run; unlike log
+messages, events are meant to be processed by programs - user interfaces
+and custom installers - while String() returns a human-readable description
+*/
+type Event interface {
+	String() string
+}
+
+/*
+EventListener receives the events emitted by Run. A user interface
+implementing EventListener receives all the events emitted while it is
+in use; otherwise, the events are mapped to its SetHeader(), SetStatus()
+and SetProgress() methods
+*/
+type EventListener interface {
+	HandleEvent(event Event)
+}
+
+/*
+EventListenerFunc is a function that can be used as an EventListener
+*/
+type EventListenerFunc func(event Event)
+
+func (listenerFunc EventListenerFunc) HandleEvent(event Event) {
+	listenerFunc(event)
+}
+
+/*
+Stage identifies a phase of Run
+*/
+type Stage string
+
+const (
+	StartupStage              Stage = "startup"
+	DescriptorResolutionStage Stage = "descriptorResolution"
+	RequirementsCheckStage    Stage = "requirementsCheck"
+	RuntimeInstallationStage  Stage = "runtimeInstallation"
+	FilesCheckStage           Stage = "filesCheck"
+	CommandPreparationStage   Stage = "commandPreparation"
+	LaunchStage               Stage = "launch"
+	UpdateStagingStage        Stage = "updateStaging"
+)
+
+/*
+StageStarted is emitted when Run enters a stage; stages can be nested,
+as the installation of a runtime happens within the requirements check
+*/
+type StageStarted struct {
+	Stage       Stage
+	Description string
+}
+
+func (event *StageStarted) String() string {
+	return event.Description
+}
+
+/*
+StageFinished is emitted when a stage ends; Err is nil on success
+*/
+type StageFinished struct {
+	Stage Stage
+	Err   error
+}
+
+func (event *StageFinished) String() string {
+	if event.Err != nil {
+		return fmt.Sprintf("Stage '%v' failed: %v", event.Stage, event.Err)
+	}
+
+	return fmt.Sprintf("Stage '%v' completed", event.Stage)
+}
+
+/*
+DescriptorChosen is emitted when the reference descriptor - the one
+actually used to run the app - has been chosen between the local and
+the remote descriptor
+*/
+type DescriptorChosen struct {
+	Descriptor descriptors.AppDescriptor
+	Remote     bool
+}
+
+func (event *DescriptorChosen) String() string {
+	source := "local"
+	if event.Remote {
+		source = "remote"
+	}
+
+	return fmt.Sprintf("Using the %v descriptor of %v", source, event.Descriptor.GetTitle())
+}
+
+/*
+UpdateAvailable is emitted when the remote descriptor declares a version
+newer than the installed one
+*/
+type UpdateAvailable struct {
+	App              string
+	InstalledVersion *versioning.Version
+	AvailableVersion *versioning.Version
+}
+
+func (event *UpdateAvailable) String() string {
+	return fmt.Sprintf("Update available for %v: %v -> %v",
+		event.App,
+		event.InstalledVersion,
+		event.AvailableVersion)
+}
+
+/*
+LockWaiting is emitted when the app directory is locked by another process -
+usually another MoonDeploy instance updating the app - and the engine starts
+waiting for the lock to be released
+*/
+type LockWaiting struct {
+	App     string
+	Timeout time.Duration
+}
+
+func (event *LockWaiting) String() string {
+	return fmt.Sprintf("Another update of %v is in progress: waiting for at most %v...",
+		event.App,
+		event.Timeout)
+}
+
+/*
+AppRestarting is emitted when the app has crashed and is going to be restarted,
+according to the restart policy of its descriptor; Retry starts from 1
+*/
+type AppRestarting struct {
+	App        string
+	Err        error
+	Retry      int
+	MaxRetries int
+}
+
+func (event *AppRestarting) String() string {
+	return fmt.Sprintf("%v - restarting it (%v of %v)...",
+		event.Err,
+		event.Retry,
+		event.MaxRetries)
+}
+
+/*
+RetrievalAttempt is emitted when a file - a descriptor or a package - is
+requested to a host; Attempt describes the mirror and the attempt in use
+*/
+type RetrievalAttempt struct {
+	App     string
+	Host    string
+	Attempt networking.RetrievalAttempt
+}
+
+func (event *RetrievalAttempt) String() string {
+	return fmt.Sprintf("Retrieving from %v - %v...", event.Host, event.Attempt)
+}
+
+/*
+RetrievalRetrying is emitted when a retrieval has failed with a transient
+error and is going to be retried after the given delay
+*/
+type RetrievalRetrying struct {
+	App   string
+	Host  string
+	Err   error
+	Delay time.Duration
+}
+
+func (event *RetrievalRetrying) String() string {
+	return fmt.Sprintf("Retrieval from %v failed: %v - retrying in %v...",
+		event.Host,
+		event.Err,
+		event.Delay)
+}
+
+/*
+MirrorSwitched is emitted when every attempt on a mirror has failed and
+the retrieval moves on to the next mirror
+*/
+type MirrorSwitched struct {
+	App        string
+	FailedHost string
+	NextHost   string
+	Err        error
+}
+
+func (event *MirrorSwitched) String() string {
+	return fmt.Sprintf("%v is unavailable - switching to %v...", event.FailedHost, event.NextHost)
+}
+
+/*
+RequestsThrottled is emitted when a host - for example, GitHub's API -
+is throttling the requests, and the engine waits before retrying
+*/
+type RequestsThrottled struct {
+	App   string
+	Host  string
+	Delay time.Duration
+}
+
+func (event *RequestsThrottled) String() string {
+	return fmt.Sprintf("%v is throttling the requests: retrying in %v...", event.Host, event.Delay)
+}
+
+/*
+RemoteDescriptorUnavailable is emitted when the remote descriptor cannot be
+retrieved, so the local descriptor is used instead; Err is the reason
+*/
+type RemoteDescriptorUnavailable struct {
+	App string
+	Err error
+}
+
+func (event *RemoteDescriptorUnavailable) String() string {
+	return fmt.Sprintf("Cannot check for updates (%v): using the installed version", event.Err)
+}
+
+/*
+DownloadProgress is emitted while retrieving a package; PackageIndex
+starts from 1, and TotalBytes is <= 0 when the size is unknown
+*/
+type DownloadProgress struct {
+	App            string
+	PackageName    string
+	PackageIndex   int
+	PackageCount   int
+	RetrievedBytes int64
+	TotalBytes     int64
+}
+
+func (event *DownloadProgress) String() string {
+	return fmt.Sprintf("Retrieved: %v / %v bytes", event.RetrievedBytes, event.TotalBytes)
+}
+
+/*
+eventBus dispatches the emitted events to its listeners, which are
+called synchronously - so they should return quickly
+*/
+type eventBus struct {
+	listeners      map[int]EventListener
+	nextListenerID int
+	mutex          sync.Mutex
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		listeners: make(map[int]EventListener),
+	}
+}
+
+func (bus *eventBus) subscribe(listener EventListener) (unsubscribe func()) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	listenerID := bus.nextListenerID
+	bus.nextListenerID++
+
+	bus.listeners[listenerID] = listener
+
+	return func() {
+		bus.mutex.Lock()
+		defer bus.mutex.Unlock()
+
+		delete(bus.listeners, listenerID)
+	}
+}
+
+func (bus *eventBus) emit(event Event) {
+	bus.mutex.Lock()
+	listeners := make([]EventListener, 0, len(bus.listeners))
+	for _, listener := range bus.listeners {
+		listeners = append(listeners, listener)
+	}
+	bus.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener.HandleEvent(event)
+	}
+}
+
+var globalEventBus = newEventBus()
+
+/*
+Subscribe registers a process-wide listener, receiving the events emitted
+by every engine - and therefore by every call to Run - and returns the function
+that cancels the subscription. To receive only the events of a given engine,
+use Engine.Subscribe() instead
+*/
+func Subscribe(listener EventListener) (unsubscribe func()) {
+	return globalEventBus.subscribe(listener)
+}
+
+/*
+stageTracker emits the events of the sequential stages of Run: starting a
+stage finishes the current one, and the last stage is finished - with the
+error returned by Run - via finish()
+*/
+type stageTracker struct {
+	emit         func(event Event)
+	currentStage Stage
+}
+
+func (tracker *stageTracker) start(stage Stage, description string) {
+	tracker.finish(nil)
+
+	tracker.currentStage = stage
+	tracker.emit(&StageStarted{
+		Stage:       stage,
+		Description: description,
+	})
+}
+
+func (tracker *stageTracker) finish(err error) {
+	if tracker.currentStage == "" {
+		return
+	}
+
+	tracker.emit(&StageFinished{
+		Stage: tracker.currentStage,
+		Err:   err,
+	})
+	tracker.currentStage = ""
+}
@@ -0,0 +1,85 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package engine
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/giancosta86/moondeploy/v3/networking"
+)
+
+/*
+retrievalEventEmitter is a networking.RetrievalObserver converting the
+progress of the retrievals performed for an app into engine events
+*/
+type retrievalEventEmitter struct {
+	engine  *Engine
+	appName string
+}
+
+/*
+withRetrievals returns a copy of the given context whose retrievals follow
+the retry policy of the engine and emit events on behalf of the given app
+*/
+func (engine *Engine) withRetrievals(ctx context.Context, appName string) context.Context {
+	ctx = networking.WithRetryPolicy(ctx, engine.retryPolicy)
+
+	return networking.WithRetrievalObserver(ctx, &retrievalEventEmitter{
+		engine:  engine,
+		appName: appName,
+	})
+}
+
+func (emitter *retrievalEventEmitter) AttemptStarted(sourceURL *url.URL, attempt networking.RetrievalAttempt) {
+	emitter.engine.emit(&RetrievalAttempt{
+		App:     emitter.appName,
+		Host:    sourceURL.Host,
+		Attempt: attempt,
+	})
+}
+
+func (emitter *retrievalEventEmitter) RetryScheduled(sourceURL *url.URL, err error, delay time.Duration) {
+	emitter.engine.emit(&RetrievalRetrying{
+		App:   emitter.appName,
+		Host:  sourceURL.Host,
+		Err:   err,
+		Delay: delay,
+	})
+}
+
+func (emitter *retrievalEventEmitter) MirrorSwitched(failedURL *url.URL, nextURL *url.URL, err error) {
+	emitter.engine.emit(&MirrorSwitched{
+		App:        emitter.appName,
+		FailedHost: failedURL.Host,
+		NextHost:   nextURL.Host,
+		Err:        err,
+	})
+}
+
+func (emitter *retrievalEventEmitter) Throttled(sourceURL *url.URL, delay time.Duration) {
+	emitter.engine.emit(&RequestsThrottled{
+		App:   emitter.appName,
+		Host:  sourceURL.Host,
+		Delay: delay,
+	})
+}
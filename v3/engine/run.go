@@ -21,9 +21,12 @@
 package engine
 
 import (
-	"github.com/op/go-logging"
+	"context"
+	"os"
+	"time"
 
 	"github.com/giancosta86/moondeploy/v3/apps"
+	"github.com/giancosta86/moondeploy/v3/credentials"
 	"github.com/giancosta86/moondeploy/v3/descriptors"
 	"github.com/giancosta86/moondeploy/v3/launchers"
 	"github.com/giancosta86/moondeploy/v3/log"
@@ -32,7 +35,8 @@ import (
 
 /*
 ExecutionCanceled is returned when the user explicitly interrupts the execution process,
-for example refusing to install the application or closing the loading dialog.
+for example refusing to install the application or closing the loading dialog,
+as well as when the context passed to Run is canceled or its deadline expires.
 */
 type ExecutionCanceled struct{}
 
@@ -42,218 +46,122 @@ func (err *ExecutionCanceled) Error() string {
 
 /*
 Run is the entry point you must employ to create a custom installer, for example to
-employ custom settings or a brand-new user interface, based on any technology
-*/
-func Run(
-	launcher launchers.Launcher,
-	userInterface ui.UserInterface,
-	bootDescriptor descriptors.AppDescriptor) (err error) {
-
-	settings := launcher.GetSettings()
-
-	//----------------------------------------------------------------------------
-
-	setupUserInterface(launcher, userInterface)
-	defer dismissUserInterface(userInterface)
-
-	//----------------------------------------------------------------------------
-
-	userInterface.SetHeader("Performing startup operations")
-
-	log.Debug("The boot descriptor is: %#v", bootDescriptor)
-
-	//----------------------------------------------------------------------------
-
-	userInterface.SetApp(bootDescriptor.GetName())
-
-	//----------------------------------------------------------------------------
-
-	appGallery := apps.NewAppGallery(settings.GetGalleryDirectory())
-	log.Debug("The app gallery is: %#v", appGallery)
-
-	//----------------------------------------------------------------------------
-
-	log.Info("Resolving the app...")
-	app, err := appGallery.GetApp(bootDescriptor)
-	if err != nil {
-		return err
-	}
-	log.Notice("The app directory is: '%v'", app.Directory)
+employ custom settings or a brand-new user interface, based on any technology.
 
-	log.Debug("App is: %#v", app)
+It is the composition of the phases exposed by Engine - Resolve(), Update()
+and Launch() - which can be called separately for finer control.
 
-	firstRun := !app.DirectoryExists()
-	log.Debug("Is this a first run for the app? %v", firstRun)
+Its progress is published as a stream of typed events - see Subscribe() -
+while log messages are just written to the log.
 
-	//----------------------------------------------------------------------------
+Credentials for authenticated hosts are read from the sources loaded via
+credentials.Setup(), which should be called - together with gitHubUtils.Setup(),
+giteaUtils.Setup() and, optionally, descriptors.SetupActualBaseURLCache() -
+before opening the boot descriptor.
 
-	if firstRun {
-		log.Info("Now asking the user if the app can run...")
+The given context bounds the whole installation process - network retrieval
+and package extraction included - but not the launched app: once it is
+canceled, or its deadline expires, Run stops as soon as possible, removes
+any partially installed files and returns ExecutionCanceled.
 
-		canRun := app.CanPerformFirstRun(userInterface)
-		if !canRun {
-			return &ExecutionCanceled{}
-		}
-
-		log.Debug("The user agreed to proceed")
-
-		log.Info("Ensuring the app dir is available...")
-		err = app.EnsureDirectory()
-		if err != nil {
-			return err
-		}
-		log.Notice("App dir available")
-	}
-
-	//----------------------------------------------------------------------------
-
-	log.Info("Locking the app dir...")
-	err = app.LockDirectory()
-	if err != nil {
-		return err
-	}
-	defer func() {
-		unlockErr := app.UnlockDirectory()
-		if unlockErr != nil {
-			log.Warning(unlockErr.Error())
-		}
-	}()
-
-	log.Notice("App dir locked")
+If the app exits with a non-zero exit code - after the restarts allowed by its
+descriptor - Run returns apps.AppFailed, which carries the exit code.
+*/
+func Run(
+	ctx context.Context,
+	launcher launchers.Launcher,
+	userInterface ui.UserInterface,
+	bootDescriptor descriptors.AppDescriptor) (err error) {
 
-	//----------------------------------------------------------------------------
+	engine := NewEngine(launcher, userInterface, bootDescriptor)
+	defer engine.Close()
 
-	log.Info("Checking for conflicting local descriptors...")
-	err = app.CheckForConflictingLocalDescriptors()
+	err = engine.Resolve(ctx)
 	if err != nil {
 		return err
 	}
-	log.Notice("No conflicting local descriptors found")
-
-	//----------------------------------------------------------------------------
-
-	log.Info("Resolving the local descriptor...")
-	localDescriptor := app.GetLocalDescriptor()
-
-	startedWithLocalDescriptor := localDescriptor != nil
-	log.Debug("Started with local descriptor? %v", startedWithLocalDescriptor)
-
-	if startedWithLocalDescriptor {
-		log.Info("Checking that local descriptor and boot descriptor actually match...")
-		err = descriptors.CheckDescriptorMatch(localDescriptor, bootDescriptor)
-		if err != nil {
-			return err
-		}
-		log.Notice("The descriptors match correctly")
-	}
-
-	//----------------------------------------------------------------------------
-
-	log.Info("Resolving the remote descriptor...")
-	remoteDescriptor := app.GetRemoteDescriptor()
-
-	if remoteDescriptor != nil {
-		log.Info("Checking that remote descriptor and boot descriptor actually match...")
-		err = descriptors.CheckDescriptorMatch(remoteDescriptor, bootDescriptor)
-		if err != nil {
-			return err
-		}
-		log.Notice("The descriptors match correctly")
-	}
 
-	//----------------------------------------------------------------------------
-
-	log.Info("Now choosing the reference descriptor...")
-	referenceDescriptor, err := app.GetReferenceDescriptor()
+	err = engine.Update(ctx)
 	if err != nil {
 		return err
 	}
-	log.Notice("Reference descriptor chosen")
-
-	log.Debug("The reference descriptor is: %#v", referenceDescriptor)
 
-	//----------------------------------------------------------------------------
+	return engine.Launch(ctx)
+}
 
-	err = referenceDescriptor.CheckRequirements()
+/*
+removeCanceledFirstRun deletes the directory of an app whose first run
+was interrupted, so that no partial installation is left in the gallery
+*/
+func removeCanceledFirstRun(app *apps.App) {
+	log.Info("Removing the directory of the interrupted first run...")
+	err := os.RemoveAll(app.Directory)
 	if err != nil {
-		return err
+		log.Warning("Could not remove the app directory: %v", err)
+		return
 	}
+	log.Notice("App directory removed")
+}
 
-	//----------------------------------------------------------------------------
-
-	userInterface.SetApp(referenceDescriptor.GetTitle())
-
-	//----------------------------------------------------------------------------
-
-	log.Info("Resolving the OS-specific app command line...")
-	commandLine := referenceDescriptor.GetCommandLine()
-	log.Notice("Command line resolved")
-
-	log.Debug("Command line is: %v", commandLine)
-
-	//----------------------------------------------------------------------------
+/*
+emit dispatches the given event to the listeners of the engine and to
+the process-wide ones
+*/
+func (engine *Engine) emit(event Event) {
+	engine.events.emit(event)
+	globalEventBus.emit(event)
+}
 
-	err = app.CheckFiles(settings, userInterface)
-	if err != nil {
-		return err
+/*
+newDownloadProgressEmitter returns a callback emitting DownloadProgress events
+for the packages of the given app
+*/
+func (engine *Engine) newDownloadProgressEmitter(appName string) apps.PackageProgressCallback {
+	return func(packageName string, packageIndex int, packageCount int, retrievedSize int64, totalSize int64) {
+		engine.emit(&DownloadProgress{
+			App:            appName,
+			PackageName:    packageName,
+			PackageIndex:   packageIndex,
+			PackageCount:   packageCount,
+			RetrievedBytes: retrievedSize,
+			TotalBytes:     totalSize,
+		})
 	}
+}
 
-	//----------------------------------------------------------------------------
-
-	userInterface.SetHeader("Preparing the command...")
-
-	log.Info("Creating the command...")
-	command := app.PrepareCommand(commandLine)
-	log.Notice("Command created")
-
-	log.Debug("Command path: %v", command.Path)
-	log.Debug("Command arguments: %v", command.Args)
-
-	//----------------------------------------------------------------------------
-
-	referenceDescriptorSaved := app.SaveReferenceDescriptor()
-
-	if !startedWithLocalDescriptor && referenceDescriptorSaved {
-		if userInterface.AskForDesktopShortcut(referenceDescriptor) {
-			log.Info("Creating desktop shortcut...")
+/*
+lockAppDirectory locks the directory of the given app, waiting for the timeout
+declared by the settings and emitting LockWaiting if the lock is busy
+*/
+func (engine *Engine) lockAppDirectory(ctx context.Context, app *apps.App, appName string) (err error) {
+	timeout := time.Duration(engine.settings.GetLockTimeoutInSeconds()) * time.Second
+
+	return app.LockDirectory(ctx, timeout, func() {
+		engine.emit(&LockWaiting{
+			App:     appName,
+			Timeout: timeout,
+		})
+	})
+}
 
-			err = app.CreateDesktopShortcut(launcher, referenceDescriptor)
-			if err != nil {
-				log.Warning("Could not create desktop shortcut: %v", err)
-			} else {
-				log.Notice("Desktop shortcut created")
-			}
-		} else {
-			log.Info("The user refused to create a desktop shortcut")
-		}
-	}
+func (engine *Engine) setupUserInterface() (unsubscribe func()) {
+	userInterface := engine.userInterface
 
-	//----------------------------------------------------------------------------
+	userInterface.SetApp(engine.launcher.GetTitle())
 
-	app.UnlockDirectory()
+	unsubscribe = engine.Subscribe(newEventListenerFor(userInterface))
 
-	//----------------------------------------------------------------------------
+	credentials.SetPrompt(userInterface.AskForCredentials)
 
-	userInterface.SetHeader("Launching the application")
-	userInterface.SetStatus("")
+	userInterface.Show()
 
-	return app.Launch(command, settings, userInterface)
+	return unsubscribe
 }
 
-func setupUserInterface(launcher launchers.Launcher, userInterface ui.UserInterface) {
-	userInterface.SetApp(launcher.GetTitle())
-
-	log.SetCallback(func(level logging.Level, message string) {
-		if level <= logging.INFO {
-			userInterface.SetStatus(message)
-		}
-	})
-
-	userInterface.Show()
-}
+func (engine *Engine) dismissUserInterface() {
+	engine.unsubscribeUserInterface()
 
-func dismissUserInterface(userInterface ui.UserInterface) {
-	log.SetCallback(func(level logging.Level, message string) {})
+	credentials.SetPrompt(func(host string) *credentials.Credentials { return nil })
 
-	userInterface.Hide()
+	engine.userInterface.Hide()
 }
@@ -0,0 +1,171 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/giancosta86/moondeploy/v3/apps"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/networking"
+)
+
+/*
+provisionRuntimes installs - or updates - the shared runtime apps referenced
+by the runtime requirements not satisfied by the system, prepending
+their bin directories to the PATH
+*/
+func (engine *Engine) provisionRuntimes(ctx context.Context, referenceDescriptor descriptors.AppDescriptor) (err error) {
+
+	requirements := referenceDescriptor.GetRequirements()
+
+	for runtimeIndex := range requirements.Runtimes {
+		runtimeRequirement := &requirements.Runtimes[runtimeIndex]
+
+		if runtimeRequirement.Descriptor == "" {
+			continue
+		}
+
+		if runtimeRequirement.Check() == nil {
+			log.Notice("Runtime %v is available on the system", runtimeRequirement.GetName())
+			continue
+		}
+
+		engine.emit(&StageStarted{
+			Stage:       RuntimeInstallationStage,
+			Description: fmt.Sprintf("Installing runtime: %v", runtimeRequirement.GetName()),
+		})
+
+		runtimeApp, err := engine.installRuntimeApp(ctx, runtimeRequirement)
+
+		engine.emit(&StageFinished{
+			Stage: RuntimeInstallationStage,
+			Err:   err,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		binDirectory := filepath.Join(
+			runtimeApp.GetFilesDirectory(),
+			filepath.FromSlash(runtimeRequirement.BinDirectory))
+
+		log.Notice("Prepending the runtime's bin directory to the PATH: '%v'", binDirectory)
+		err = os.Setenv("PATH", binDirectory+string(os.PathListSeparator)+os.Getenv("PATH"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (engine *Engine) installRuntimeApp(
+	ctx context.Context,
+	runtimeRequirement *descriptors.RuntimeRequirement) (runtimeApp *apps.App, err error) {
+
+	settings := engine.settings
+	ctx = engine.withRetrievals(ctx, runtimeRequirement.GetName())
+
+	runtimeDescriptorURL, err := url.Parse(runtimeRequirement.Descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Retrieving the descriptor of runtime %v...", runtimeRequirement.GetName())
+	var runtimeDescriptorBytes []byte
+	err = networking.RetrieveFromMirrors(ctx, []*url.URL{runtimeDescriptorURL}, func(sourceURL *url.URL, attempt networking.RetrievalAttempt) (err error) {
+		runtimeDescriptorBytes, err = networking.RetrieveFromURL(ctx, sourceURL)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	runtimeBootDescriptor, err := descriptors.NewAppDescriptorFromBytes(ctx, runtimeDescriptorBytes)
+	if err != nil {
+		return nil, err
+	}
+	log.Notice("Runtime descriptor ready")
+
+	runtimeApp, err = engine.appGallery.GetApp(runtimeBootDescriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	if !runtimeApp.DirectoryExists() {
+		log.Info("Now asking the user if the runtime can be installed...")
+		if !runtimeApp.CanPerformFirstRun(engine.userInterface) {
+			return nil, &ExecutionCanceled{}
+		}
+
+		err = runtimeApp.EnsureDirectory()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = engine.lockAppDirectory(ctx, runtimeApp, runtimeRequirement.GetName())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		unlockErr := runtimeApp.UnlockDirectory()
+		if unlockErr != nil {
+			log.Warning(unlockErr.Error())
+		}
+	}()
+
+	err = runtimeApp.CheckForConflictingLocalDescriptors()
+	if err != nil {
+		return nil, err
+	}
+
+	localDescriptor := runtimeApp.GetLocalDescriptor(ctx)
+	if localDescriptor != nil {
+		err = descriptors.CheckDescriptorMatch(localDescriptor, runtimeBootDescriptor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = runtimeApp.GetReferenceDescriptor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = runtimeApp.CheckFiles(ctx, settings, engine.newDownloadProgressEmitter(runtimeRequirement.GetName()))
+	if err != nil {
+		return nil, err
+	}
+
+	runtimeApp.SaveReferenceDescriptor(ctx)
+
+	log.Notice("Runtime %v installed", runtimeRequirement.GetName())
+
+	return runtimeApp, nil
+}
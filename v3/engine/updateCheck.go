@@ -0,0 +1,54 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package engine
+
+import (
+	"github.com/giancosta86/moondeploy/v3/apps"
+	"github.com/giancosta86/moondeploy/v3/versioning"
+)
+
+/*
+UpdateCheck is the outcome of Engine.CheckForUpdates(): InstalledVersion is nil
+if the app is not installed, while AvailableVersion is nil if the remote
+descriptor must not be checked, as requested by the local descriptor
+*/
+type UpdateCheck struct {
+	App              string
+	InstalledVersion *versioning.Version
+	AvailableVersion *versioning.Version
+	PackageChanges   []apps.PackageChange
+}
+
+/*
+IsUpdateAvailable returns true if the remote descriptor is newer than
+the local one - or if the app is not installed yet
+*/
+func (updateCheck *UpdateCheck) IsUpdateAvailable() bool {
+	if updateCheck.AvailableVersion == nil {
+		return false
+	}
+
+	if updateCheck.InstalledVersion == nil {
+		return true
+	}
+
+	return updateCheck.AvailableVersion.NewerThan(updateCheck.InstalledVersion)
+}
@@ -0,0 +1,51 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package engine
+
+import (
+	"github.com/giancosta86/moondeploy/v3/versioning"
+)
+
+/*
+UpdateOutcome describes what Engine.Update() has actually done: PreviousVersion
+is nil after a first installation, StagedVersion is not nil if the update
+has been staged because the app is running, and RemoteDescriptorErr
+is not nil if the remote descriptor could not be retrieved - so that
+the installed version has been kept
+*/
+type UpdateOutcome struct {
+	App                 string
+	PreviousVersion     *versioning.Version
+	CurrentVersion      *versioning.Version
+	StagedVersion       *versioning.Version
+	RemoteDescriptorErr error
+}
+
+/*
+IsUpdated returns true if a different version has been installed
+*/
+func (outcome *UpdateOutcome) IsUpdated() bool {
+	if outcome.PreviousVersion == nil {
+		return true
+	}
+
+	return outcome.CurrentVersion.String() != outcome.PreviousVersion.String()
+}
@@ -0,0 +1,80 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package engine
+
+import (
+	"fmt"
+
+	"github.com/giancosta86/moondeploy/v3/ui"
+)
+
+/*
+userInterfaceAdapter maps the events to the basic methods of a user interface
+not implementing EventListener
+*/
+type userInterfaceAdapter struct {
+	userInterface ui.UserInterface
+
+	currentPackage string
+}
+
+func newEventListenerFor(userInterface ui.UserInterface) EventListener {
+	listener, isListener := userInterface.(EventListener)
+	if isListener {
+		return listener
+	}
+
+	return &userInterfaceAdapter{
+		userInterface: userInterface,
+	}
+}
+
+func (adapter *userInterfaceAdapter) HandleEvent(event Event) {
+	userInterface := adapter.userInterface
+
+	switch actualEvent := event.(type) {
+	case *StageStarted:
+		adapter.currentPackage = ""
+		userInterface.SetHeader(actualEvent.Description)
+		userInterface.SetStatus("")
+
+	case *DescriptorChosen, *UpdateAvailable, *LockWaiting, *RemoteDescriptorUnavailable,
+		*RetrievalAttempt, *RetrievalRetrying, *MirrorSwitched, *RequestsThrottled:
+		userInterface.SetStatus(event.String())
+
+	case *DownloadProgress:
+		if actualEvent.PackageName != adapter.currentPackage {
+			adapter.currentPackage = actualEvent.PackageName
+
+			userInterface.SetHeader(
+				fmt.Sprintf("Updating package %v of %v: %v",
+					actualEvent.PackageIndex,
+					actualEvent.PackageCount,
+					actualEvent.PackageName))
+		}
+
+		userInterface.SetStatus(event.String())
+
+		if actualEvent.TotalBytes > 0 {
+			userInterface.SetProgress(float64(actualEvent.RetrievedBytes) / float64(actualEvent.TotalBytes))
+		}
+	}
+}
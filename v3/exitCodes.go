@@ -23,3 +23,40 @@ package v3
 const ExitCodeSuccess = 0
 const ExitCodeError = 1
 const ExitCodeCanceled = 2
+
+/*
+ExitCodeAppTerminated is returned when the app has been terminated by a signal,
+so that it has no exit code of its own to propagate
+*/
+const ExitCodeAppTerminated = 3
+
+/*
+ExitCodeAppFailureBase starts the range of exit codes reserved to the failures
+of the app, so that they cannot be mistaken for MoonDeploy's own exit codes:
+the app's exit code N is returned as ExitCodeAppFailureBase + N
+*/
+const ExitCodeAppFailureBase = 64
+
+/*
+ExitCodeAppFailureOverflow is returned when the app's exit code is too high
+to be mapped into the reserved range
+*/
+const ExitCodeAppFailureOverflow = 255
+
+/*
+GetAppFailureExitCode maps the non-zero exit code of the app into the range
+reserved to app failures; a negative exit code - meaning that the app was
+terminated by a signal - is mapped to ExitCodeAppTerminated
+*/
+func GetAppFailureExitCode(appExitCode int) int {
+	if appExitCode < 0 {
+		return ExitCodeAppTerminated
+	}
+
+	exitCode := ExitCodeAppFailureBase + appExitCode
+	if exitCode >= ExitCodeAppFailureOverflow {
+		return ExitCodeAppFailureOverflow
+	}
+
+	return exitCode
+}
@@ -21,26 +21,37 @@
 package gitHubUtils
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 	"regexp"
-
-	"github.com/giancosta86/caravel"
+	"strings"
 
 	"github.com/giancosta86/moondeploy/v3/log"
 	"github.com/giancosta86/moondeploy/v3/versioning"
 )
 
-var latestVersionURLRegex = regexp.MustCompile(`^https://github\.com/([^/]+)/([^/]+)/releases/latest/?$`)
+var latestVersionURLRegex = regexp.MustCompile(`^https://([^/]+)/([^/]+)/([^/]+)/releases/latest/?$`)
+
+var apiLatestVersionURLTemplate = "%v/repos/%v/%v/releases/latest"
 
-var apiLatestVersionURLTemplate = "https://api.github.com/repos/%v/%v/releases/latest"
+var apiReleasesURLTemplate = "%v/repos/%v/%v/releases?per_page=100"
 
-var tagRegex = regexp.MustCompile(`^\D*(\d.*)$`)
+var nextPageLinkRegex = regexp.MustCompile(`^\s*<([^>]+)>.*;\s*rel="next"`)
 
-type latestVersionResponse struct {
-	TagName string        `json:"tag_name"`
-	Assets  []assetStruct `json:"assets"`
+/*
+maxReleasePages bounds the number of pages of releases retrieved
+when applying a release selection
+*/
+const maxReleasePages = 10
+
+type releaseResponse struct {
+	TagName    string        `json:"tag_name"`
+	Draft      bool          `json:"draft"`
+	PreRelease bool          `json:"prerelease"`
+	Assets     []assetStruct `json:"assets"`
 }
 
 type assetStruct struct {
@@ -53,77 +64,214 @@ type GitHubDescriptorInfo struct {
 	Version       *versioning.Version
 }
 
-func GetGitHubDescriptorInfo(baseURL *url.URL, descriptorFileName string) *GitHubDescriptorInfo {
+/*
+GetGitHubDescriptorInfo returns nil, without errors, if the base URL does not
+reference the 'latest' release of a repository on GitHub - or on one of the
+Enterprise hosts; otherwise, it queries the API for the release
+- chosen via the given selection, that can be nil - and returns
+the URL of its descriptor, as well as its version
+*/
+func GetGitHubDescriptorInfo(ctx context.Context, baseURL *url.URL, descriptorFileName string, selection *ReleaseSelection) (*GitHubDescriptorInfo, error) {
 	projectParams := latestVersionURLRegex.FindStringSubmatch(baseURL.String())
-	if projectParams == nil {
+	if projectParams == nil || !IsGitHubHost(projectParams[1]) {
 		log.Debug("The URL does not reference a 'latest' release on GitHub")
-		return nil
+		return nil, nil
 	}
 	log.Debug("The URL references a 'latest' release on GitHub")
 
-	gitHubUser := projectParams[1]
-	gitHubRepo := projectParams[2]
+	apiRootURL := getAPIRootURL(projectParams[1])
+	gitHubUser := projectParams[2]
+	gitHubRepo := projectParams[3]
+
+	var release *releaseResponse
+	var err error
+
+	if selection.isDefault() {
+		release, err = getLatestRelease(ctx, apiRootURL, gitHubUser, gitHubRepo)
+	} else {
+		release, err = getSelectedRelease(ctx, apiRootURL, gitHubUser, gitHubRepo, descriptorFileName, selection)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Now processing the release fields...")
+
+	result := &GitHubDescriptorInfo{}
+
+	for _, asset := range release.Assets {
+		if asset.Name == descriptorFileName {
+			result.DescriptorURL, err = url.Parse(asset.BrowserDownloadURL)
+			if err != nil {
+				return nil, fmt.Errorf("Error while parsing the BrowserDownloadURL: %v", err.Error())
+			}
+			break
+		}
+	}
+
+	if result.DescriptorURL == nil {
+		return nil, fmt.Errorf("The app descriptor ('%v') could not be found as an asset of release '%v' on GitHub",
+			descriptorFileName,
+			release.TagName)
+	}
+
+	result.Version, err = versioning.ParseTagVersion(release.TagName)
+	if err != nil {
+		return nil, fmt.Errorf("Error while parsing the version of release '%v': %v", release.TagName, err.Error())
+	}
+
+	log.Notice("Release fields correctly processed")
+
+	return result, nil
+}
 
+func getLatestRelease(ctx context.Context, apiRootURL string, gitHubUser string, gitHubRepo string) (release *releaseResponse, err error) {
 	apiLatestVersionURL, err := url.Parse(fmt.Sprintf(
 		apiLatestVersionURLTemplate,
+		apiRootURL,
 		gitHubUser,
 		gitHubRepo))
 	if err != nil {
-		log.Warning(err.Error())
-		return nil
+		return nil, err
 	}
 
-	log.Debug("Calling GitHub's API, at '%v'...", apiLatestVersionURL)
-
-	apiResponseBytes, err := caravel.RetrieveFromURL(apiLatestVersionURL)
+	apiResponseBytes, _, err := callAPI(ctx, apiLatestVersionURL)
 	if err != nil {
-		log.Warning(err.Error())
-		return nil
+		return nil, err
 	}
-	log.Debug("API returned OK")
 
 	log.Debug("Deserializing the API response...")
-	var latestVersionResponse latestVersionResponse
-	err = json.Unmarshal(apiResponseBytes, &latestVersionResponse)
+	release = &releaseResponse{}
+	err = json.Unmarshal(apiResponseBytes, release)
 	if err != nil {
-		log.Warning(err.Error())
-		return nil
+		return nil, err
 	}
-	log.Debug("Response correctly deserialized: %#v", latestVersionResponse)
+	log.Debug("Response correctly deserialized: %#v", release)
 
-	log.Debug("Now processing the response fields...")
+	return release, nil
+}
 
-	result := &GitHubDescriptorInfo{}
+/*
+getSelectedRelease returns the release having the highest version among
+the ones accepted by the selection and providing the descriptor
+*/
+func getSelectedRelease(
+	ctx context.Context,
+	apiRootURL string,
+	gitHubUser string,
+	gitHubRepo string,
+	descriptorFileName string,
+	selection *ReleaseSelection) (release *releaseResponse, err error) {
 
-	for _, asset := range latestVersionResponse.Assets {
-		if asset.Name == descriptorFileName {
-			result.DescriptorURL, err = url.Parse(asset.BrowserDownloadURL)
-			if err != nil {
-				log.Warning("Error while parsing the BrowserDownloadURL: %v", err.Error())
-				return nil
-			}
-			break
+	apiReleasesURL, err := url.Parse(fmt.Sprintf(
+		apiReleasesURLTemplate,
+		apiRootURL,
+		gitHubUser,
+		gitHubRepo))
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := getAllReleases(ctx, apiReleasesURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var selectedVersion *versioning.Version
+
+	for releaseIndex := range releases {
+		candidateRelease := &releases[releaseIndex]
+
+		if !selection.accepts(candidateRelease) || !candidateRelease.hasAsset(descriptorFileName) {
+			continue
+		}
+
+		candidateVersion, err := versioning.ParseTagVersion(candidateRelease.TagName)
+		if err != nil {
+			log.Debug("Skipping release '%v': %v", candidateRelease.TagName, err)
+			continue
+		}
+
+		if selectedVersion == nil || candidateVersion.NewerThan(selectedVersion) {
+			release = candidateRelease
+			selectedVersion = candidateVersion
 		}
 	}
 
-	if result.DescriptorURL == nil {
-		log.Warning("The app descriptor ('%v') could not be found as an asset of the latest release", descriptorFileName)
-		return nil
+	if release == nil {
+		return nil, fmt.Errorf("No release of %v/%v on GitHub matches the release selection %v",
+			gitHubUser,
+			gitHubRepo,
+			selection)
 	}
 
-	tagComponents := tagRegex.FindStringSubmatch(latestVersionResponse.TagName)
-	if tagComponents == nil {
-		log.Warning("GitHub's release tag must be in the format: <any string, even empty><VERSION>, not '%v'", latestVersionResponse.TagName)
-		return nil
+	log.Notice("Release '%v' selected", release.TagName)
+
+	return release, nil
+}
+
+func (release *releaseResponse) hasAsset(assetName string) bool {
+	for _, asset := range release.Assets {
+		if asset.Name == assetName {
+			return true
+		}
 	}
 
-	result.Version, err = versioning.ParseVersion(tagComponents[1])
-	if err != nil {
-		log.Warning("Error while parsing the version: %v", err.Error())
-		return nil
+	return false
+}
+
+/*
+getAllReleases retrieves the releases from the given API URL, following the
+pagination links - up to maxReleasePages pages
+*/
+func getAllReleases(ctx context.Context, apiReleasesURL *url.URL) (releases []releaseResponse, err error) {
+	releases = []releaseResponse{}
+
+	pageURL := apiReleasesURL
+
+	for page := 1; pageURL != nil; page++ {
+		if page > maxReleasePages {
+			log.Warning("Only the first %v pages of releases will be considered", maxReleasePages)
+			break
+		}
+
+		apiResponseBytes, apiResponseHeader, err := callAPI(ctx, pageURL)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Debug("Deserializing the API response...")
+		var pageReleases []releaseResponse
+		err = json.Unmarshal(apiResponseBytes, &pageReleases)
+		if err != nil {
+			return nil, err
+		}
+		log.Debug("Response correctly deserialized: %v release(s) found in page %v", len(pageReleases), page)
+
+		releases = append(releases, pageReleases...)
+
+		pageURL, err = getNextPageURL(apiResponseHeader)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	log.Notice("Response fields correctly processed")
+	return releases, nil
+}
+
+/*
+getNextPageURL returns the URL of the next page declared by the Link header
+of an API response - or nil if the current page is the last one
+*/
+func getNextPageURL(apiResponseHeader http.Header) (*url.URL, error) {
+	for _, linkHeader := range apiResponseHeader["Link"] {
+		for _, link := range strings.Split(linkHeader, ",") {
+			linkParams := nextPageLinkRegex.FindStringSubmatch(link)
+			if linkParams != nil {
+				return url.Parse(linkParams[1])
+			}
+		}
+	}
 
-	return result
+	return nil, nil
 }
@@ -0,0 +1,142 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package gitHubUtils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/giancosta86/moondeploy/v3/credentials"
+	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/networking"
+)
+
+/*
+Beyond this delay, a Retry-After header is not honoured: the rate limit
+is reported to the user instead
+*/
+const maxRetryAfterDelay = 60 * time.Second
+
+const maxRateLimitedAttempts = 3
+
+/*
+RateLimitExceeded is returned when GitHub's API refuses a call because
+its rate limit has been exceeded; ResetTime is zero if the API did not declare it
+*/
+type RateLimitExceeded struct {
+	Host          string
+	ResetTime     time.Time
+	Authenticated bool
+}
+
+func (err *RateLimitExceeded) Error() string {
+	message := fmt.Sprintf("The rate limit of GitHub's API on %v has been exceeded", err.Host)
+
+	if !err.ResetTime.IsZero() {
+		message += fmt.Sprintf(" until %v", err.ResetTime.Local().Format("15:04"))
+	}
+
+	if err.Authenticated {
+		return message + ". Please, try again later."
+	}
+
+	return message + fmt.Sprintf(". Please, try again later - or set a GitHub token via the GitHubToken setting or the %v environment variable, to raise the limit.",
+		tokenEnvironmentVariable)
+}
+
+/*
+callAPI calls GitHub's API, waiting and retrying when the API declares
+a short Retry-After delay; it also returns the header of the response
+*/
+func callAPI(ctx context.Context, apiURL *url.URL) (apiResponseBytes []byte, apiResponseHeader http.Header, err error) {
+	apiCredentials := getAPICredentials(apiURL.Host)
+
+	log.Debug("Calling GitHub's API, at '%v'...", apiURL.Redacted())
+
+	for attempt := 1; ; attempt++ {
+		apiResponseBytes, apiResponseHeader, err = networking.RetrieveFromURLWithHeader(ctx, apiURL, apiCredentials)
+		if err == nil {
+			log.Debug("API returned OK")
+			return apiResponseBytes, apiResponseHeader, nil
+		}
+
+		httpErr, isHTTPErr := err.(*networking.HTTPError)
+		if !isHTTPErr || !isRateLimitResponse(httpErr) {
+			return nil, nil, err
+		}
+
+		retryAfter := getRetryAfter(httpErr)
+
+		if retryAfter <= 0 || retryAfter > maxRetryAfterDelay || attempt == maxRateLimitedAttempts {
+			return nil, nil, newRateLimitExceeded(apiURL, httpErr, apiCredentials)
+		}
+
+		log.Warning("GitHub's API is throttling the requests: retrying in %v...", retryAfter)
+		networking.GetRetrievalObserver(ctx).Throttled(apiURL, retryAfter)
+		err = networking.SleepWithContext(ctx, retryAfter)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+func isRateLimitResponse(httpErr *networking.HTTPError) bool {
+	switch httpErr.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+
+	case http.StatusForbidden:
+		return httpErr.Header.Get("X-RateLimit-Remaining") == "0" ||
+			httpErr.Header.Get("Retry-After") != ""
+
+	default:
+		return false
+	}
+}
+
+func getRetryAfter(httpErr *networking.HTTPError) time.Duration {
+	retryAfterSeconds, err := strconv.Atoi(httpErr.Header.Get("Retry-After"))
+	if err != nil || retryAfterSeconds < 0 {
+		return 0
+	}
+
+	return time.Duration(retryAfterSeconds) * time.Second
+}
+
+func newRateLimitExceeded(apiURL *url.URL, httpErr *networking.HTTPError, apiCredentials *credentials.Credentials) *RateLimitExceeded {
+	result := &RateLimitExceeded{
+		Host:          apiURL.Host,
+		Authenticated: !apiCredentials.IsEmpty(),
+	}
+
+	resetSeconds, err := strconv.ParseInt(httpErr.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err == nil && resetSeconds > 0 {
+		result.ResetTime = time.Unix(resetSeconds, 0)
+	} else if retryAfter := getRetryAfter(httpErr); retryAfter > 0 {
+		result.ResetTime = time.Now().Add(retryAfter)
+	}
+
+	return result
+}
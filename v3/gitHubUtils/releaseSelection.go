@@ -0,0 +1,76 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package gitHubUtils
+
+import (
+	"fmt"
+	"regexp"
+)
+
+/*
+ReleaseSelection replaces the 'latest' release of a repository with
+the release having the highest version among the ones whose tag matches
+TagPattern - a regular expression - or among all of them, if TagPattern
+is empty. Pre-releases are skipped unless IncludePreReleases is true,
+whereas drafts are always skipped.
+*/
+type ReleaseSelection struct {
+	TagPattern         string `json:",omitempty"`
+	IncludePreReleases bool   `json:",omitempty"`
+
+	tagRegex *regexp.Regexp
+}
+
+func (selection *ReleaseSelection) Init() (err error) {
+	if selection.TagPattern == "" {
+		return nil
+	}
+
+	selection.tagRegex, err = regexp.Compile(selection.TagPattern)
+	if err != nil {
+		return fmt.Errorf("Invalid tag pattern: %v", err)
+	}
+
+	return nil
+}
+
+func (selection *ReleaseSelection) isDefault() bool {
+	return selection == nil ||
+		(selection.TagPattern == "" && !selection.IncludePreReleases)
+}
+
+func (selection *ReleaseSelection) accepts(release *releaseResponse) bool {
+	if release.Draft {
+		return false
+	}
+
+	if release.PreRelease && !selection.IncludePreReleases {
+		return false
+	}
+
+	return selection.tagRegex == nil || selection.tagRegex.MatchString(release.TagName)
+}
+
+func (selection *ReleaseSelection) String() string {
+	return fmt.Sprintf("(tag pattern: '%v', pre-releases included: %v)",
+		selection.TagPattern,
+		selection.IncludePreReleases)
+}
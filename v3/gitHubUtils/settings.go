@@ -0,0 +1,117 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package gitHubUtils
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/giancosta86/moondeploy/v3/credentials"
+)
+
+const publicHost = "github.com"
+const publicAPIHost = "api.github.com"
+
+const tokenEnvironmentVariable = "GITHUB_TOKEN"
+
+/*
+Settings configure the access to GitHub's API.
+
+Token, if not empty, is sent to the API of every GitHub host lacking specific
+credentials - which can still be provided via the credentials package;
+when empty, the GITHUB_TOKEN environment variable is used instead.
+
+EnterpriseHosts are the hosts of GitHub Enterprise Server instances,
+whose API is available at https://<host>/api/v3/
+*/
+type Settings struct {
+	Token           string
+	EnterpriseHosts []string
+}
+
+var settings Settings
+
+var settingsMutex sync.Mutex
+
+func Setup(gitHubSettings Settings) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	settings = gitHubSettings
+}
+
+func getSettings() Settings {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	return settings
+}
+
+/*
+IsGitHubHost returns true if the given host is github.com or one of
+the configured Enterprise hosts
+*/
+func IsGitHubHost(host string) bool {
+	if strings.EqualFold(host, publicHost) {
+		return true
+	}
+
+	for _, enterpriseHost := range getSettings().EnterpriseHosts {
+		if strings.EqualFold(host, enterpriseHost) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getAPIRootURL(host string) string {
+	if strings.EqualFold(host, publicHost) {
+		return "https://" + publicAPIHost
+	}
+
+	return "https://" + host + "/api/v3"
+}
+
+/*
+getAPICredentials prefers the credentials specifically available for
+the API host; otherwise, it resorts to the token, if any
+*/
+func getAPICredentials(apiHost string) *credentials.Credentials {
+	hostCredentials := credentials.Lookup(apiHost)
+	if hostCredentials != nil {
+		return hostCredentials
+	}
+
+	token := getSettings().Token
+	if token == "" {
+		token = os.Getenv(tokenEnvironmentVariable)
+	}
+
+	if token == "" {
+		return nil
+	}
+
+	return &credentials.Credentials{
+		Token: token,
+	}
+}
@@ -0,0 +1,136 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package gitLabUtils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/networking"
+	"github.com/giancosta86/moondeploy/v3/versioning"
+)
+
+/*
+The permalink to the latest release is supported by any GitLab instance -
+gitlab.com or self-hosted - and the project can be nested in subgroups
+*/
+var latestVersionURLRegex = regexp.MustCompile(`^(https?://[^/]+)/(.+?)/-/releases/permalink/latest/?$`)
+
+var apiLatestVersionURLTemplate = "%v/api/v4/projects/%v/releases/permalink/latest"
+
+type latestVersionResponse struct {
+	TagName string       `json:"tag_name"`
+	Assets  assetsStruct `json:"assets"`
+}
+
+type assetsStruct struct {
+	Links []linkStruct `json:"links"`
+}
+
+type linkStruct struct {
+	Name           string `json:"name"`
+	URL            string `json:"url"`
+	DirectAssetURL string `json:"direct_asset_url"`
+}
+
+type GitLabDescriptorInfo struct {
+	DescriptorURL *url.URL
+	Version       *versioning.Version
+}
+
+/*
+GetGitLabDescriptorInfo returns nil if the given base URL does not reference
+the permalink to the 'latest' release of a GitLab project; otherwise, it queries the API for the release
+and returns the URL of its descriptor, as well as its version
+*/
+func GetGitLabDescriptorInfo(ctx context.Context, baseURL *url.URL, descriptorFileName string) (*GitLabDescriptorInfo, error) {
+	projectParams := latestVersionURLRegex.FindStringSubmatch(baseURL.String())
+	if projectParams == nil {
+		log.Debug("The URL does not reference a 'latest' release on GitLab")
+		return nil, nil
+	}
+	log.Debug("The URL references a 'latest' release on GitLab")
+
+	gitLabServer := projectParams[1]
+	projectPath := projectParams[2]
+
+	apiLatestVersionURL, err := url.Parse(fmt.Sprintf(
+		apiLatestVersionURLTemplate,
+		gitLabServer,
+		url.PathEscape(projectPath)))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Calling GitLab's API, at '%v'...", apiLatestVersionURL.Redacted())
+
+	apiResponseBytes, err := networking.RetrieveFromURL(ctx, apiLatestVersionURL)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("API returned OK")
+
+	log.Debug("Deserializing the API response...")
+	var latestVersionResponse latestVersionResponse
+	err = json.Unmarshal(apiResponseBytes, &latestVersionResponse)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("Response correctly deserialized: %#v", latestVersionResponse)
+
+	log.Debug("Now processing the response fields...")
+
+	result := &GitLabDescriptorInfo{}
+
+	for _, link := range latestVersionResponse.Assets.Links {
+		if link.Name == descriptorFileName {
+			linkURL := link.DirectAssetURL
+			if linkURL == "" {
+				linkURL = link.URL
+			}
+
+			result.DescriptorURL, err = url.Parse(linkURL)
+			if err != nil {
+				return nil, fmt.Errorf("Error while parsing the asset link URL: %v", err.Error())
+			}
+			break
+		}
+	}
+
+	if result.DescriptorURL == nil {
+		return nil, fmt.Errorf("The app descriptor ('%v') could not be found among the asset links of release '%v' on GitLab",
+			descriptorFileName,
+			latestVersionResponse.TagName)
+	}
+
+	result.Version, err = versioning.ParseTagVersion(latestVersionResponse.TagName)
+	if err != nil {
+		return nil, fmt.Errorf("Error while parsing the version of release '%v': %v", latestVersionResponse.TagName, err.Error())
+	}
+
+	log.Notice("Response fields correctly processed")
+
+	return result, nil
+}
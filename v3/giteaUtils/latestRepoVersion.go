@@ -0,0 +1,132 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package giteaUtils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/giancosta86/moondeploy/v3/log"
+	"github.com/giancosta86/moondeploy/v3/networking"
+	"github.com/giancosta86/moondeploy/v3/versioning"
+)
+
+/*
+The greedy server group supports Gitea instances installed in a sub-path
+*/
+var latestVersionURLRegex = regexp.MustCompile(`^(https?://.+)/([^/]+)/([^/]+)/releases/latest/?$`)
+
+var apiLatestVersionURLTemplate = "%v/api/v1/repos/%v/%v/releases/latest"
+
+type latestVersionResponse struct {
+	TagName string        `json:"tag_name"`
+	Assets  []assetStruct `json:"assets"`
+}
+
+type assetStruct struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+type GiteaDescriptorInfo struct {
+	DescriptorURL *url.URL
+	Version       *versioning.Version
+}
+
+/*
+GetGiteaDescriptorInfo returns nil if the given base URL does not reference
+the 'latest' release of a repository on one of the configured Gitea hosts; otherwise, it queries the API for the release
+and returns the URL of its descriptor, as well as its version
+*/
+func GetGiteaDescriptorInfo(ctx context.Context, baseURL *url.URL, descriptorFileName string) (*GiteaDescriptorInfo, error) {
+	if !IsGiteaHost(baseURL.Host) {
+		log.Debug("The URL does not reference a configured Gitea host")
+		return nil, nil
+	}
+
+	projectParams := latestVersionURLRegex.FindStringSubmatch(baseURL.String())
+	if projectParams == nil {
+		log.Debug("The URL does not reference a 'latest' release on Gitea")
+		return nil, nil
+	}
+	log.Debug("The URL references a 'latest' release on Gitea")
+
+	giteaServer := projectParams[1]
+	giteaOwner := projectParams[2]
+	giteaRepo := projectParams[3]
+
+	apiLatestVersionURL, err := url.Parse(fmt.Sprintf(
+		apiLatestVersionURLTemplate,
+		giteaServer,
+		giteaOwner,
+		giteaRepo))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Calling Gitea's API, at '%v'...", apiLatestVersionURL.Redacted())
+
+	apiResponseBytes, err := networking.RetrieveFromURL(ctx, apiLatestVersionURL)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("API returned OK")
+
+	log.Debug("Deserializing the API response...")
+	var latestVersionResponse latestVersionResponse
+	err = json.Unmarshal(apiResponseBytes, &latestVersionResponse)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("Response correctly deserialized: %#v", latestVersionResponse)
+
+	log.Debug("Now processing the response fields...")
+
+	result := &GiteaDescriptorInfo{}
+
+	for _, asset := range latestVersionResponse.Assets {
+		if asset.Name == descriptorFileName {
+			result.DescriptorURL, err = url.Parse(asset.BrowserDownloadURL)
+			if err != nil {
+				return nil, fmt.Errorf("Error while parsing the BrowserDownloadURL: %v", err.Error())
+			}
+			break
+		}
+	}
+
+	if result.DescriptorURL == nil {
+		return nil, fmt.Errorf("The app descriptor ('%v') could not be found as an asset of release '%v' on Gitea",
+			descriptorFileName,
+			latestVersionResponse.TagName)
+	}
+
+	result.Version, err = versioning.ParseTagVersion(latestVersionResponse.TagName)
+	if err != nil {
+		return nil, fmt.Errorf("Error while parsing the version of release '%v': %v", latestVersionResponse.TagName, err.Error())
+	}
+
+	log.Notice("Response fields correctly processed")
+
+	return result, nil
+}
@@ -0,0 +1,65 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package giteaUtils
+
+import (
+	"strings"
+	"sync"
+)
+
+/*
+Settings configure the Gitea instances: as any host could run Gitea, 'latest'
+release URLs are only resolved via Gitea's API on the given Hosts
+*/
+type Settings struct {
+	Hosts []string
+}
+
+var settings Settings
+
+var settingsMutex sync.Mutex
+
+func Setup(giteaSettings Settings) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	settings = giteaSettings
+}
+
+func getSettings() Settings {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	return settings
+}
+
+/*
+IsGiteaHost returns true if the given host is one of the configured Gitea hosts
+*/
+func IsGiteaHost(host string) bool {
+	for _, giteaHost := range getSettings().Hosts {
+		if strings.EqualFold(host, giteaHost) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,35 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package networking
+
+import "syscall"
+
+const wsaeConnRefused syscall.Errno = 10061
+
+/*
+transientConnectionErrors are the Winsock errors - such as a refused or
+reset connection - that might disappear when retrying
+*/
+var transientConnectionErrors = []syscall.Errno{
+	wsaeConnRefused,
+	syscall.WSAECONNRESET,
+	syscall.WSAECONNABORTED,
+}
@@ -0,0 +1,63 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package networking
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/giancosta86/moondeploy/v3/credentials"
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+/*
+ResolveRedirects sends a HEAD request to the given URL - with the credentials
+available for its host, if it accepts them - following every redirect, and returns the URL
+finally reached; it matches the given URL if the server does not redirect
+*/
+func ResolveRedirects(ctx context.Context, sourceURL *url.URL) (finalURL *url.URL, err error) {
+	requestURL, requestCredentials := splitUserInfo(sourceURL)
+	if requestCredentials == nil && credentials.AcceptsCredentials(requestURL) {
+		requestCredentials = credentials.Lookup(requestURL.Host)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "HEAD", requestURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	requestCredentials.Apply(request)
+
+	log.Debug("Sending HEAD request to '%v' - credentials: %v", requestURL.Redacted(), requestCredentials)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, newHTTPError(requestURL, response)
+	}
+
+	return response.Request.URL, nil
+}
@@ -0,0 +1,247 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package networking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+const maxRetryDelay = 30 * time.Second
+
+/*
+RetryPolicy describes how many times a retrieval is attempted on each source
+and how long to wait before the first retry; the delay doubles after
+every further attempt
+*/
+type RetryPolicy struct {
+	Attempts     int
+	InitialDelay time.Duration
+}
+
+/*
+DefaultRetryPolicy is used by the retrievals whose context has no retry policy:
+just one attempt per source
+*/
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:     1,
+	InitialDelay: 0,
+}
+
+type retryPolicyKey struct{}
+
+/*
+WithRetryPolicy returns a copy of the given context, causing the retrievals
+performed with it to follow the given policy
+*/
+func WithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	if policy.Attempts < 1 {
+		policy.Attempts = 1
+	}
+
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+/*
+GetRetryPolicy returns the policy attached to the given context via
+WithRetryPolicy() - or DefaultRetryPolicy
+*/
+func GetRetryPolicy(ctx context.Context) RetryPolicy {
+	policy, hasPolicy := ctx.Value(retryPolicyKey{}).(RetryPolicy)
+	if !hasPolicy {
+		return DefaultRetryPolicy
+	}
+
+	return policy
+}
+
+/*
+RetrievalAttempt describes the mirror and the attempt currently in use
+*/
+type RetrievalAttempt struct {
+	Mirror   int
+	Mirrors  int
+	Attempt  int
+	Attempts int
+}
+
+func (attempt RetrievalAttempt) String() string {
+	return fmt.Sprintf("mirror %v of %v, attempt %v of %v",
+		attempt.Mirror,
+		attempt.Mirrors,
+		attempt.Attempt,
+		attempt.Attempts)
+}
+
+/*
+Retrieval performs a single retrieval attempt from the given source URL
+*/
+type Retrieval func(sourceURL *url.URL, attempt RetrievalAttempt) (err error)
+
+/*
+RetrieveFromMirrors tries the given source URLs in order, performing on each
+of them up to the number of attempts declared by the retry policy of the context,
+with exponential backoff. Errors that cannot be solved by retrying the same
+source (for example, a 404) make it switch to the next source immediately.
+The error of the very last attempt is returned if every source fails,
+whereas the context's error is returned as soon as the context is done.
+*/
+func RetrieveFromMirrors(ctx context.Context, sourceURLs []*url.URL, retrieval Retrieval) (err error) {
+	if len(sourceURLs) == 0 {
+		return fmt.Errorf("No source URL available")
+	}
+
+	policy := GetRetryPolicy(ctx)
+	observer := GetRetrievalObserver(ctx)
+
+	for mirrorIndex, sourceURL := range sourceURLs {
+		delay := policy.InitialDelay
+
+		for attempt := 1; attempt <= policy.Attempts; attempt++ {
+			retrievalAttempt := RetrievalAttempt{
+				Mirror:   mirrorIndex + 1,
+				Mirrors:  len(sourceURLs),
+				Attempt:  attempt,
+				Attempts: policy.Attempts,
+			}
+
+			log.Info("Retrieving from %v - %v...", sourceURL.Host, retrievalAttempt)
+			observer.AttemptStarted(sourceURL, retrievalAttempt)
+
+			err = retrieval(sourceURL, retrievalAttempt)
+			if err == nil {
+				return nil
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			log.Warning("Retrieval from %v failed: %v", sourceURL.Host, err)
+
+			if !IsRetriable(err) || attempt == policy.Attempts {
+				break
+			}
+
+			actualDelay := getRetryDelay(err, delay)
+
+			log.Info("Retrying in %v...", actualDelay)
+			observer.RetryScheduled(sourceURL, err, actualDelay)
+			err = SleepWithContext(ctx, actualDelay)
+			if err != nil {
+				return err
+			}
+
+			delay *= 2
+			if delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+		}
+
+		if mirrorIndex < len(sourceURLs)-1 {
+			log.Notice("Switching to the next mirror")
+			observer.MirrorSwitched(sourceURL, sourceURLs[mirrorIndex+1], err)
+		}
+	}
+
+	return err
+}
+
+/*
+IsRetriable returns true if the given retrieval error - even if wrapped, for
+example by a *url.Error - might be transient: timeouts, refused or reset
+connections, temporary DNS failures, truncated responses, server errors
+and throttling responses
+*/
+func IsRetriable(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+
+		return httpErr.StatusCode >= 500
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	for _, connectionErr := range transientConnectionErrors {
+		if errors.Is(err, connectionErr) {
+			return true
+		}
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func getRetryDelay(err error, defaultDelay time.Duration) time.Duration {
+	httpErr, isHTTPErr := err.(*HTTPError)
+	if !isHTTPErr {
+		return defaultDelay
+	}
+
+	retryAfterSeconds, parseErr := strconv.Atoi(httpErr.Header.Get("Retry-After"))
+	if parseErr != nil || retryAfterSeconds < 0 {
+		return defaultDelay
+	}
+
+	retryAfter := time.Duration(retryAfterSeconds) * time.Second
+	if retryAfter > maxRetryDelay {
+		return maxRetryDelay
+	}
+
+	return retryAfter
+}
+
+/*
+SleepWithContext waits for the given duration, returning the context's error
+as soon as the context is done
+*/
+func SleepWithContext(ctx context.Context, duration time.Duration) (err error) {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,275 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package networking
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/giancosta86/moondeploy/v3/credentials"
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+const defaultBufferSize = 32 * 1024
+
+/*
+RetrievalProgressCallback is called whenever a chunk has been retrieved;
+totalSize is -1 if the server did not declare it
+*/
+type RetrievalProgressCallback func(retrievedSize int64, totalSize int64)
+
+/*
+HTTPError is returned when the server answers with a non-2xx status code
+*/
+type HTTPError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+}
+
+func (err *HTTPError) Error() string {
+	return fmt.Sprintf("Cannot retrieve '%v': %v", err.URL, err.Status)
+}
+
+/*
+RetrieveFromURL retrieves the whole content of the given URL, sending
+the credentials available for its host
+*/
+func RetrieveFromURL(ctx context.Context, sourceURL *url.URL) (result []byte, err error) {
+	return RetrieveFromURLWithCredentials(ctx, sourceURL, nil)
+}
+
+/*
+RetrieveFromURLWithCredentials retrieves the whole content of the given URL,
+sending the given credentials - or, if they are nil, the ones available
+for its host
+*/
+func RetrieveFromURLWithCredentials(ctx context.Context, sourceURL *url.URL, requestCredentials *credentials.Credentials) (result []byte, err error) {
+	result, _, err = RetrieveFromURLWithHeader(ctx, sourceURL, requestCredentials)
+	return result, err
+}
+
+/*
+RetrieveFromURLWithHeader is like RetrieveFromURLWithCredentials, but also
+returns the header of the response - for example, to follow its pagination links
+*/
+func RetrieveFromURLWithHeader(ctx context.Context, sourceURL *url.URL, requestCredentials *credentials.Credentials) (result []byte, header http.Header, err error) {
+	var buffer bytes.Buffer
+
+	response, err := openURL(ctx, sourceURL, requestCredentials)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer response.Body.Close()
+
+	_, err = io.Copy(&buffer, response.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return buffer.Bytes(), response.Header, nil
+}
+
+/*
+RetrieveChunksFromURL copies the content of the given URL to the given writer,
+chunk by chunk, sending the credentials available for its host
+*/
+func RetrieveChunksFromURL(
+	ctx context.Context,
+	sourceURL *url.URL,
+	outputWriter io.Writer,
+	bufferSize int64,
+	progressCallback RetrievalProgressCallback) (err error) {
+
+	response, err := openURL(ctx, sourceURL, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+
+	buffer := make([]byte, bufferSize)
+	totalSize := response.ContentLength
+	var retrievedSize int64
+
+	for {
+		readBytes, readErr := response.Body.Read(buffer)
+
+		if readBytes > 0 {
+			_, err = outputWriter.Write(buffer[:readBytes])
+			if err != nil {
+				return err
+			}
+
+			retrievedSize += int64(readBytes)
+
+			if progressCallback != nil {
+				progressCallback(retrievedSize, totalSize)
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+func openURL(ctx context.Context, sourceURL *url.URL, explicitCredentials *credentials.Credentials) (response *http.Response, err error) {
+	requestURL, urlCredentials := splitUserInfo(sourceURL)
+	host := requestURL.Host
+
+	acceptingCredentials := credentials.AcceptsCredentials(requestURL)
+
+	requestCredentials := urlCredentials
+	if requestCredentials == nil && acceptingCredentials {
+		requestCredentials = explicitCredentials
+		if requestCredentials == nil {
+			requestCredentials = credentials.Lookup(host)
+		}
+	}
+
+	log.Debug("Sending request to '%v' - credentials: %v", requestURL.Redacted(), requestCredentials)
+
+	response, err = sendRequest(ctx, requestURL, requestCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusUnauthorized {
+		response.Body.Close()
+
+		credentials.Forget(host)
+
+		log.Notice("The server requires authentication for host '%v'", host)
+
+		if !acceptingCredentials {
+			log.Warning("Credentials are sent over plain HTTP only to the hosts listed in the InsecureCredentialHosts setting")
+			return nil, newHTTPError(requestURL, response)
+		}
+
+		response, err = answerAuthentication(ctx, requestURL, requestCredentials, response)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		response.Body.Close()
+		return nil, newHTTPError(requestURL, response)
+	}
+
+	return response, nil
+}
+
+/*
+answerAuthentication is called when the server has rejected the request: it first tries
+the default credentials of .netrc - unless they have just been rejected - then asks the user
+*/
+func answerAuthentication(ctx context.Context, requestURL *url.URL, rejectedCredentials *credentials.Credentials, rejectingResponse *http.Response) (response *http.Response, err error) {
+	host := requestURL.Host
+
+	defaultCredentials := credentials.LookupDefault()
+	if defaultCredentials != nil && defaultCredentials != rejectedCredentials {
+		log.Info("Sending the default credentials of .netrc to host '%v'...", host)
+
+		response, err = sendRequest(ctx, requestURL, defaultCredentials)
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode != http.StatusUnauthorized {
+			log.Notice("Default credentials accepted")
+			return response, nil
+		}
+
+		response.Body.Close()
+		rejectingResponse = response
+	}
+
+	promptedCredentials := credentials.Ask(host)
+	if promptedCredentials == nil {
+		return nil, newHTTPError(requestURL, rejectingResponse)
+	}
+
+	response, err = sendRequest(ctx, requestURL, promptedCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusUnauthorized {
+		credentials.Forget(host)
+	}
+
+	return response, nil
+}
+
+func sendRequest(ctx context.Context, requestURL *url.URL, requestCredentials *credentials.Credentials) (response *http.Response, err error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", requestURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	requestCredentials.Apply(request)
+
+	return http.DefaultClient.Do(request)
+}
+
+/*
+splitUserInfo removes the user info from the given URL, returning it as
+credentials - so that it can never appear in log messages or error messages
+*/
+func splitUserInfo(sourceURL *url.URL) (requestURL *url.URL, urlCredentials *credentials.Credentials) {
+	if sourceURL.User == nil {
+		return sourceURL, nil
+	}
+
+	password, _ := sourceURL.User.Password()
+	urlCredentials = &credentials.Credentials{
+		Username: sourceURL.User.Username(),
+		Password: password,
+	}
+
+	strippedURL := *sourceURL
+	strippedURL.User = nil
+
+	return &strippedURL, urlCredentials
+}
+
+func newHTTPError(requestURL *url.URL, response *http.Response) *HTTPError {
+	return &HTTPError{
+		URL:        requestURL.Redacted(),
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Header:     response.Header,
+	}
+}
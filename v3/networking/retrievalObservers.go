@@ -0,0 +1,72 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package networking
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+/*
+RetrievalObserver is notified about the progress of the retrievals performed
+with a context returned by WithRetrievalObserver(). Its methods are called
+synchronously, from the retrieving goroutine, so they should return quickly
+*/
+type RetrievalObserver interface {
+	AttemptStarted(sourceURL *url.URL, attempt RetrievalAttempt)
+	RetryScheduled(sourceURL *url.URL, err error, delay time.Duration)
+	MirrorSwitched(failedURL *url.URL, nextURL *url.URL, err error)
+	Throttled(sourceURL *url.URL, delay time.Duration)
+}
+
+type retrievalObserverKey struct{}
+
+/*
+WithRetrievalObserver returns a copy of the given context, causing the
+retrievals performed with it to notify the given observer
+*/
+func WithRetrievalObserver(ctx context.Context, observer RetrievalObserver) context.Context {
+	return context.WithValue(ctx, retrievalObserverKey{}, observer)
+}
+
+/*
+GetRetrievalObserver returns the observer attached to the given context
+via WithRetrievalObserver() - or an observer ignoring every notification
+*/
+func GetRetrievalObserver(ctx context.Context) RetrievalObserver {
+	observer, hasObserver := ctx.Value(retrievalObserverKey{}).(RetrievalObserver)
+	if !hasObserver {
+		return nullRetrievalObserver{}
+	}
+
+	return observer
+}
+
+type nullRetrievalObserver struct{}
+
+func (nullRetrievalObserver) AttemptStarted(sourceURL *url.URL, attempt RetrievalAttempt) {}
+
+func (nullRetrievalObserver) RetryScheduled(sourceURL *url.URL, err error, delay time.Duration) {}
+
+func (nullRetrievalObserver) MirrorSwitched(failedURL *url.URL, nextURL *url.URL, err error) {}
+
+func (nullRetrievalObserver) Throttled(sourceURL *url.URL, delay time.Duration) {}
@@ -0,0 +1,348 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+)
+
+const descriptorsCorpusDirectory = "testdata/descriptors"
+
+const goldenFileSuffix = ".golden.json"
+
+var updateGoldenFiles = flag.Bool("update", false, "Update the golden files of the descriptor corpus")
+
+var corpusPlatforms = []descriptors.Platform{
+	{OS: "darwin", Arch: "amd64"},
+	{OS: "linux", Arch: "amd64"},
+	{OS: "linux", Arch: "arm64"},
+	{OS: "windows", Arch: "amd64"},
+}
+
+/*
+goldenDescriptor is the expected outcome of reading a corpus descriptor
+*/
+type goldenDescriptor struct {
+	SchemaWarnings []string
+	Platforms      map[string]*platformOutcome
+}
+
+type platformOutcome struct {
+	Fields *computedFields `json:",omitempty"`
+	Error  string          `json:",omitempty"`
+}
+
+type computedFields struct {
+	DescriptorVersion  string
+	DeclaredBaseURL    string
+	ActualBaseURL      string
+	MirrorBaseURLs     []string
+	DescriptorFileName string
+
+	Name        string
+	AppVersion  string
+	Publisher   string
+	Description string
+	Title       string
+
+	PackageVersions map[string]string
+	RawPackages     map[string]*descriptors.RawPackage
+	CommandLine     []string
+	IconPath        string
+
+	SkipPackageLevels  int
+	SkipUpdateCheck    bool
+	SingleInstance     bool
+	MaxPackageEntries  int
+	MaxPackageSizeInMB int64
+
+	Requirements  *descriptors.Requirements
+	RestartPolicy *descriptors.RestartPolicy
+	LaunchMode    string
+}
+
+/*
+TestDescriptorCorpus reads every descriptor of the versioned corpus on each platform,
+comparing the computed fields with the golden files; it also checks that the bytes
+returned by GetBytes() - used for the local descriptor - fully comply with the schema
+and are read back identically.
+
+Descriptors are parsed offline - so their actual base URL always matches the
+declared one - because many of them reference the latest release of a GitHub repo.
+
+Run "go test -update" to regenerate the golden files after an intentional change.
+*/
+func TestDescriptorCorpus(t *testing.T) {
+	descriptorPaths := getCorpusDescriptorPaths(t)
+
+	if len(descriptorPaths) == 0 {
+		t.Fatal("The descriptor corpus is empty")
+	}
+
+	for _, descriptorPath := range descriptorPaths {
+		descriptorPath := descriptorPath
+
+		t.Run(filepath.ToSlash(descriptorPath), func(t *testing.T) {
+			checkCorpusDescriptor(t, descriptorPath)
+		})
+	}
+}
+
+func getCorpusDescriptorPaths(t *testing.T) []string {
+	descriptorPaths := []string{}
+
+	err := filepath.Walk(descriptorsCorpusDirectory, func(filePath string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fileInfo.Mode().IsRegular() && !strings.HasSuffix(filePath, goldenFileSuffix) {
+			descriptorPaths = append(descriptorPaths, filePath)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(descriptorPaths)
+
+	return descriptorPaths
+}
+
+func checkCorpusDescriptor(t *testing.T, descriptorPath string) {
+	descriptorBytes, err := ioutil.ReadFile(descriptorPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descriptorBytes, err = descriptors.ConvertToJSON(descriptorBytes, descriptors.GetEncodingFromFileName(descriptorPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemaWarnings, err := descriptors.CheckSchema(descriptorBytes)
+	if err != nil {
+		t.Fatalf("Schema violation: %v", err)
+	}
+
+	actualGolden := &goldenDescriptor{
+		SchemaWarnings: schemaWarnings,
+		Platforms:      make(map[string]*platformOutcome),
+	}
+
+	for _, platform := range corpusPlatforms {
+		descriptor, err := descriptors.ParseAppDescriptorForPlatform(descriptorBytes, platform)
+		if err != nil {
+			actualGolden.Platforms[platform.String()] = &platformOutcome{
+				Error: err.Error(),
+			}
+			continue
+		}
+
+		fields := getComputedFields(descriptor)
+
+		actualGolden.Platforms[platform.String()] = &platformOutcome{
+			Fields: fields,
+		}
+
+		checkRoundTrip(t, descriptorBytes, descriptor, platform, fields)
+	}
+
+	actualGoldenBytes, err := json.MarshalIndent(actualGolden, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actualGoldenBytes = append(actualGoldenBytes, '\n')
+
+	goldenFilePath := descriptorPath + goldenFileSuffix
+
+	if *updateGoldenFiles {
+		err = ioutil.WriteFile(goldenFilePath, actualGoldenBytes, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expectedGoldenBytes, err := ioutil.ReadFile(goldenFilePath)
+	if err != nil {
+		t.Fatalf("Cannot read the golden file - run the tests with -update to create it: %v", err)
+	}
+
+	if !bytes.Equal(bytes.TrimSpace(expectedGoldenBytes), bytes.TrimSpace(actualGoldenBytes)) {
+		t.Errorf("The descriptor does not match its golden file '%v'.\n\nExpected:\n%s\n\nActual:\n%s",
+			goldenFilePath,
+			expectedGoldenBytes,
+			actualGoldenBytes)
+	}
+}
+
+func checkRoundTrip(t *testing.T, descriptorBytes []byte, descriptor descriptors.AppDescriptor, platform descriptors.Platform, fields *computedFields) {
+	localDescriptorBytes, err := descriptor.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extraKeys := getExtraKeys(t, descriptorBytes, localDescriptorBytes)
+	if len(extraKeys) > 0 {
+		t.Errorf("GetBytes() on %v adds keys missing from the original descriptor: %v", platform, extraKeys)
+	}
+
+	localSchemaWarnings, err := descriptors.CheckSchema(localDescriptorBytes)
+	if err != nil {
+		t.Errorf("The bytes returned by GetBytes() on %v violate the schema: %v", platform, err)
+	} else if len(localSchemaWarnings) > 0 {
+		t.Errorf("The bytes returned by GetBytes() on %v have schema warnings: %v", platform, localSchemaWarnings)
+	}
+
+	localDescriptor, err := descriptors.ParseAppDescriptorForPlatform(localDescriptorBytes, platform)
+	if err != nil {
+		t.Fatalf("Cannot read back the bytes on %v: %v", platform, err)
+	}
+
+	expectedFieldsBytes, _ := json.Marshal(fields)
+	actualFieldsBytes, _ := json.Marshal(getComputedFields(localDescriptor))
+
+	if !bytes.Equal(expectedFieldsBytes, actualFieldsBytes) {
+		t.Errorf("The GetBytes() round-trip changed the descriptor on %v.\n\nExpected:\n%s\n\nActual:\n%s",
+			platform,
+			expectedFieldsBytes,
+			actualFieldsBytes)
+	}
+}
+
+/*
+getExtraKeys returns the location of each key found in the local descriptor but
+not in the original one - as it would change the on-disk format of the descriptor
+*/
+func getExtraKeys(t *testing.T, descriptorBytes []byte, localDescriptorBytes []byte) []string {
+	var originalValue interface{}
+	err := json.Unmarshal(descriptorBytes, &originalValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var localValue interface{}
+	err = json.Unmarshal(localDescriptorBytes, &localValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extraKeys := []string{}
+	collectExtraKeys(originalValue, localValue, "", &extraKeys)
+	sort.Strings(extraKeys)
+
+	return extraKeys
+}
+
+func collectExtraKeys(originalValue interface{}, localValue interface{}, location string, extraKeys *[]string) {
+	switch localTypedValue := localValue.(type) {
+	case map[string]interface{}:
+		originalObject, _ := originalValue.(map[string]interface{})
+
+		for key, localItem := range localTypedValue {
+			keyLocation := key
+			if location != "" {
+				keyLocation = location + "." + key
+			}
+
+			originalItem, keyFound := originalObject[key]
+			if !keyFound {
+				*extraKeys = append(*extraKeys, keyLocation)
+				continue
+			}
+
+			collectExtraKeys(originalItem, localItem, keyLocation, extraKeys)
+		}
+
+	case []interface{}:
+		originalArray, _ := originalValue.([]interface{})
+
+		for itemIndex, localItem := range localTypedValue {
+			if itemIndex < len(originalArray) {
+				collectExtraKeys(originalArray[itemIndex], localItem, fmt.Sprintf("%v[%v]", location, itemIndex), extraKeys)
+			}
+		}
+	}
+}
+
+func getComputedFields(descriptor descriptors.AppDescriptor) *computedFields {
+	fields := &computedFields{
+		DescriptorVersion:  descriptor.GetDescriptorVersion().String(),
+		DeclaredBaseURL:    descriptor.GetDeclaredBaseURL().String(),
+		ActualBaseURL:      descriptor.GetActualBaseURL().String(),
+		MirrorBaseURLs:     []string{},
+		DescriptorFileName: descriptor.GetDescriptorFileName(),
+
+		Name:        descriptor.GetName(),
+		AppVersion:  descriptor.GetAppVersion().String(),
+		Publisher:   descriptor.GetPublisher(),
+		Description: descriptor.GetDescription(),
+		Title:       descriptor.GetTitle(),
+
+		PackageVersions: make(map[string]string),
+		RawPackages:     make(map[string]*descriptors.RawPackage),
+		CommandLine:     descriptor.GetCommandLine(),
+		IconPath:        descriptor.GetIconPath(),
+
+		SkipPackageLevels:  descriptor.GetSkipPackageLevels(),
+		SkipUpdateCheck:    descriptor.IsSkipUpdateCheck(),
+		SingleInstance:     descriptor.IsSingleInstance(),
+		MaxPackageEntries:  descriptor.GetMaxPackageEntries(),
+		MaxPackageSizeInMB: descriptor.GetMaxPackageSizeInMB(),
+
+		Requirements:  descriptor.GetRequirements(),
+		RestartPolicy: descriptor.GetRestartPolicy(),
+		LaunchMode:    descriptor.GetLaunchMode(),
+	}
+
+	for _, mirrorBaseURL := range descriptor.GetMirrorBaseURLs() {
+		fields.MirrorBaseURLs = append(fields.MirrorBaseURLs, mirrorBaseURL.String())
+	}
+
+	for packageName, packageVersion := range descriptor.GetPackageVersions() {
+		if packageVersion != nil {
+			fields.PackageVersions[packageName] = packageVersion.String()
+		} else {
+			fields.PackageVersions[packageName] = ""
+		}
+
+		rawPackage := descriptor.GetRawPackage(packageName)
+		if rawPackage != nil {
+			fields.RawPackages[packageName] = rawPackage
+		}
+	}
+
+	return fields
+}
@@ -25,4 +25,11 @@ It is not trivial to perform automated tests for MoonDeploy.
 
 Currently, testing is based on its actual execution for existing programs
 such as GraphsJ, Chronos IDE and KnapScal.
+
+However, descriptors are checked by a golden-file suite: testdata/descriptors
+contains a versioned corpus of V1, V2 and V3 descriptors, each with a
+.golden.json file listing its expected computed fields on each platform.
+After an intentional change, the golden files can be regenerated via:
+
+	go test ./v3/test -update
 */
@@ -0,0 +1,76 @@
+/*§
+  ===========================================================================
+  MoonDeploy
+  ===========================================================================
+  Copyright (C) 2015-2016 Gianluca Costa
+  ===========================================================================
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+  ===========================================================================
+*/
+
+package batchui
+
+import (
+	"github.com/giancosta86/moondeploy/v3/credentials"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+	"github.com/giancosta86/moondeploy/v3/log"
+)
+
+/*
+BatchUserInterface is a user interface for unattended operations - such as
+scheduled updates - that shows nothing and never waits for the user.
+
+As the descriptors are explicitly chosen by whoever starts the process,
+the first run is allowed for secure apps only; desktop shortcuts
+are never created and credentials can only come from the credentials file.
+*/
+type BatchUserInterface struct{}
+
+func NewBatchUserInterface() *BatchUserInterface {
+	return &BatchUserInterface{}
+}
+
+func (userInterface *BatchUserInterface) ShowError(message string) {
+	log.Error(message)
+}
+
+func (userInterface *BatchUserInterface) AskForSecureFirstRun(bootDescriptor descriptors.AppDescriptor) (canRun bool) {
+	return true
+}
+
+func (userInterface *BatchUserInterface) AskForUntrustedFirstRun(bootDescriptor descriptors.AppDescriptor) (canRun bool) {
+	log.Warning("Refusing the unattended first run of an app having an insecure address: %v",
+		bootDescriptor.GetDeclaredBaseURL())
+	return false
+}
+
+func (userInterface *BatchUserInterface) SetApp(app string) {}
+
+func (userInterface *BatchUserInterface) SetHeader(header string) {}
+
+func (userInterface *BatchUserInterface) SetStatus(status string) {}
+
+func (userInterface *BatchUserInterface) SetProgress(progress float64) {}
+
+func (userInterface *BatchUserInterface) AskForDesktopShortcut(referenceDescriptor descriptors.AppDescriptor) (canCreate bool) {
+	return false
+}
+
+func (userInterface *BatchUserInterface) AskForCredentials(host string) *credentials.Credentials {
+	log.Warning("Cannot ask for the credentials of '%v' during an unattended operation", host)
+	return nil
+}
+
+func (userInterface *BatchUserInterface) Show() {}
+
+func (userInterface *BatchUserInterface) Hide() {}
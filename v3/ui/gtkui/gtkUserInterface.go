@@ -26,6 +26,7 @@ import (
 
 	"github.com/gotk3/gotk3/gtk"
 
+	"github.com/giancosta86/moondeploy/v3/credentials"
 	"github.com/giancosta86/moondeploy/v3/descriptors"
 	"github.com/giancosta86/moondeploy/v3/launchers"
 	"github.com/giancosta86/moondeploy/v3/ui"
@@ -183,6 +184,79 @@ func (userInterface *GtkUserInterface) AskForDesktopShortcut(referenceDescriptor
 	return userInterface.askYesNo(ui.FormatDesktopShortcutPrompt(referenceDescriptor))
 }
 
+func (userInterface *GtkUserInterface) AskForCredentials(host string) *credentials.Credentials {
+	result := runOnUIThreadAndWait(func() interface{} {
+		dialog, err := gtk.DialogNew()
+		if err != nil {
+			panic(err)
+		}
+		defer dialog.Destroy()
+
+		dialog.SetTitle(userInterface.launcher.GetTitle())
+		dialog.SetModal(true)
+
+		if userInterface.window.GetVisible() {
+			dialog.SetTransientFor(userInterface.window)
+		}
+
+		contentArea, err := dialog.GetContentArea()
+		if err != nil {
+			panic(err)
+		}
+
+		promptLabel, err := gtk.LabelNew(ui.FormatCredentialsPrompt(host))
+		if err != nil {
+			panic(err)
+		}
+		contentArea.PackStart(promptLabel, false, false, 8)
+
+		usernameEntry, err := gtk.EntryNew()
+		if err != nil {
+			panic(err)
+		}
+		usernameEntry.SetPlaceholderText("Username")
+		contentArea.PackStart(usernameEntry, false, false, 4)
+
+		passwordEntry, err := gtk.EntryNew()
+		if err != nil {
+			panic(err)
+		}
+		passwordEntry.SetPlaceholderText("Password")
+		passwordEntry.SetVisibility(false)
+		contentArea.PackStart(passwordEntry, false, false, 4)
+
+		dialog.AddButton("Cancel", gtk.RESPONSE_CANCEL)
+		dialog.AddButton("OK", gtk.RESPONSE_OK)
+
+		dialog.ShowAll()
+
+		if dialog.Run() != int(gtk.RESPONSE_OK) {
+			return (*credentials.Credentials)(nil)
+		}
+
+		username, err := usernameEntry.GetText()
+		if err != nil {
+			panic(err)
+		}
+
+		password, err := passwordEntry.GetText()
+		if err != nil {
+			panic(err)
+		}
+
+		if username == "" {
+			return (*credentials.Credentials)(nil)
+		}
+
+		return &credentials.Credentials{
+			Username: username,
+			Password: password,
+		}
+	})
+
+	return result.(*credentials.Credentials)
+}
+
 func (userInterface *GtkUserInterface) SetApp(app string) {
 	runOnUIThreadAndWait(func() interface{} {
 		userInterface.window.SetTitle(fmt.Sprintf("%v - %v", userInterface.launcher.GetName(), app))
@@ -47,6 +47,10 @@ func FormatDesktopShortcutPrompt(referenceDescriptor descriptors.AppDescriptor)
 	return "Would you like to create a desktop shortcut for the application?"
 }
 
+func FormatCredentialsPrompt(host string) (prompt string) {
+	return fmt.Sprintf("The server '%v' requires authentication.", host)
+}
+
 const untrustedWarning = "\n\n\nWARNING: the address is insecure, so " +
 	"the integrity of the application files might be compromised by " +
 	"third parties during the download process. Do you really want to continue?"
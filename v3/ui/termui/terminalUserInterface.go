@@ -29,6 +29,7 @@ import (
 	"github.com/giancosta86/caravel/terminals"
 
 	"github.com/giancosta86/moondeploy/v3"
+	"github.com/giancosta86/moondeploy/v3/credentials"
 	"github.com/giancosta86/moondeploy/v3/descriptors"
 	"github.com/giancosta86/moondeploy/v3/launchers"
 	"github.com/giancosta86/moondeploy/v3/log"
@@ -94,6 +95,37 @@ func (userInterface *TerminalUserInterface) askYesNo(prompt string) (yesResponse
 	}
 }
 
+func (userInterface *TerminalUserInterface) askLine(prompt string, question string, hiddenInput bool) string {
+	terminal := userInterface.terminal
+
+	terminal.ResetStyle()
+	userInterface.setupColors()
+	terminal.Clear()
+	terminal.ShowCursor()
+
+	userInterface.drawTitle()
+
+	terminal.MoveCursor(8, 1)
+	fmt.Printf("%v\n\n%v: ", prompt, question)
+
+	if hiddenInput {
+		terminal.EnableTextHidden()
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+
+	userInput, err := reader.ReadString('\n')
+
+	terminal.ResetStyle()
+
+	if err != nil {
+		userInterface.ShowError(err.Error())
+		os.Exit(v3.ExitCodeError)
+	}
+
+	return strings.TrimRight(userInput, "\r\n")
+}
+
 func (userInterface *TerminalUserInterface) styleFirstRunPrompt(prompt string) string {
 	if !userInterface.terminal.SupportsANSI() {
 		return prompt
@@ -146,6 +178,24 @@ func (userInterface *TerminalUserInterface) AskForDesktopShortcut(referenceDescr
 	return userInterface.askYesNo(prompt)
 }
 
+func (userInterface *TerminalUserInterface) AskForCredentials(host string) *credentials.Credentials {
+	prompt := ui.FormatCredentialsPrompt(host)
+
+	username := userInterface.askLine(prompt, "Username (leave empty to cancel)", false)
+	if username == "" {
+		return nil
+	}
+
+	password := userInterface.askLine(prompt, "Password", true)
+
+	userInterface.redraw()
+
+	return &credentials.Credentials{
+		Username: username,
+		Password: password,
+	}
+}
+
 func (userInterface *TerminalUserInterface) Show() {
 
 }
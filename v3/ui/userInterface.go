@@ -20,11 +20,18 @@
 
 package ui
 
-import "github.com/giancosta86/moondeploy/v3/descriptors"
+import (
+	"github.com/giancosta86/moondeploy/v3/credentials"
+	"github.com/giancosta86/moondeploy/v3/descriptors"
+)
 
 /*
 UserInterface is the interface that must be implemented to plug a user interface,
-based on any technology, into MoonDeploy's infrastructure
+based on any technology, into MoonDeploy's infrastructure.
+
+During engine.Run(), SetHeader(), SetStatus() and SetProgress() are driven by
+the engine's events - unless the implementation is also an engine.EventListener,
+in which case it receives the typed events directly.
 */
 type UserInterface interface {
 	/*
@@ -74,6 +81,13 @@ type UserInterface interface {
 	*/
 	AskForDesktopShortcut(referenceDescriptor descriptors.AppDescriptor) (canCreate bool)
 
+	/*
+		AskForCredentials asks the user for the credentials required by the given
+		host, whenever the server refuses the request as unauthorized.
+		Must return nil if the user does not provide them
+	*/
+	AskForCredentials(host string) (credentials *credentials.Credentials)
+
 	/*
 		Show shows the user interface
 	*/
@@ -22,10 +22,13 @@ package versioning
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
+var tagRegex = regexp.MustCompile(`^\D*(\d.*)$`)
+
 type Version struct {
 	Major   int
 	Minor   int
@@ -127,3 +130,17 @@ func MustParseVersion(versionString string) (version *Version) {
 
 	return version
 }
+
+/*
+ParseTagVersion parses the version within a release tag, that must be
+in the format: <any string, even empty, without digits><VERSION> - for example,
+"v1.2" or "release-1.2"
+*/
+func ParseTagVersion(tag string) (version *Version, err error) {
+	tagComponents := tagRegex.FindStringSubmatch(tag)
+	if tagComponents == nil {
+		return nil, fmt.Errorf("Release tags must be in the format: <any string, even empty><VERSION>, not '%v'", tag)
+	}
+
+	return ParseVersion(tagComponents[1])
+}